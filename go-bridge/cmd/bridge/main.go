@@ -1,7 +1,9 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
@@ -15,15 +17,58 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/activation"
 	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/app"
+	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/authz"
 	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/config"
+	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/eventbus"
 	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/logging"
+	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/metrics"
 	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/opencode"
+	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/ports"
+	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/secrets"
 	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/service"
 	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/storage"
 	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/telegram"
 )
 
+// Secret names used as encrypted_secrets keys, shared between
+// resolveSecrets (read path) and runRotateSecrets (rewrite path).
+const (
+	secretNameBotToken           = "bot_token"
+	secretNameOpenCodeServerPass = "opencode_server_pass"
+)
+
+// webhookStatusTotal buckets webhook HTTP responses by status class so an
+// operator can tell a spike of forged/malformed requests (4xx) apart from
+// the bridge itself failing to keep up (5xx).
+var webhookStatusTotal = metrics.Default.Register(metrics.NewCounterVec(
+	"webhook_responses_total", "Webhook HTTP responses by status class.", "class"))
+
+// webhookStatusRecorder captures the status code the webhook handler wrote
+// so the deferred webhookStatusTotal increment can see it, since
+// http.ResponseWriter doesn't expose what's already been written.
+type webhookStatusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *webhookStatusRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func webhookStatusClass(status int) string {
+	switch {
+	case status >= 500:
+		return "5xx"
+	case status >= 400:
+		return "4xx"
+	default:
+		return "2xx"
+	}
+}
+
 func main() {
 	if err := run(os.Args[1:]); err != nil {
 		fmt.Fprintf(os.Stderr, "bridge: %v\n", err)
@@ -49,6 +94,16 @@ func run(args []string) error {
 		return runImportJSON()
 	case "resolve":
 		return runResolve(args[1:])
+	case "migrate-encrypt":
+		return runMigrateEncrypt()
+	case "rotate-secrets":
+		return runRotateSecrets(args[1:])
+	case "rekey":
+		return runRekey(args[1:])
+	case "doctor":
+		return runDoctor(args[1:])
+	case "reload":
+		return runReload(args[1:])
 	default:
 		return fmt.Errorf("unknown command %q", args[0])
 	}
@@ -60,7 +115,7 @@ func runServe() error {
 		return err
 	}
 
-	logger, err := logging.New(cfg)
+	logger, logLevel, err := logging.NewWithLevel(cfg)
 	if err != nil {
 		return err
 	}
@@ -91,32 +146,98 @@ func runServe() error {
 		return err
 	}
 
-	opencodeClient := opencode.NewClient(cfg)
+	if err := resolveSecrets(context.Background(), &cfg, store); err != nil {
+		return fmt.Errorf("resolve secrets: %w", err)
+	}
+
+	configManager, err := config.NewManager(context.Background(), cfg, cfg.ConfigOverlayPath, store)
+	if err != nil {
+		return fmt.Errorf("config manager: %w", err)
+	}
+
+	opencodeClient := opencode.NewClient(cfg, store)
 	telegramAPI := telegram.NewAPI(cfg.BotToken, cfg.OpenCodeTimeout, time.Duration(cfg.BotPollingIntervalS)*time.Second)
-	resolver := telegram.NewResolver(cfg.BotToken, cfg.OpenCodeTimeout)
-	resolveService := service.NewResolveService(resolver, store)
-	sessionLinks := service.NewSessionLinkService(store, cfg.DefaultSessionID)
+
+	// BOT_TRANSPORT has no selectable value beyond "polling"/"webhook" yet -
+	// internal/telegram/tdlib.go's dialTDLib is a hard stub, and
+	// config.validate rejects "tdlib" - so there is no tdlib branch to wire
+	// in here until that transport is real. tdlibClient stays nil and is
+	// only reused below by the RESOLVER_BACKEND=tdlib resolver chain, which
+	// opens its own session on demand when it isn't.
+	var interactiveTelegram service.InteractiveTelegramClient = telegramAPI
+	var tdlibClient *telegram.TDLibClient
+
+	resolverBackends, err := buildResolverBackends(cfg, tdlibClient)
+	if err != nil {
+		return fmt.Errorf("resolver backends: %w", err)
+	}
+	resolveService := service.NewResolveService(
+		resolverBackends,
+		store,
+		store,
+		time.Duration(cfg.UsernameCacheTTLSeconds)*time.Second,
+		time.Duration(cfg.UsernameNegativeCacheTTLSeconds)*time.Second,
+		cfg.UsernameResolveConcurrency,
+	)
+
+	var sessionAuthz *authz.Enforcer
+	var sessionAuthzEnforcer ports.AuthzEnforcer
+	if cfg.AuthzPolicyFile != "" {
+		var enforcerErr error
+		sessionAuthz, enforcerErr = authz.New(cfg.AuthzPolicyFile)
+		if enforcerErr != nil {
+			return fmt.Errorf("authz policy: %w", enforcerErr)
+		}
+		sessionAuthzEnforcer = sessionAuthz
+	}
+	sessionLinks := service.NewSessionLinkService(store, sessionAuthzEnforcer, cfg.DefaultSessionID)
+	rateLimiter := storage.NewRateLimiter(store, cfg.DefaultUserRequestsPerDay, cfg.DefaultUserTokensPerMonth)
+
+	// TELEGRAM_TRANSPORT has no selectable value beyond "bot" yet -
+	// internal/telegram/mtproto.go is an unimplemented skeleton (see its
+	// dialMTProto), and config.validate rejects anything else - so there is
+	// no mtproto branch to wire in here until that transport is real.
+	var relayTelegram ports.TelegramClient = telegramAPI
 	bridgeService := service.NewBridgeService(
 		logger,
 		opencodeClient,
-		telegramAPI,
+		interactiveTelegram,
+		store,
+		store,
+		store,
 		store,
 		store,
+		configManager,
 		sessionLinks,
+		rateLimiter,
 		cfg.SessionsListLimit,
 		cfg.SessionsSource,
 		cfg.SessionsShowIDList,
+		cfg.DefaultLocale,
 	)
 	controlService := service.NewControlService(store, sessionLinks, store, opencodeClient, cfg.SessionsListLimit, cfg.SessionsSource)
+	bus, err := newEventBus(cfg)
+	if err != nil {
+		return err
+	}
 	relayService := service.NewRelayService(
 		logger,
 		opencodeClient,
 		store,
-		telegramAPI,
+		relayTelegram,
 		cfg.RelayMode,
 		cfg.RelayFallback,
 		cfg.RelayFallbackDelayMs,
+		cfg.RelayAttachmentThresholdBytes,
+		store,
+		metrics.Default,
+		bus,
+		store,
+		cfg.ReplicaID,
+		cfg.RelayLeaseTTLSeconds,
+		cfg.RelayMaxTextBytes,
 	)
+	controlService.SetRelayService(relayService)
 
 	server := app.NewHealthServer(cfg, logger, func(ctx context.Context, usernames []string) app.ResolveResponse {
 		result := resolveService.ResolveAndPersist(ctx, usernames)
@@ -129,12 +250,42 @@ func runServe() error {
 			unresolved = append(unresolved, app.ResolveItem{Username: item.Username, Reason: item.Reason})
 		}
 		return app.ResolveResponse{Resolved: resolved, Unresolved: unresolved}
-	})
+	}, metrics.Default)
 	server.SetControlService(controlService)
 	var webhookServer *http.Server
+	drain := service.NewDrainTracker()
 
-	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	// SIGUSR2 triggers the same graceful drain as SIGTERM (see the shutdown
+	// select below); it exists as a separate signal so a deploy script can
+	// ask for a drain-and-restart without it looking like an operator-issued
+	// SIGTERM/SIGINT in process-manager logs.
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM, syscall.SIGUSR2)
 	defer cancel()
+	server.SetReloadFn(cancel)
+
+	if sessionAuthz != nil {
+		go sessionAuthz.Watch(ctx, time.Duration(cfg.AuthzPolicyReloadSeconds)*time.Second)
+	}
+
+	go configManager.WatchSIGHUP(ctx, func(err error) {
+		logger.Error("config overlay reload failed", "error", err)
+	})
+	go watchRelayModeOverrides(ctx, configManager, relayService)
+	go watchLogLevelOverrides(ctx, configManager, logLevel, logger)
+	go watchAllowedUserIDsOverrides(ctx, configManager, store, logger)
+	go watchOpenCodeTimeoutOverrides(ctx, configManager, opencodeClient, logger)
+
+	idleWatcher := service.NewIdleWatcher(time.Duration(cfg.IdleTimeoutSeconds) * time.Second)
+	relayService.SetActivityHook(idleWatcher.Reset)
+	if cfg.IdleTimeoutSeconds > 0 {
+		go func() {
+			idleWatcher.Wait(ctx)
+			if ctx.Err() == nil {
+				logger.Info("idle timeout reached; shutting down", "idle_timeout_seconds", cfg.IdleTimeoutSeconds)
+				cancel()
+			}
+		}()
+	}
 
 	errCh := make(chan error, 4)
 	go func() {
@@ -155,19 +306,32 @@ func runServe() error {
 			logger.Warn("delete webhook failed before polling", "error", err)
 		}
 		go func() {
-			errCh <- telegramAPI.PollUpdates(ctx, bridgeService.HandleUpdate)
+			errCh <- telegramAPI.PollUpdates(ctx, func(updateCtx context.Context, update telegram.Update) {
+				idleWatcher.Reset()
+				drain.Add()
+				defer drain.Done()
+				bridgeService.HandleUpdate(updateCtx, update)
+			})
 		}()
 	} else {
-		if err := telegramAPI.SetupWebhook(ctx, cfg.WebhookURL); err != nil {
+		if err := telegramAPI.SetupWebhook(ctx, cfg.WebhookURL, cfg.WebhookSecretToken); err != nil {
 			return err
 		}
 		webhookPath := telegramAPI.WebhookPath(cfg.WebhookURL)
 		mux := http.NewServeMux()
-		mux.HandleFunc(webhookPath, func(w http.ResponseWriter, r *http.Request) {
+		mux.HandleFunc(webhookPath, func(rw http.ResponseWriter, r *http.Request) {
+			w := &webhookStatusRecorder{ResponseWriter: rw, status: http.StatusOK}
+			defer func() { webhookStatusTotal.WithLabelValue(webhookStatusClass(w.status)).Inc() }()
 			if r.Method != http.MethodPost {
 				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
 				return
 			}
+			if err := telegram.VerifyWebhookRequest(r, cfg.WebhookSecretToken, cfg.WebhookTrustedProxyCIDRs); err != nil {
+				logger.Warn("rejected forged webhook request", "error", err, "remote_addr", r.RemoteAddr)
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			idleWatcher.Reset()
 			body, err := io.ReadAll(r.Body)
 			if err != nil {
 				http.Error(w, "invalid body", http.StatusBadRequest)
@@ -178,12 +342,30 @@ func runServe() error {
 				http.Error(w, "invalid update", http.StatusBadRequest)
 				return
 			}
+
+			drain.Add()
+			if drain.Draining() {
+				// Ack immediately so Telegram stops retrying while we're
+				// shutting down, and finish this update on a tracked
+				// goroutine the shutdown path waits for.
+				w.WriteHeader(http.StatusOK)
+				go func() {
+					defer drain.Done()
+					bridgeService.HandleUpdate(context.Background(), update)
+				}()
+				return
+			}
+			defer drain.Done()
 			bridgeService.HandleUpdate(r.Context(), update)
 			w.WriteHeader(http.StatusOK)
 		})
 		webhookServer = &http.Server{Addr: cfg.WebhookListenAddr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+		webhookListener, err := activation.Listener(cfg.WebhookListenAddr)
+		if err != nil {
+			return fmt.Errorf("webhook listener: %w", err)
+		}
 		go func() {
-			errCh <- webhookServer.ListenAndServe()
+			errCh <- webhookServer.Serve(webhookListener)
 		}()
 	}
 
@@ -197,6 +379,15 @@ func runServe() error {
 
 	select {
 	case <-ctx.Done():
+		logger.Info("shutting down bridge; draining in-flight updates", "drain_timeout_seconds", cfg.ShutdownDrainTimeoutSeconds)
+		drain.SetDraining(true)
+		drainCtx, drainCancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownDrainTimeoutSeconds)*time.Second)
+		drained := drain.Wait(drainCtx)
+		drainCancel()
+		if !drained {
+			logger.Warn("shutdown drain timed out; some in-flight updates may be interrupted")
+		}
+
 		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer shutdownCancel()
 		logger.Info("shutting down bridge")
@@ -217,6 +408,153 @@ func runServe() error {
 	}
 }
 
+// newEventBus builds the ports.EventBus RelayService uses to coordinate
+// across replicas, selected via EVENT_BUS. The in-process backend is the
+// only one fully wired up today; nats requires a real client library (see
+// internal/eventbus/nats.go).
+func newEventBus(cfg config.Config) (ports.EventBus, error) {
+	switch cfg.EventBusBackend {
+	case "nats":
+		return eventbus.NewNATSBus(cfg.NATSURL)
+	default:
+		return eventbus.NewInProcessBus(), nil
+	}
+}
+
+// buildResolverBackends builds the ordered chain of username resolver
+// backends ResolveService tries, selected via RESOLVER_BACKEND. A non-nil
+// tdlibClient is reused for the "tdlib" backend; both callers pass nil today
+// (BOT_TRANSPORT has no tdlib mode), so that backend opens a dedicated
+// session of its own.
+func buildResolverBackends(cfg config.Config, tdlibClient *telegram.TDLibClient) ([]service.UsernameResolver, error) {
+	backends := make([]service.UsernameResolver, 0, len(cfg.ResolverBackends))
+	for _, name := range cfg.ResolverBackends {
+		switch name {
+		case "bot_api":
+			backends = append(backends, telegram.NewResolver(cfg.BotToken, cfg.OpenCodeTimeout))
+		case "tdlib":
+			client := tdlibClient
+			if client == nil {
+				var err error
+				client, err = telegram.NewTDLibClient(cfg.TelegramAPIID, cfg.TelegramAPIHash, cfg.DataDir)
+				if err != nil {
+					return nil, fmt.Errorf("tdlib backend: %w", err)
+				}
+			}
+			backends = append(backends, client)
+		case "directory":
+			directory, err := telegram.NewDirectoryResolver(cfg.ResolverDirectoryPath)
+			if err != nil {
+				return nil, fmt.Errorf("directory backend: %w", err)
+			}
+			backends = append(backends, directory)
+		default:
+			return nil, fmt.Errorf("unknown resolver backend %q", name)
+		}
+	}
+	return backends, nil
+}
+
+// resolveSecrets overwrites cfg's sensitive fields with whatever the
+// encrypted secrets store has on file for them, falling back to the env
+// vars config.LoadFromEnv already populated cfg with when nothing's
+// stored. This lets an operator run "rotate-secrets"/provision the store
+// once and drop BOT_TOKEN/OPENCODE_SERVER_PASS from their deployment.
+func resolveSecrets(ctx context.Context, cfg *config.Config, store ports.SecretsRepository) error {
+	resolver := secrets.NewResolver(store)
+
+	botToken, err := resolver.Resolve(ctx, secretNameBotToken, cfg.BotToken)
+	if err != nil {
+		return err
+	}
+	cfg.BotToken = botToken.Reveal()
+
+	openCodeServerPass, err := resolver.Resolve(ctx, secretNameOpenCodeServerPass, cfg.OpenCodeServerPass)
+	if err != nil {
+		return err
+	}
+	cfg.OpenCodeServerPass = openCodeServerPass.Reveal()
+
+	return nil
+}
+
+// watchRelayModeOverrides applies every "RELAY_MODE" change config.Manager
+// publishes to the running relay, so an admin's "/config set RELAY_MODE
+// stream" takes effect without a restart.
+func watchRelayModeOverrides(ctx context.Context, manager *config.Manager, relay *service.RelayService) {
+	ch := manager.Subscribe("RELAY_MODE")
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case mode := <-ch:
+			relay.SetMode(mode)
+		}
+	}
+}
+
+// watchLogLevelOverrides applies every "LOG_LEVEL" change config.Manager
+// publishes to the running logger's level, reusing logging.ParseLevel so the
+// mapping stays identical to the one LoadFromEnv applied at startup.
+func watchLogLevelOverrides(ctx context.Context, manager *config.Manager, level *slog.LevelVar, logger *slog.Logger) {
+	ch := manager.Subscribe("LOG_LEVEL")
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case raw := <-ch:
+			level.Set(logging.ParseLevel(raw))
+			logger.Info("log level updated", "log_level", raw)
+		}
+	}
+}
+
+// watchAllowedUserIDsOverrides re-seeds the allow-list every time
+// config.Manager publishes an "ALLOWED_USER_IDS" change. Like the startup
+// SeedFromConfig call, this only ever adds users; removing someone from the
+// list doesn't revoke access already granted (use /deny for that).
+func watchAllowedUserIDsOverrides(ctx context.Context, manager *config.Manager, store ports.AuthzRepository, logger *slog.Logger) {
+	ch := manager.Subscribe("ALLOWED_USER_IDS")
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case raw := <-ch:
+			ids, err := config.ParseInt64List(raw)
+			if err != nil {
+				logger.Error("ignoring malformed ALLOWED_USER_IDS override", "error", err)
+				continue
+			}
+			for _, userID := range ids {
+				if err := store.UpsertAllowed(ctx, userID); err != nil {
+					logger.Error("seed allowed user from override failed", "error", err, "user_id", userID)
+				}
+			}
+		}
+	}
+}
+
+// watchOpenCodeTimeoutOverrides applies every "OPENCODE_TIMEOUT_MS" change
+// config.Manager publishes to the running opencode client. The value has
+// already passed config.Manager's own validation, so a parse failure here
+// would mean the two have drifted; log and skip rather than panic.
+func watchOpenCodeTimeoutOverrides(ctx context.Context, manager *config.Manager, opencodeClient *opencode.Client, logger *slog.Logger) {
+	ch := manager.Subscribe("OPENCODE_TIMEOUT_MS")
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case raw := <-ch:
+			ms, err := strconv.Atoi(raw)
+			if err != nil {
+				logger.Error("ignoring malformed OPENCODE_TIMEOUT_MS override", "error", err)
+				continue
+			}
+			opencodeClient.SetTimeout(time.Duration(ms) * time.Millisecond)
+		}
+	}
+}
+
 func runMigrate() error {
 	cfg, err := config.LoadFromEnv()
 	if err != nil {
@@ -246,6 +584,223 @@ func runMigrate() error {
 	return nil
 }
 
+// runMigrateEncrypt re-encrypts every session_links/session_models row in
+// place using the currently configured STORAGE_ENCRYPTION_PASSPHRASE. It's
+// meant for operators turning encryption on against an existing plaintext
+// database; rows already encrypted under the current key are left untouched.
+func runMigrateEncrypt() error {
+	cfg, err := config.LoadFromEnv()
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(cfg.StorageEncryptionPassphrase) == "" {
+		return errors.New("STORAGE_ENCRYPTION_PASSPHRASE must be set to run migrate-encrypt")
+	}
+
+	store, err := storage.Open(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if err := store.Migrate(context.Background()); err != nil {
+		return err
+	}
+
+	count, err := store.MigrateEncrypt(context.Background())
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("migrate-encrypt complete: re-encrypted %d row(s)\n", count)
+	return nil
+}
+
+// runRotateSecrets re-encrypts every row in encrypted_secrets under a new
+// SECRETS_KEY read from -new-key, so operators can rotate the master key
+// without losing the bot token/OpenCode password already provisioned in
+// the store.
+func runRotateSecrets(args []string) error {
+	fs := flag.NewFlagSet("rotate-secrets", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	newKey := fs.String("new-key", "", "new SECRETS_KEY to re-encrypt every stored secret under")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if strings.TrimSpace(*newKey) == "" {
+		return errors.New("rotate-secrets requires -new-key")
+	}
+
+	cfg, err := config.LoadFromEnv()
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(cfg.SecretsKey) == "" {
+		return errors.New("SECRETS_KEY must be set to run rotate-secrets")
+	}
+
+	store, err := storage.Open(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if err := store.Migrate(context.Background()); err != nil {
+		return err
+	}
+
+	count, err := store.RotateSecrets(context.Background(), *newKey)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("rotate-secrets complete: re-encrypted %d secret(s); update SECRETS_KEY to the new value\n", count)
+	return nil
+}
+
+// runRekey re-encrypts every session_links/session_models/prompt_history row
+// under a new STORAGE_ENCRYPTION_PASSPHRASE read from -new-key, the
+// encrypted-at-rest counterpart to rotate-secrets: that command rotates the
+// key protecting the bot token and OpenCode password, this one rotates the
+// key protecting session IDs, prompts, and per-session model overrides.
+func runRekey(args []string) error {
+	fs := flag.NewFlagSet("rekey", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	newKey := fs.String("new-key", "", "new STORAGE_ENCRYPTION_PASSPHRASE to re-encrypt stored session data under")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if strings.TrimSpace(*newKey) == "" {
+		return errors.New("rekey requires -new-key")
+	}
+
+	cfg, err := config.LoadFromEnv()
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(cfg.StorageEncryptionPassphrase) == "" {
+		return errors.New("STORAGE_ENCRYPTION_PASSPHRASE must be set to run rekey")
+	}
+
+	store, err := storage.Open(cfg)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	if err := store.Migrate(context.Background()); err != nil {
+		return err
+	}
+
+	count, skipped, err := store.RotateStorageEncryption(context.Background(), *newKey)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("rekey complete: re-encrypted %d row(s); update STORAGE_ENCRYPTION_PASSPHRASE to the new value\n", count)
+	if skipped > 0 {
+		fmt.Printf("warning: %d session_models row(s) had no matching session_links entry and were left under the old key; they are now unreachable\n", skipped)
+	}
+	return nil
+}
+
+// runDoctor runs app.RunDoctor's end-to-end preflight and prints it as a
+// per-check pass/fail report, or as JSON with -json for scripting. Exits
+// non-zero (via the returned error) when any check fails, so it plugs into
+// orchestrator healthchecks the same way golangci-lint/govulncheck plug
+// into CI: non-zero means act on it.
+func runDoctor(args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	asJSON := fs.Bool("json", false, "print the report as JSON instead of text")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	cfg, err := config.LoadFromEnv()
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	report := app.RunDoctor(ctx, cfg)
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		for _, check := range report.Checks {
+			status := "PASS"
+			if !check.OK {
+				status = "FAIL"
+			}
+			line := fmt.Sprintf("[%s] %s", status, check.Name)
+			if check.Detail != "" {
+				line += ": " + check.Detail
+			}
+			fmt.Println(line)
+			if !check.OK && check.Hint != "" {
+				fmt.Println("  hint: " + check.Hint)
+			}
+		}
+	}
+
+	if !report.OK {
+		return errors.New("doctor: one or more checks failed")
+	}
+	fmt.Println("all checks passed")
+	return nil
+}
+
+// runReload asks a bridge process already running on this host to drain and
+// exit gracefully, by POSTing to its own /command/reload over localhost.
+// It's the CLI counterpart to sending SIGTERM/SIGUSR2 directly, for deploy
+// scripts that only have network access to the bridge (e.g. across a
+// container boundary without a shared PID namespace).
+func runReload(args []string) error {
+	fs := flag.NewFlagSet("reload", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := config.LoadFromEnv()
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+
+	url := fmt.Sprintf("http://127.0.0.1:%d/command/reload", cfg.HealthPort)
+	body := []byte("{}")
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(cfg.ControlAuthSecrets) > 0 {
+		random, checksum, err := app.SignRequest(cfg.ControlAuthSecrets[0], body)
+		if err != nil {
+			return fmt.Errorf("sign reload request: %w", err)
+		}
+		req.Header.Set("X-Bridge-Random", random)
+		req.Header.Set("X-Bridge-Checksum", checksum)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("reload request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("reload request failed: %s: %s", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+	fmt.Println("reload triggered; the bridge will drain in-flight updates and exit")
+	return nil
+}
+
 func runImportJSON() error {
 	cfg, err := config.LoadFromEnv()
 	if err != nil {
@@ -290,8 +845,13 @@ func runBootstrap(args []string) error {
 		"ADMIN_USER_IDS=" + joinInt64(cfg.AdminUserIDs),
 		"ALLOWED_USER_IDS=" + joinInt64(cfg.AllowedUserIDs),
 		"BOT_TRANSPORT=" + cfg.BotTransport,
+		"TELEGRAM_TRANSPORT=" + cfg.TelegramTransport,
+		"TELEGRAM_API_ID=" + strconv.Itoa(cfg.TelegramAPIID),
+		"TELEGRAM_API_HASH=" + cfg.TelegramAPIHash,
 		"WEBHOOK_URL=" + cfg.WebhookURL,
 		"WEBHOOK_LISTEN_ADDR=" + cfg.WebhookListenAddr,
+		"WEBHOOK_SECRET_TOKEN=" + cfg.WebhookSecretToken,
+		"WEBHOOK_TRUSTED_PROXY_CIDRS=" + strings.Join(cfg.WebhookTrustedProxyCIDRs, ","),
 		"BOT_POLLING_INTERVAL_SECONDS=" + strconv.Itoa(cfg.BotPollingIntervalS),
 		"DATA_DIR=" + cfg.DataDir,
 		"OPENCODE_SERVER_URL=" + cfg.OpenCodeServerURL,
@@ -311,7 +871,21 @@ func runBootstrap(args []string) error {
 		"CONTROL_WEB_SERVER=" + strconv.FormatBool(cfg.ControlWebServer),
 		"CONTROL_SOCKET_PATH=" + cfg.ControlSocketPath,
 		"HEALTH_PORT=" + strconv.Itoa(cfg.HealthPort),
+		"METRICS_ENABLED=" + strconv.FormatBool(cfg.MetricsEnabled),
+		"METRICS_PATH=" + cfg.MetricsPath,
+		"EVENT_BUS=" + cfg.EventBusBackend,
+		"NATS_URL=" + cfg.NATSURL,
+		"REPLICA_ID=" + cfg.ReplicaID,
+		"RELAY_LEASE_TTL_SECONDS=" + strconv.Itoa(cfg.RelayLeaseTTLSeconds),
+		"HEALTH_TRUSTED_PROXY_CIDRS=" + strings.Join(cfg.HealthTrustedProxyCIDRs, ","),
+		"HEALTH_RATE_LIMIT_PER_MINUTE=" + strconv.Itoa(cfg.HealthRateLimitPerMinute),
+		"HEALTH_RATE_LIMIT_BURST=" + strconv.Itoa(cfg.HealthRateLimitBurst),
+		"RELAY_MAX_TEXT_BYTES=" + strconv.Itoa(cfg.RelayMaxTextBytes),
 		"LOG_LEVEL=" + cfg.LogLevel,
+		"TGBRIDGE_IDLE_TIMEOUT=" + strconv.Itoa(cfg.IdleTimeoutSeconds),
+		"SHUTDOWN_DRAIN_TIMEOUT_SECONDS=" + strconv.Itoa(cfg.ShutdownDrainTimeoutSeconds),
+		"RESOLVER_BACKEND=" + strings.Join(cfg.ResolverBackends, ","),
+		"RESOLVER_DIRECTORY_PATH=" + cfg.ResolverDirectoryPath,
 	}
 
 	content := strings.Join(lines, "\n") + "\n"
@@ -360,8 +934,18 @@ func runResolve(args []string) error {
 		return err
 	}
 
-	resolver := telegram.NewResolver(cfg.BotToken, cfg.OpenCodeTimeout)
-	resolveService := service.NewResolveService(resolver, store)
+	resolverBackends, err := buildResolverBackends(cfg, nil)
+	if err != nil {
+		return fmt.Errorf("resolver backends: %w", err)
+	}
+	resolveService := service.NewResolveService(
+		resolverBackends,
+		store,
+		store,
+		time.Duration(cfg.UsernameCacheTTLSeconds)*time.Second,
+		time.Duration(cfg.UsernameNegativeCacheTTLSeconds)*time.Second,
+		cfg.UsernameResolveConcurrency,
+	)
 	usernames := splitUsernames(usernamesValue)
 	result := resolveService.ResolveAndPersist(context.Background(), usernames)
 
@@ -372,6 +956,9 @@ func runResolve(args []string) error {
 		fmt.Printf("unresolved %s: %s\n", unresolved.Username, unresolved.Reason)
 	}
 
+	// ResolveService.resolveViaBackends only reports a username unresolved
+	// once every configured RESOLVER_BACKEND has failed on it, so this
+	// epilogue never fires just because one backend in the chain missed.
 	if len(result.Unresolved) > 0 {
 		fmt.Println("manual steps:")
 		fmt.Println("1) Ask user to message the bot")
@@ -0,0 +1,42 @@
+// Package activation provides a net.Listener factory that detects systemd
+// socket activation (sd_listen_fds(3)) so the bridge can be started on
+// demand instead of binding its own port at process startup.
+package activation
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// Listener returns the socket systemd passed via LISTEN_FDS/LISTEN_PID when
+// it targets this process, falling back to a normal TCP listen on addr
+// otherwise.
+func Listener(addr string) (net.Listener, error) {
+	if fd, ok := activatedFD(); ok {
+		file := os.NewFile(fd, "systemd-socket")
+		listener, err := net.FileListener(file)
+		if err != nil {
+			return nil, fmt.Errorf("activation: use systemd socket: %w", err)
+		}
+		return listener, nil
+	}
+	return net.Listen("tcp", addr)
+}
+
+// activatedFD reports the first file descriptor systemd passed to this
+// process, if any. Sockets passed by socket activation start at fd 3 (0, 1,
+// and 2 are reserved for stdio); only the first is used since the bridge
+// listens on a single address.
+func activatedFD() (uintptr, bool) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return 0, false
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count < 1 {
+		return 0, false
+	}
+	return 3, true
+}
@@ -0,0 +1,93 @@
+package app
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// clientIP resolves the real client address for r. When RemoteAddr falls
+// inside s.trustedProxies, it prefers X-Real-Ip, then walks X-Forwarded-For
+// from right to left and returns the first entry that isn't itself a
+// trusted proxy. This differs from telegram.VerifyWebhookRequest, which
+// walks X-Forwarded-For first and falls back to X-Real-IP only when every
+// forwarded hop is trusted; this one is also keyed off the health server's
+// own trusted-proxy list, since it may sit behind a different edge. Otherwise RemoteAddr is
+// trusted as-is.
+func (s *HealthServer) clientIP(r *http.Request) netip.Addr {
+	remote := remoteAddrIP(r.RemoteAddr)
+	if len(s.trustedProxies) == 0 || !remote.IsValid() || !addrInPrefixes(remote, s.trustedProxies) {
+		return remote
+	}
+
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-Ip")); realIP != "" {
+		if addr, err := netip.ParseAddr(realIP); err == nil {
+			return addr
+		}
+	}
+
+	chain := forwardedForChain(r)
+	for i := len(chain) - 1; i >= 0; i-- {
+		if !addrInPrefixes(chain[i], s.trustedProxies) {
+			return chain[i]
+		}
+	}
+	return remote
+}
+
+func remoteAddrIP(remoteAddr string) netip.Addr {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	addr, err := netip.ParseAddr(strings.TrimSpace(host))
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr
+}
+
+// forwardedForChain parses X-Forwarded-For in the order the header lists
+// hops: left is the original client, right is the nearest proxy.
+func forwardedForChain(r *http.Request) []netip.Addr {
+	xff := r.Header.Get("X-Forwarded-For")
+	if xff == "" {
+		return nil
+	}
+	parts := strings.Split(xff, ",")
+	chain := make([]netip.Addr, 0, len(parts))
+	for _, part := range parts {
+		addr, err := netip.ParseAddr(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		chain = append(chain, addr)
+	}
+	return chain
+}
+
+func addrInPrefixes(addr netip.Addr, prefixes []netip.Prefix) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseTrustedProxies(raw []string) ([]netip.Prefix, error) {
+	out := make([]netip.Prefix, 0, len(raw))
+	for _, item := range raw {
+		trimmed := strings.TrimSpace(item)
+		if trimmed == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(trimmed)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, prefix)
+	}
+	return out, nil
+}
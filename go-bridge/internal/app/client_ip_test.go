@@ -0,0 +1,64 @@
+package app
+
+import (
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestClientIPUsesRightmostUntrustedForwardedForHop(t *testing.T) {
+	trusted, err := parseTrustedProxies([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("parseTrustedProxies failed: %v", err)
+	}
+	server := &HealthServer{trustedProxies: trusted}
+
+	r := httptest.NewRequest("GET", "/command/status", nil)
+	r.RemoteAddr = "10.0.0.5:12345"
+	r.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.2")
+
+	got := server.clientIP(r)
+	want := netip.MustParseAddr("203.0.113.7")
+	if got != want {
+		t.Fatalf("expected %s, got %s", want, got)
+	}
+}
+
+func TestClientIPIgnoresForwardedForFromUntrustedRemote(t *testing.T) {
+	trusted, err := parseTrustedProxies([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("parseTrustedProxies failed: %v", err)
+	}
+	server := &HealthServer{trustedProxies: trusted}
+
+	r := httptest.NewRequest("GET", "/command/status", nil)
+	r.RemoteAddr = "203.0.113.99:443"
+	// A hostile client can set whatever X-Forwarded-For it wants when it
+	// isn't even talking through a trusted proxy; this must be ignored.
+	r.Header.Set("X-Forwarded-For", "1.2.3.4")
+
+	got := server.clientIP(r)
+	want := netip.MustParseAddr("203.0.113.99")
+	if got != want {
+		t.Fatalf("expected remote addr %s to win over spoofed header, got %s", want, got)
+	}
+}
+
+func TestClientIPPrefersXRealIPOverForwardedForWhenTrusted(t *testing.T) {
+	trusted, err := parseTrustedProxies([]string{"10.0.0.0/8"})
+	if err != nil {
+		t.Fatalf("parseTrustedProxies failed: %v", err)
+	}
+	server := &HealthServer{trustedProxies: trusted}
+
+	r := httptest.NewRequest("GET", "/command/status", nil)
+	r.RemoteAddr = "10.0.0.5:12345"
+	r.Header.Set("X-Real-Ip", "198.51.100.23")
+	r.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.2")
+
+	got := server.clientIP(r)
+	want := netip.MustParseAddr("198.51.100.23")
+	if got != want {
+		t.Fatalf("expected X-Real-Ip %s to win, got %s", want, got)
+	}
+}
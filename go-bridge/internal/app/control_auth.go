@@ -0,0 +1,146 @@
+package app
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	randomHeader    = "X-Bridge-Random"
+	checksumHeader  = "X-Bridge-Checksum"
+	minRandomBytes  = 16
+	replayWindow    = 5 * time.Minute
+	signedRandomLen = 32
+)
+
+// controlAuth verifies the shared-secret HMAC scheme used to protect
+// /command/* (and optionally /resolve): callers send an X-Bridge-Random
+// header (hex, >=16 bytes) and an X-Bridge-Checksum header equal to
+// hex(HMAC_SHA256(secret, random||rawBody)). Multiple secrets may be
+// configured at once so operators can rotate without downtime, and a short
+// LRU-style replay window rejects a random/checksum pair seen before.
+type controlAuth struct {
+	secrets [][]byte
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+func newControlAuth(secrets []string) *controlAuth {
+	keys := make([][]byte, 0, len(secrets))
+	for _, secret := range secrets {
+		trimmed := strings.TrimSpace(secret)
+		if trimmed == "" {
+			continue
+		}
+		keys = append(keys, []byte(trimmed))
+	}
+	return &controlAuth{secrets: keys, seen: make(map[string]time.Time)}
+}
+
+// enabled reports whether CONTROL_AUTH_SECRETS was configured; when it's
+// empty, middleware is a no-op so existing deployments keep working.
+func (a *controlAuth) enabled() bool {
+	return len(a.secrets) > 0
+}
+
+func (a *controlAuth) verify(r *http.Request, body []byte) error {
+	randomHex := strings.TrimSpace(r.Header.Get(randomHeader))
+	checksum := strings.ToLower(strings.TrimSpace(r.Header.Get(checksumHeader)))
+	if randomHex == "" || checksum == "" {
+		return errors.New("missing signature headers")
+	}
+
+	random, err := hex.DecodeString(randomHex)
+	if err != nil {
+		return errors.New("invalid random header")
+	}
+	if len(random) < minRandomBytes {
+		return errors.New("random header too short")
+	}
+
+	matched := false
+	for _, secret := range a.secrets {
+		expected := computeChecksum(secret, random, body)
+		if hmac.Equal([]byte(expected), []byte(checksum)) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return errors.New("checksum mismatch")
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.evictExpiredLocked()
+	key := randomHex + ":" + checksum
+	if _, ok := a.seen[key]; ok {
+		return errors.New("replayed request")
+	}
+	a.seen[key] = time.Now()
+	return nil
+}
+
+func (a *controlAuth) evictExpiredLocked() {
+	cutoff := time.Now().Add(-replayWindow)
+	for key, seenAt := range a.seen {
+		if seenAt.Before(cutoff) {
+			delete(a.seen, key)
+		}
+	}
+}
+
+// middleware rejects the request with 401 unless it carries a valid,
+// not-yet-seen signature. It reads and restores r.Body so the wrapped
+// handler can still decode it as JSON.
+func (a *controlAuth) middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !a.enabled() {
+			next(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "invalid body", http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		if err := a.verify(r, body); err != nil {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func computeChecksum(secret []byte, random []byte, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(random)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignRequest is a client helper for front-ends (e.g. the Telegram bot
+// process calling back into the control API): it generates a fresh random
+// value and returns the X-Bridge-Random/X-Bridge-Checksum header values to
+// attach to a request carrying body.
+func SignRequest(secret string, body []byte) (randomHeaderValue string, checksumHeaderValue string, err error) {
+	random := make([]byte, signedRandomLen)
+	if _, err := rand.Read(random); err != nil {
+		return "", "", fmt.Errorf("generate random: %w", err)
+	}
+	return hex.EncodeToString(random), computeChecksum([]byte(secret), random, body), nil
+}
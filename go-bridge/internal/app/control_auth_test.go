@@ -0,0 +1,149 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func signedRequest(t *testing.T, secret string, body []byte) (randomHex, checksum string) {
+	t.Helper()
+	randomHex, checksum, err := SignRequest(secret, body)
+	if err != nil {
+		t.Fatalf("SignRequest: %v", err)
+	}
+	return randomHex, checksum
+}
+
+func TestControlAuthAcceptsValidSignature(t *testing.T) {
+	auth := newControlAuth([]string{"s3cret"})
+	body := []byte(`{"action":"reload"}`)
+	randomHex, checksum := signedRequest(t, "s3cret", body)
+
+	r := httptest.NewRequest("POST", "/command/reload", nil)
+	r.Header.Set(randomHeader, randomHex)
+	r.Header.Set(checksumHeader, checksum)
+
+	if err := auth.verify(r, body); err != nil {
+		t.Fatalf("expected valid signature to verify, got %v", err)
+	}
+}
+
+func TestControlAuthRejectsWrongSecret(t *testing.T) {
+	auth := newControlAuth([]string{"s3cret"})
+	body := []byte(`{"action":"reload"}`)
+	randomHex, checksum := signedRequest(t, "wrong-secret", body)
+
+	r := httptest.NewRequest("POST", "/command/reload", nil)
+	r.Header.Set(randomHeader, randomHex)
+	r.Header.Set(checksumHeader, checksum)
+
+	if err := auth.verify(r, body); err == nil {
+		t.Fatalf("expected signature under the wrong secret to be rejected")
+	}
+}
+
+func TestControlAuthRejectsTamperedBody(t *testing.T) {
+	auth := newControlAuth([]string{"s3cret"})
+	randomHex, checksum := signedRequest(t, "s3cret", []byte(`{"action":"reload"}`))
+
+	r := httptest.NewRequest("POST", "/command/reload", nil)
+	r.Header.Set(randomHeader, randomHex)
+	r.Header.Set(checksumHeader, checksum)
+
+	if err := auth.verify(r, []byte(`{"action":"shutdown"}`)); err == nil {
+		t.Fatalf("expected checksum computed over a different body to be rejected")
+	}
+}
+
+func TestControlAuthRejectsMissingHeaders(t *testing.T) {
+	auth := newControlAuth([]string{"s3cret"})
+	r := httptest.NewRequest("POST", "/command/reload", nil)
+	if err := auth.verify(r, []byte("{}")); err == nil {
+		t.Fatalf("expected request with no signature headers to be rejected")
+	}
+}
+
+func TestControlAuthRejectsShortRandom(t *testing.T) {
+	auth := newControlAuth([]string{"s3cret"})
+	body := []byte("{}")
+	r := httptest.NewRequest("POST", "/command/reload", nil)
+	r.Header.Set(randomHeader, "ab")
+	r.Header.Set(checksumHeader, computeChecksum([]byte("s3cret"), []byte{0xab}, body))
+	if err := auth.verify(r, body); err == nil {
+		t.Fatalf("expected a random header shorter than minRandomBytes to be rejected")
+	}
+}
+
+func TestControlAuthRejectsReplayedRequest(t *testing.T) {
+	auth := newControlAuth([]string{"s3cret"})
+	body := []byte(`{"action":"reload"}`)
+	randomHex, checksum := signedRequest(t, "s3cret", body)
+
+	first := httptest.NewRequest("POST", "/command/reload", nil)
+	first.Header.Set(randomHeader, randomHex)
+	first.Header.Set(checksumHeader, checksum)
+	if err := auth.verify(first, body); err != nil {
+		t.Fatalf("expected first use of the signature to verify, got %v", err)
+	}
+
+	second := httptest.NewRequest("POST", "/command/reload", nil)
+	second.Header.Set(randomHeader, randomHex)
+	second.Header.Set(checksumHeader, checksum)
+	if err := auth.verify(second, body); err == nil {
+		t.Fatalf("expected replaying the same random/checksum pair to be rejected")
+	}
+}
+
+func TestControlAuthAcceptsOldSecretDuringRotationOverlap(t *testing.T) {
+	auth := newControlAuth([]string{"new-secret", "old-secret"})
+	body := []byte(`{"action":"reload"}`)
+
+	oldRandomHex, oldChecksum := signedRequest(t, "old-secret", body)
+	r := httptest.NewRequest("POST", "/command/reload", nil)
+	r.Header.Set(randomHeader, oldRandomHex)
+	r.Header.Set(checksumHeader, oldChecksum)
+	if err := auth.verify(r, body); err != nil {
+		t.Fatalf("expected a request signed with the old secret to still verify during rotation overlap, got %v", err)
+	}
+
+	newRandomHex, newChecksum := signedRequest(t, "new-secret", body)
+	r2 := httptest.NewRequest("POST", "/command/reload", nil)
+	r2.Header.Set(randomHeader, newRandomHex)
+	r2.Header.Set(checksumHeader, newChecksum)
+	if err := auth.verify(r2, body); err != nil {
+		t.Fatalf("expected a request signed with the new secret to verify, got %v", err)
+	}
+}
+
+func TestControlAuthEnabledReflectsConfiguredSecrets(t *testing.T) {
+	if (&controlAuth{}).enabled() {
+		t.Fatalf("expected zero-value controlAuth to report disabled")
+	}
+	if !newControlAuth([]string{"s3cret"}).enabled() {
+		t.Fatalf("expected controlAuth with a secret to report enabled")
+	}
+	if newControlAuth([]string{"  ", ""}).enabled() {
+		t.Fatalf("expected blank/whitespace secrets to be ignored")
+	}
+}
+
+func TestControlAuthMiddlewareRejectsWithoutCallingNext(t *testing.T) {
+	auth := newControlAuth([]string{"s3cret"})
+	called := false
+	handler := auth.middleware(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})
+
+	r := httptest.NewRequest("POST", "/command/reload", strings.NewReader("{}"))
+	w := httptest.NewRecorder()
+	handler(w, r)
+
+	if called {
+		t.Fatalf("expected next handler not to run without a valid signature")
+	}
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", w.Code)
+	}
+}
@@ -0,0 +1,165 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/config"
+	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/opencode"
+	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/storage"
+	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/telegram"
+)
+
+// DoctorCheck is the pass/fail result of one doctor preflight check, with an
+// operator-facing hint for what to do when it fails.
+type DoctorCheck struct {
+	Name   string `json:"name"`
+	OK     bool   `json:"ok"`
+	Detail string `json:"detail,omitempty"`
+	Hint   string `json:"hint,omitempty"`
+}
+
+// DoctorReport is the full preflight result returned by both `bridge doctor`
+// and GET /doctor: every check plus an overall pass/fail.
+type DoctorReport struct {
+	OK     bool          `json:"ok"`
+	Checks []DoctorCheck `json:"checks"`
+}
+
+// RunDoctor runs the bridge's end-to-end preflight: required config fields,
+// OpenCode auth/reachability, the Bot API token, webhook delivery health
+// (skipped for polling/tdlib transports, which have no webhook to check),
+// the store's migration state, and the control socket's file permissions.
+// cfg is assumed already loaded; config.LoadFromEnv failing is itself a
+// preflight failure the caller reports before RunDoctor is ever invoked.
+func RunDoctor(ctx context.Context, cfg config.Config) DoctorReport {
+	checks := []DoctorCheck{doctorCheckConfig(cfg)}
+	checks = append(checks, doctorCheckOpenCode(ctx, cfg), doctorCheckTelegram(ctx, cfg))
+	if cfg.BotTransport != "polling" && cfg.BotTransport != "tdlib" {
+		checks = append(checks, doctorCheckWebhook(ctx, cfg))
+	}
+	checks = append(checks, doctorCheckStore(ctx, cfg), doctorCheckControlSocket(cfg))
+
+	ok := true
+	for _, c := range checks {
+		if !c.OK {
+			ok = false
+			break
+		}
+	}
+	return DoctorReport{OK: ok, Checks: checks}
+}
+
+func doctorCheckConfig(cfg config.Config) DoctorCheck {
+	var missing []string
+	if strings.TrimSpace(cfg.BotToken) == "" {
+		missing = append(missing, "BOT_TOKEN")
+	}
+	if strings.TrimSpace(cfg.OpenCodeServerURL) == "" {
+		missing = append(missing, "OPENCODE_SERVER_URL")
+	}
+	if len(missing) > 0 {
+		return DoctorCheck{
+			Name: "config", OK: false, Detail: "missing " + strings.Join(missing, ", "),
+			Hint: "set these in the environment, or run `bridge bootstrap` to write a starter .env",
+		}
+	}
+	return DoctorCheck{Name: "config", OK: true}
+}
+
+func doctorCheckOpenCode(ctx context.Context, cfg config.Config) DoctorCheck {
+	if err := opencode.CheckConnectivity(ctx, cfg); err != nil {
+		return DoctorCheck{
+			Name: "opencode", OK: false, Detail: err.Error(),
+			Hint: "check OPENCODE_SERVER_URL/OPENCODE_SERVER_USERNAME/OPENCODE_SERVER_PASSWORD and that the server is reachable from this host",
+		}
+	}
+	return DoctorCheck{Name: "opencode", OK: true}
+}
+
+func doctorCheckTelegram(ctx context.Context, cfg config.Config) DoctorCheck {
+	if err := telegram.CheckConnectivity(ctx, cfg.BotToken, cfg.OpenCodeTimeout); err != nil {
+		return DoctorCheck{
+			Name: "telegram", OK: false, Detail: err.Error(),
+			Hint: "check BOT_TOKEN is valid and this host can reach api.telegram.org",
+		}
+	}
+	return DoctorCheck{Name: "telegram", OK: true}
+}
+
+func doctorCheckWebhook(ctx context.Context, cfg config.Config) DoctorCheck {
+	api := telegram.NewAPI(cfg.BotToken, cfg.OpenCodeTimeout, 0)
+	info, err := api.GetWebhookInfo(ctx)
+	if err != nil {
+		return DoctorCheck{
+			Name: "webhook", OK: false, Detail: err.Error(),
+			Hint: "check BOT_TOKEN is valid and this host can reach api.telegram.org",
+		}
+	}
+	if info.URL == "" {
+		return DoctorCheck{
+			Name: "webhook", OK: false, Detail: "no webhook registered with Telegram",
+			Hint: "start the bridge once with BOT_TRANSPORT=webhook so it calls setWebhook, or switch to BOT_TRANSPORT=polling",
+		}
+	}
+	if info.LastErrorMessage != "" {
+		return DoctorCheck{
+			Name: "webhook", OK: false,
+			Detail: fmt.Sprintf("telegram last failed delivering to %s: %s", info.URL, info.LastErrorMessage),
+			Hint:   "check WEBHOOK_URL is publicly reachable over HTTPS and WEBHOOK_SECRET_TOKEN matches",
+		}
+	}
+	return DoctorCheck{Name: "webhook", OK: true, Detail: fmt.Sprintf("%s (pending=%d)", info.URL, info.PendingUpdateCount)}
+}
+
+func doctorCheckStore(ctx context.Context, cfg config.Config) DoctorCheck {
+	store, err := storage.Open(cfg)
+	if err != nil {
+		return DoctorCheck{
+			Name: "store", OK: false, Detail: err.Error(),
+			Hint: "check DATA_DIR/DATABASE_URL point at a writable path",
+		}
+	}
+	defer store.Close()
+	if err := store.Migrate(ctx); err != nil {
+		return DoctorCheck{
+			Name: "store", OK: false, Detail: err.Error(),
+			Hint: "check the database file isn't locked by another process and DATA_DIR is writable",
+		}
+	}
+	return DoctorCheck{Name: "store", OK: true}
+}
+
+// doctorCheckControlSocket reports whether CONTROL_SOCKET_PATH, if set,
+// exists with permissions that don't let other local users reach it; the
+// control endpoint accepts admin-equivalent commands so a world-readable
+// socket file would be a local privilege escalation.
+func doctorCheckControlSocket(cfg config.Config) DoctorCheck {
+	if cfg.ControlWebServer {
+		return DoctorCheck{Name: "control_socket", OK: true, Detail: "CONTROL_WEB_SERVER=true, no socket file to check"}
+	}
+	path := strings.TrimSpace(cfg.ControlSocketPath)
+	if path == "" {
+		return DoctorCheck{Name: "control_socket", OK: true, Detail: "CONTROL_SOCKET_PATH not set"}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return DoctorCheck{Name: "control_socket", OK: true, Detail: "no socket file at this path"}
+		}
+		return DoctorCheck{
+			Name: "control_socket", OK: false, Detail: err.Error(),
+			Hint: "check CONTROL_SOCKET_PATH's parent directory is accessible",
+		}
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		return DoctorCheck{
+			Name: "control_socket", OK: false,
+			Detail: fmt.Sprintf("%s is mode %o", path, info.Mode().Perm()),
+			Hint:   "chmod 600 the control socket; it accepts admin-equivalent control commands",
+		}
+	}
+	return DoctorCheck{Name: "control_socket", OK: true}
+}
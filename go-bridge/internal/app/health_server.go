@@ -7,12 +7,14 @@ import (
 	"fmt"
 	"log/slog"
 	"net/http"
+	"net/netip"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/config"
+	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/metrics"
 	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/opencode"
 	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/service"
 	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/telegram"
@@ -21,12 +23,17 @@ import (
 var ErrServerClosed = http.ErrServerClosed
 
 type HealthServer struct {
-	cfg        config.Config
-	logger     *slog.Logger
-	httpServer *http.Server
-	startedAt  time.Time
-	resolveFn  func(context.Context, []string) ResolveResponse
-	controlSvc *service.ControlService
+	cfg            config.Config
+	logger         *slog.Logger
+	httpServer     *http.Server
+	startedAt      time.Time
+	resolveFn      func(context.Context, []string) ResolveResponse
+	controlSvc     *service.ControlService
+	reloadFn       func()
+	auth           *controlAuth
+	registry       *metrics.Registry
+	trustedProxies []netip.Prefix
+	limiter        *ipRateLimiter
 }
 
 type ResolveResponse struct {
@@ -56,23 +63,48 @@ type healthResponse struct {
 	} `json:"relay"`
 }
 
-func NewHealthServer(cfg config.Config, logger *slog.Logger, resolveFn func(context.Context, []string) ResolveResponse) *HealthServer {
-	server := &HealthServer{cfg: cfg, logger: logger, startedAt: time.Now(), resolveFn: resolveFn}
+// NewHealthServer builds the health/control HTTP server. registry may be nil
+// to use the process-wide metrics.Default; passing a dedicated
+// *metrics.Registry lets tests assert on metric values without touching
+// global state.
+func NewHealthServer(cfg config.Config, logger *slog.Logger, resolveFn func(context.Context, []string) ResolveResponse, registry *metrics.Registry) *HealthServer {
+	trustedProxies, err := parseTrustedProxies(cfg.HealthTrustedProxyCIDRs)
+	if err != nil {
+		logger.Error("invalid HEALTH_TRUSTED_PROXY_CIDRS, ignoring", "error", err)
+		trustedProxies = nil
+	}
+	server := &HealthServer{
+		cfg:            cfg,
+		logger:         logger,
+		startedAt:      time.Now(),
+		resolveFn:      resolveFn,
+		auth:           newControlAuth(cfg.ControlAuthSecrets),
+		registry:       registry,
+		trustedProxies: trustedProxies,
+		limiter:        newIPRateLimiter(cfg.HealthRateLimitPerMinute, cfg.HealthRateLimitBurst),
+	}
+	guarded := func(next http.HandlerFunc) http.HandlerFunc {
+		return server.logged(server.rateLimited(server.auth.middleware(next)))
+	}
+
 	mux := http.NewServeMux()
-	mux.HandleFunc("/health", server.healthHandler)
-	mux.HandleFunc("/resolve", server.resolveHandler)
-	mux.HandleFunc("/command/status", server.commandStatusHandler)
-	mux.HandleFunc("/command/session/get", server.commandSessionGetHandler)
-	mux.HandleFunc("/command/session/list", server.commandSessionListHandler)
-	mux.HandleFunc("/command/session/use", server.commandSessionUseHandler)
-	mux.HandleFunc("/command/session/new", server.commandSessionNewHandler)
-	mux.HandleFunc("/command/models/list", server.commandModelsListHandler)
-	mux.HandleFunc("/command/models/set", server.commandModelsSetHandler)
-	mux.HandleFunc("/command/models/clear", server.commandModelsClearHandler)
-	mux.HandleFunc("/command/compact", server.commandCompactHandler)
-	mux.HandleFunc("/command/allow", server.commandAllowHandler)
-	mux.HandleFunc("/command/deny", server.commandDenyHandler)
-	mux.HandleFunc("/command/access/list", server.commandAccessListHandler)
+	mux.HandleFunc("/health", server.logged(server.healthHandler))
+	mux.HandleFunc("/doctor", guarded(server.doctorHandler))
+	mux.HandleFunc(cfg.MetricsPath, server.logged(server.metricsHandler))
+	mux.HandleFunc("/resolve", guarded(server.resolveHandler))
+	mux.HandleFunc("/command/status", guarded(server.commandStatusHandler))
+	mux.HandleFunc("/command/session/get", guarded(server.commandSessionGetHandler))
+	mux.HandleFunc("/command/session/list", guarded(server.commandSessionListHandler))
+	mux.HandleFunc("/command/session/use", guarded(server.commandSessionUseHandler))
+	mux.HandleFunc("/command/session/new", guarded(server.commandSessionNewHandler))
+	mux.HandleFunc("/command/models/list", guarded(server.commandModelsListHandler))
+	mux.HandleFunc("/command/models/set", guarded(server.commandModelsSetHandler))
+	mux.HandleFunc("/command/models/clear", guarded(server.commandModelsClearHandler))
+	mux.HandleFunc("/command/compact", guarded(server.commandCompactHandler))
+	mux.HandleFunc("/command/allow", guarded(server.commandAllowHandler))
+	mux.HandleFunc("/command/deny", guarded(server.commandDenyHandler))
+	mux.HandleFunc("/command/access/list", guarded(server.commandAccessListHandler))
+	mux.HandleFunc("/command/reload", guarded(server.commandReloadHandler))
 
 	server.httpServer = &http.Server{
 		Addr:              fmt.Sprintf(":%d", cfg.HealthPort),
@@ -86,6 +118,38 @@ func (s *HealthServer) SetControlService(control *service.ControlService) {
 	s.controlSvc = control
 }
 
+// SetReloadFn wires in the callback POST /command/reload triggers: runServe
+// passes its shutdown context's cancel func, so a reload request starts the
+// same graceful drain as SIGTERM/SIGUSR2 rather than a separate code path.
+func (s *HealthServer) SetReloadFn(reload func()) {
+	s.reloadFn = reload
+}
+
+// logged records the resolved client IP on every request, so logs reflect
+// the real caller once the bridge is exposed behind nginx/Caddy/Traefik
+// instead of the ingress's own address.
+func (s *HealthServer) logged(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		s.logger.Debug("health request", "method", r.Method, "path", r.URL.Path, "client_ip", s.clientIP(r))
+		next(w, r)
+	}
+}
+
+// rateLimited rejects requests once the caller's IP has exceeded its
+// token-bucket budget, returning 429 with Retry-After so well-behaved
+// clients know when to try again.
+func (s *HealthServer) rateLimited(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ip := s.clientIP(r)
+		if ok, retryAfter := s.limiter.allow(ip); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds()+1)))
+			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		next(w, r)
+	}
+}
+
 func (s *HealthServer) resolveHandler(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
@@ -149,7 +213,7 @@ func (s *HealthServer) commandSessionListHandler(w http.ResponseWriter, r *http.
 	if !ok {
 		return
 	}
-	list, err := s.controlSvc.SessionList(r.Context(), chatID, userID, 5)
+	list, err := s.controlSvc.SessionList(r.Context(), chatID, userID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
@@ -281,6 +345,24 @@ func (s *HealthServer) commandAccessListHandler(w http.ResponseWriter, r *http.R
 	s.writeJSON(w, http.StatusOK, list)
 }
 
+// commandReloadHandler triggers the same graceful drain-and-exit sequence as
+// SIGTERM/SIGUSR2, for operators who'd rather hit the control API than send
+// a signal (e.g. across a container boundary without a shared PID
+// namespace). It acks before the process actually starts draining, since the
+// drain itself can take up to SHUTDOWN_DRAIN_TIMEOUT_SECONDS.
+func (s *HealthServer) commandReloadHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.reloadFn == nil {
+		http.Error(w, "reload unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	s.writeJSON(w, http.StatusOK, map[string]string{"message": "graceful shutdown triggered"})
+	go s.reloadFn()
+}
+
 type chatUserPayload map[string]any
 
 func (p chatUserPayload) chatID() int64 {
@@ -418,6 +500,53 @@ func (s *HealthServer) healthHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// doctorHandler runs the same preflight `bridge doctor` does and reports it
+// as JSON, so orchestrator healthchecks can alert on a specific failing
+// check (e.g. webhook delivery) rather than just "unhealthy".
+func (s *HealthServer) doctorHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+	defer cancel()
+
+	report := RunDoctor(ctx, s.cfg)
+
+	w.Header().Set("Content-Type", "application/json")
+	if !report.OK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(report); err != nil {
+		s.logger.Error("encode doctor response failed", "error", err)
+	}
+}
+
+// metricsHandler exposes process counters (telegram_send_total and friends)
+// in Prometheus text exposition format so operators can scrape the bridge
+// when it's running in a degraded, rate-limited state.
+func (s *HealthServer) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.cfg.MetricsEnabled {
+		http.Error(w, "metrics disabled", http.StatusNotFound)
+		return
+	}
+	s.metricsRegistry().Handler().ServeHTTP(w, r)
+}
+
+// metricsRegistry returns the registry the server was constructed with, or
+// the process-wide default when none was supplied.
+func (s *HealthServer) metricsRegistry() *metrics.Registry {
+	if s.registry != nil {
+		return s.registry
+	}
+	return metrics.Default
+}
+
 func checkFromErr(err error) serviceCheck {
 	if err == nil {
 		return serviceCheck{OK: true}
@@ -0,0 +1,63 @@
+package app
+
+import (
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// ipRateLimiter is a non-blocking, per-IP token bucket: allow reports
+// whether a request from addr may proceed right now, refilling at
+// ratePerMinute/60 tokens per second up to burst. Unlike telegram's
+// tokenBucket (which blocks the caller until a token frees up), an HTTP
+// handler needs an immediate yes/no so it can return 429 with Retry-After.
+type ipRateLimiter struct {
+	ratePerSecond float64
+	burst         float64
+
+	mu      sync.Mutex
+	buckets map[netip.Addr]*ipBucket
+}
+
+type ipBucket struct {
+	tokens   float64
+	lastFill time.Time
+}
+
+func newIPRateLimiter(ratePerMinute int, burst int) *ipRateLimiter {
+	return &ipRateLimiter{
+		ratePerSecond: float64(ratePerMinute) / 60,
+		burst:         float64(burst),
+		buckets:       make(map[netip.Addr]*ipBucket),
+	}
+}
+
+// allow reports whether addr may proceed now and, if not, how long it should
+// wait before retrying.
+func (l *ipRateLimiter) allow(addr netip.Addr) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	bucket, ok := l.buckets[addr]
+	if !ok {
+		bucket = &ipBucket{tokens: l.burst, lastFill: time.Now()}
+		l.buckets[addr] = bucket
+	}
+
+	now := time.Now()
+	bucket.tokens = minFloat(l.burst, bucket.tokens+now.Sub(bucket.lastFill).Seconds()*l.ratePerSecond)
+	bucket.lastFill = now
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	wait := time.Duration((1 - bucket.tokens) / l.ratePerSecond * float64(time.Second))
+	return false, wait
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
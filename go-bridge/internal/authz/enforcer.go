@@ -0,0 +1,213 @@
+// Package authz implements the bridge's default ports.AuthzEnforcer: a
+// small Casbin-style RBAC matcher whose policy is loaded from a CSV file.
+// Policy rows ("p,subject,object,action") grant a subject (user:<id> or
+// chat:<id>) an action on an object (session:<id> or chat:<id>, optionally
+// wildcarded as "session:*"), and role rows ("g,parent,child") declare an
+// action hierarchy, e.g. admin implies write implies read, so a rule
+// granting a broader action also satisfies a check for a narrower one.
+package authz
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rule is one "p" row of the policy file: subject is granted action on
+// object.
+type rule struct {
+	subject string
+	object  string
+	action  string
+}
+
+// Enforcer is the default ports.AuthzEnforcer. It holds an in-memory policy
+// table loaded from a CSV file on disk, reloadable via Reload or the
+// background poller started by Watch, guarded by a mutex so a reload never
+// races a concurrent Enforce.
+type Enforcer struct {
+	path string
+
+	mu       sync.RWMutex
+	rules    []rule
+	inherits map[string]map[string]bool
+	modTime  time.Time
+}
+
+// New loads the policy at path and returns an Enforcer ready to use. A
+// missing file is treated as an empty (deny-all) policy rather than a
+// startup failure, so the bridge can come up before ops have written one.
+func New(path string) (*Enforcer, error) {
+	e := &Enforcer{path: path, inherits: map[string]map[string]bool{}}
+	if err := e.Reload(); err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return e, nil
+}
+
+// Reload re-reads the policy file from disk and atomically swaps it in.
+func (e *Enforcer) Reload() error {
+	rules, inherits, modTime, err := loadPolicy(e.path)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	e.rules = rules
+	e.inherits = inherits
+	e.modTime = modTime
+	e.mu.Unlock()
+	return nil
+}
+
+// Watch polls the policy file's modification time every interval and calls
+// Reload when it changes, so ops can adjust access rules without
+// restarting the bridge. It blocks until ctx is cancelled; callers run it
+// in its own goroutine.
+func (e *Enforcer) Watch(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			info, err := os.Stat(e.path)
+			if err != nil {
+				continue
+			}
+			e.mu.RLock()
+			unchanged := info.ModTime().Equal(e.modTime)
+			e.mu.RUnlock()
+			if unchanged {
+				continue
+			}
+			if err := e.Reload(); err != nil {
+				slog.Default().Warn("authz policy reload failed", "path", e.path, "error", err)
+				continue
+			}
+			slog.Default().Info("authz policy reloaded", "path", e.path)
+		}
+	}
+}
+
+// Enforce reports whether subject may perform action on object: a policy
+// rule must name subject exactly, match object (exactly or via a trailing
+// ":*" wildcard), and grant an action that is action itself or implies it
+// through the action hierarchy.
+func (e *Enforcer) Enforce(subject, object, action string) (bool, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	for _, r := range e.rules {
+		if r.subject != subject {
+			continue
+		}
+		if !matchObject(r.object, object) {
+			continue
+		}
+		if e.actionGrants(r.action, action) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// actionGrants reports whether granted implies requested, following the
+// action hierarchy declared by "g" rows transitively (e.g. admin -> write
+// -> read means a rule granting "admin" also satisfies a "read" check).
+func (e *Enforcer) actionGrants(granted, requested string) bool {
+	if granted == requested {
+		return true
+	}
+	seen := map[string]bool{granted: true}
+	queue := []string{granted}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for implied := range e.inherits[current] {
+			if implied == requested {
+				return true
+			}
+			if !seen[implied] {
+				seen[implied] = true
+				queue = append(queue, implied)
+			}
+		}
+	}
+	return false
+}
+
+// matchObject reports whether pattern (as written in the policy file)
+// matches object. A bare "*" matches anything, and a pattern ending in
+// ":*" (e.g. "session:*") matches any object sharing that prefix.
+func matchObject(pattern, object string) bool {
+	if pattern == object || pattern == "*" {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(pattern, ":*"); ok {
+		return strings.HasPrefix(object, prefix+":")
+	}
+	return false
+}
+
+// loadPolicy parses path as a Casbin-style CSV: "p,subject,object,action"
+// rows define grants and "g,parent,child" rows define the action hierarchy.
+// Blank lines and lines starting with "#" are ignored.
+func loadPolicy(path string) ([]rule, map[string]map[string]bool, time.Time, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, time.Time{}, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, nil, time.Time{}, err
+	}
+
+	var rules []rule
+	inherits := map[string]map[string]bool{}
+	scanner := bufio.NewScanner(file)
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, ",")
+		for i := range fields {
+			fields[i] = strings.TrimSpace(fields[i])
+		}
+		switch fields[0] {
+		case "p":
+			if len(fields) != 4 {
+				return nil, nil, time.Time{}, fmt.Errorf("authz policy %s:%d: want p,subject,object,action", path, lineNum)
+			}
+			rules = append(rules, rule{subject: fields[1], object: fields[2], action: fields[3]})
+		case "g":
+			if len(fields) != 3 {
+				return nil, nil, time.Time{}, fmt.Errorf("authz policy %s:%d: want g,parent,child", path, lineNum)
+			}
+			if inherits[fields[1]] == nil {
+				inherits[fields[1]] = map[string]bool{}
+			}
+			inherits[fields[1]][fields[2]] = true
+		default:
+			return nil, nil, time.Time{}, fmt.Errorf("authz policy %s:%d: unknown row type %q", path, lineNum, fields[0])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, time.Time{}, err
+	}
+	return rules, inherits, info.ModTime(), nil
+}
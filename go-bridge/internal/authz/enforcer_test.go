@@ -0,0 +1,156 @@
+package authz
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writePolicy(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "policy.csv")
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatalf("write policy: %v", err)
+	}
+	return path
+}
+
+func TestEnforceGrantsExactMatch(t *testing.T) {
+	path := writePolicy(t, "p,user:1,chat:100,write\n")
+	e, err := New(path)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	allowed, err := e.Enforce("user:1", "chat:100", "write")
+	if err != nil {
+		t.Fatalf("Enforce: %v", err)
+	}
+	if !allowed {
+		t.Fatalf("expected user:1 to be granted write on chat:100")
+	}
+}
+
+func TestEnforceDeniesUnknownSubject(t *testing.T) {
+	path := writePolicy(t, "p,user:1,chat:100,write\n")
+	e, err := New(path)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	allowed, err := e.Enforce("user:2", "chat:100", "write")
+	if err != nil {
+		t.Fatalf("Enforce: %v", err)
+	}
+	if allowed {
+		t.Fatalf("expected user:2 to be denied")
+	}
+}
+
+func TestEnforceWildcardObject(t *testing.T) {
+	path := writePolicy(t, "p,user:1,chat:*,read\n")
+	e, err := New(path)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	allowed, err := e.Enforce("user:1", "chat:999", "read")
+	if err != nil {
+		t.Fatalf("Enforce: %v", err)
+	}
+	if !allowed {
+		t.Fatalf("expected wildcard chat:* to match chat:999")
+	}
+}
+
+func TestEnforceActionHierarchy(t *testing.T) {
+	path := writePolicy(t, "p,user:1,chat:100,admin\ng,admin,write\ng,write,read\n")
+	e, err := New(path)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	for _, action := range []string{"admin", "write", "read"} {
+		allowed, err := e.Enforce("user:1", "chat:100", action)
+		if err != nil {
+			t.Fatalf("Enforce(%q): %v", action, err)
+		}
+		if !allowed {
+			t.Fatalf("expected admin grant to imply %q", action)
+		}
+	}
+	if allowed, _ := e.Enforce("user:1", "chat:100", "clear"); allowed {
+		t.Fatalf("expected admin grant to not imply clear without an explicit g row")
+	}
+}
+
+func TestNewTreatsMissingFileAsEmptyPolicy(t *testing.T) {
+	e, err := New(filepath.Join(t.TempDir(), "does-not-exist.csv"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	allowed, err := e.Enforce("user:1", "chat:100", "read")
+	if err != nil {
+		t.Fatalf("Enforce: %v", err)
+	}
+	if allowed {
+		t.Fatalf("expected a missing policy file to deny everything")
+	}
+}
+
+func TestReloadPicksUpChanges(t *testing.T) {
+	path := writePolicy(t, "p,user:1,chat:100,read\n")
+	e, err := New(path)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if allowed, _ := e.Enforce("user:1", "chat:100", "write"); allowed {
+		t.Fatalf("expected write to be denied before reload")
+	}
+
+	if err := os.WriteFile(path, []byte("p,user:1,chat:100,write\n"), 0o600); err != nil {
+		t.Fatalf("rewrite policy: %v", err)
+	}
+	if err := e.Reload(); err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	allowed, err := e.Enforce("user:1", "chat:100", "write")
+	if err != nil {
+		t.Fatalf("Enforce: %v", err)
+	}
+	if !allowed {
+		t.Fatalf("expected write to be granted after reload")
+	}
+}
+
+func TestWatchReloadsOnFileChange(t *testing.T) {
+	path := writePolicy(t, "p,user:1,chat:100,read\n")
+	e, err := New(path)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go e.Watch(ctx, 10*time.Millisecond)
+
+	// Advance the mtime enough to be observed even on filesystems with
+	// coarse timestamp resolution.
+	future := time.Now().Add(time.Second)
+	if err := os.WriteFile(path, []byte("p,user:1,chat:100,write\n"), 0o600); err != nil {
+		t.Fatalf("rewrite policy: %v", err)
+	}
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	deadline := time.After(2 * time.Second)
+	for {
+		if allowed, _ := e.Enforce("user:1", "chat:100", "write"); allowed {
+			return
+		}
+		select {
+		case <-deadline:
+			t.Fatalf("timed out waiting for Watch to pick up the policy change")
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+}
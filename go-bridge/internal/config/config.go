@@ -3,36 +3,128 @@ package config
 import (
 	"errors"
 	"fmt"
+	"net"
+	"net/netip"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/i18n"
 )
 
 type Config struct {
-	BotToken             string
-	AdminUserIDs         []int64
-	AllowedUserIDs       []int64
-	BotTransport         string
-	WebhookURL           string
-	WebhookListenAddr    string
-	DataDir              string
-	DatabasePath         string
-	OpenCodeServerURL    string
-	OpenCodeServerUser   string
-	OpenCodeServerPass   string
-	DefaultSessionID     string
-	OpenCodeTimeout      time.Duration
-	RelayMode            string
-	RelayFallback        bool
-	RelayFallbackDelayMs int
-	HealthPort           int
-	LogLevel             string
-	LogFilePath          string
-	LogMaxSizeMB         int
-	LogMaxBackups        int
-	LogMaxAgeDays        int
+	BotToken                 string
+	AdminUserIDs             []int64
+	AllowedUserIDs           []int64
+	BotTransport             string
+	TelegramTransport        string
+	TelegramAPIID            int
+	TelegramAPIHash          string
+	WebhookURL               string
+	WebhookListenAddr        string
+	WebhookSecretToken       string
+	WebhookTrustedProxyCIDRs []string
+	DataDir                  string
+	DatabasePath             string
+	// DatabaseURL, when set, overrides DatabasePath and is parsed by
+	// storage.Open for a driver scheme. sqlite:// is the only scheme backed
+	// by a working driver today; postgres:// and mysql:// parse but are
+	// rejected with storage.ErrDriverNotVendored (see that error's doc
+	// comment).
+	DatabaseURL       string
+	ConfigOverlayPath string
+	OpenCodeServerURL string
+	// OpenCodeServerURLs lists failover endpoints in priority order, parsed
+	// from the comma-separated OPENCODE_SERVER_URLS. All endpoints share
+	// OpenCodeServerUser/OpenCodeServerPass: this snapshot has no per-secret
+	// map anywhere else in Config, so per-URL credentials would be the only
+	// one of its kind. When unset, Client falls back to OpenCodeServerURL
+	// alone and behaves exactly as it did before multi-endpoint support.
+	OpenCodeServerURLs []string
+	OpenCodeServerUser string
+	OpenCodeServerPass string
+	// OpenCodeBinary/OpenCodeCLIWorkDir back Client.listSessionsFromCLI, the
+	// CLI fallback used when the OpenCode server's /session endpoint isn't
+	// reachable. OpenCodeBinary unset disables the fallback outright.
+	OpenCodeBinary                   string
+	OpenCodeCLIWorkDir               string
+	DefaultSessionID                 string
+	OpenCodeTimeout                  time.Duration
+	RelayMode                        string
+	RelayFallback                    bool
+	RelayFallbackDelayMs             int
+	RelayAttachmentThresholdBytes    int
+	HealthPort                       int
+	LogLevel                         string
+	LogFilePath                      string
+	LogMaxSizeMB                     int
+	LogMaxBackups                    int
+	LogMaxAgeDays                    int
+	IdleTimeoutSeconds               int
+	StorageEncryptionPassphrase      string
+	SecretsKey                       string
+	AuthzHashedIDs                   bool
+	ControlAuthSecrets               []string
+	MetricsEnabled                   bool
+	MetricsPath                      string
+	EventBusBackend                  string
+	NATSURL                          string
+	ReplicaID                        string
+	RelayLeaseTTLSeconds             int
+	HealthTrustedProxyCIDRs          []string
+	HealthRateLimitPerMinute         int
+	HealthRateLimitBurst             int
+	RelayMaxTextBytes                int
+	DefaultLocale                    string
+	OpenCodeRetryMaxAttempts         int
+	OpenCodeRetryBaseMs              int
+	OpenCodeRetryCapMs               int
+	OpenCodeStreamIdleTimeoutSeconds int
+	AuthzPolicyFile                  string
+	AuthzPolicyReloadSeconds         int
+	OpenCodeCircuitBreakerThreshold  int
+	OpenCodeCircuitBreakerCooldownMs int
+	OpenCodeRateLimitPerSecond       int
+	OpenCodeRateLimitBurst           int
+	UsernameCacheTTLSeconds          int
+	UsernameNegativeCacheTTLSeconds  int
+	UsernameResolveConcurrency       int
+	DefaultUserRequestsPerDay        int
+	DefaultUserTokensPerMonth        int
+	ShutdownDrainTimeoutSeconds      int
+	// ResolverBackends lists, in fallback order, the username resolver
+	// backends ResolveService chains: "bot_api" (getChat), "tdlib"
+	// (contacts.searchPublicChat) and "directory" (a static resolver.json
+	// file for air-gapped ops). Parsed from the comma-separated
+	// RESOLVER_BACKEND, defaulting to just "bot_api".
+	ResolverBackends      []string
+	ResolverDirectoryPath string
+	// BotPollingIntervalS is how often, in seconds, telegram.API polls
+	// getUpdates in BOT_TRANSPORT=polling mode. telegram.NewAPI defaults a
+	// value <=0 to 2s.
+	BotPollingIntervalS int
+	// SessionsListLimit/SessionsSource configure the "/sessions" and control
+	// "session_list" listing: how many sessions to show and whether they
+	// come from OpenCode's session endpoint ("endpoint"), the opencode CLI
+	// ("cli"), or both merged ("both", the default). Both
+	// NewBridgeService and NewControlService fall back to their own
+	// defaults when these are unset.
+	SessionsListLimit  int
+	SessionsSource     string
+	SessionsShowIDList bool
+	// RelaySSEEnabled gates the relay service's OpenCode SSE subscription
+	// loop (RelayService.Run); disabling it falls back to synchronous
+	// wait-for-reply mode.
+	RelaySSEEnabled bool
+	// ControlSocketPath/ControlWebServer only pick the control mode runServe
+	// logs at startup and whether `bridge doctor` checks the permissions of
+	// a socket file at ControlSocketPath. The control endpoint
+	// (admin-equivalent session/quota commands) is always served by
+	// HealthServer over TCP on HealthPort; no Unix socket is opened.
+	ControlSocketPath string
+	ControlWebServer  bool
 }
 
 func LoadFromEnv() (Config, error) {
@@ -64,30 +156,208 @@ func LoadFromEnv() (Config, error) {
 	if err != nil {
 		return Config{}, err
 	}
+	telegramAPIID, err := parseIntWithDefault("TELEGRAM_API_ID", 0)
+	if err != nil {
+		return Config{}, err
+	}
+	relayAttachmentThreshold, err := parseIntWithDefault("RELAY_ATTACHMENT_THRESHOLD_BYTES", 3500)
+	if err != nil {
+		return Config{}, err
+	}
+	idleTimeoutSeconds, err := parseIntWithDefault("TGBRIDGE_IDLE_TIMEOUT", 0)
+	if err != nil {
+		return Config{}, err
+	}
+	authzHashedIDs, err := parseBoolWithDefault("AUTHZ_HASHED_IDS", false)
+	if err != nil {
+		return Config{}, err
+	}
+	metricsEnabled, err := parseBoolWithDefault("METRICS_ENABLED", true)
+	if err != nil {
+		return Config{}, err
+	}
+	relayLeaseTTLSeconds, err := parseIntWithDefault("RELAY_LEASE_TTL_SECONDS", 30)
+	if err != nil {
+		return Config{}, err
+	}
+	replicaID := strings.TrimSpace(os.Getenv("REPLICA_ID"))
+	if replicaID == "" {
+		if hostname, hostErr := os.Hostname(); hostErr == nil {
+			replicaID = hostname
+		}
+	}
+	healthRateLimitPerMinute, err := parseIntWithDefault("HEALTH_RATE_LIMIT_PER_MINUTE", 30)
+	if err != nil {
+		return Config{}, err
+	}
+	healthRateLimitBurst, err := parseIntWithDefault("HEALTH_RATE_LIMIT_BURST", 10)
+	if err != nil {
+		return Config{}, err
+	}
+	relayMaxTextBytes, err := parseIntWithDefault("RELAY_MAX_TEXT_BYTES", 32*1024)
+	if err != nil {
+		return Config{}, err
+	}
+	openCodeRetryMaxAttempts, err := parseIntWithDefault("OPENCODE_RETRY_MAX", 3)
+	if err != nil {
+		return Config{}, err
+	}
+	openCodeRetryBaseMs, err := parseIntWithDefault("OPENCODE_RETRY_BASE_MS", 250)
+	if err != nil {
+		return Config{}, err
+	}
+	openCodeRetryCapMs, err := parseIntWithDefault("OPENCODE_RETRY_CAP_MS", 5000)
+	if err != nil {
+		return Config{}, err
+	}
+	openCodeStreamIdleTimeoutSeconds, err := parseIntWithDefault("OPENCODE_STREAM_IDLE_TIMEOUT_SECONDS", 90)
+	if err != nil {
+		return Config{}, err
+	}
+	authzPolicyReloadSeconds, err := parseIntWithDefault("AUTHZ_POLICY_RELOAD_SECONDS", 10)
+	if err != nil {
+		return Config{}, err
+	}
+	openCodeCircuitBreakerThreshold, err := parseIntWithDefault("OPENCODE_CIRCUIT_BREAKER_THRESHOLD", 5)
+	if err != nil {
+		return Config{}, err
+	}
+	openCodeCircuitBreakerCooldownMs, err := parseIntWithDefault("OPENCODE_CIRCUIT_BREAKER_COOLDOWN_MS", 30000)
+	if err != nil {
+		return Config{}, err
+	}
+	openCodeRateLimitPerSecond, err := parseIntWithDefault("OPENCODE_RATE_LIMIT_PER_SECOND", 20)
+	if err != nil {
+		return Config{}, err
+	}
+	openCodeRateLimitBurst, err := parseIntWithDefault("OPENCODE_RATE_LIMIT_BURST", 20)
+	if err != nil {
+		return Config{}, err
+	}
+	usernameCacheTTLSeconds, err := parseIntWithDefault("USERNAME_CACHE_TTL_SECONDS", 24*60*60)
+	if err != nil {
+		return Config{}, err
+	}
+	usernameNegativeCacheTTLSeconds, err := parseIntWithDefault("USERNAME_NEGATIVE_CACHE_TTL_SECONDS", 5*60)
+	if err != nil {
+		return Config{}, err
+	}
+	usernameResolveConcurrency, err := parseIntWithDefault("USERNAME_RESOLVE_CONCURRENCY", 4)
+	if err != nil {
+		return Config{}, err
+	}
+	defaultUserRequestsPerDay, err := parseIntWithDefault("DEFAULT_USER_REQUESTS_PER_DAY", 0)
+	if err != nil {
+		return Config{}, err
+	}
+	defaultUserTokensPerMonth, err := parseIntWithDefault("DEFAULT_USER_TOKENS_PER_MONTH", 0)
+	if err != nil {
+		return Config{}, err
+	}
+	shutdownDrainTimeoutSeconds, err := parseIntWithDefault("SHUTDOWN_DRAIN_TIMEOUT_SECONDS", 25)
+	if err != nil {
+		return Config{}, err
+	}
+	resolverBackends := parseStringList(os.Getenv("RESOLVER_BACKEND"))
+	if len(resolverBackends) == 0 {
+		resolverBackends = []string{"bot_api"}
+	}
+	botPollingIntervalS, err := parseIntWithDefault("BOT_POLLING_INTERVAL_SECONDS", 2)
+	if err != nil {
+		return Config{}, err
+	}
+	sessionsListLimit, err := parseIntWithDefault("SESSIONS_LIST_LIMIT", 5)
+	if err != nil {
+		return Config{}, err
+	}
+	sessionsShowIDList, err := parseBoolWithDefault("SESSIONS_SHOW_ID_LIST", false)
+	if err != nil {
+		return Config{}, err
+	}
+	relaySSEEnabled, err := parseBoolWithDefault("RELAY_SSE_ENABLED", true)
+	if err != nil {
+		return Config{}, err
+	}
+	controlWebServer, err := parseBoolWithDefault("CONTROL_WEB_SERVER", false)
+	if err != nil {
+		return Config{}, err
+	}
 
 	cfg := Config{
-		BotToken:             botToken,
-		AdminUserIDs:         adminIDs,
-		AllowedUserIDs:       allowedIDs,
-		BotTransport:         defaultString(os.Getenv("BOT_TRANSPORT"), "polling"),
-		WebhookURL:           strings.TrimSpace(os.Getenv("WEBHOOK_URL")),
-		WebhookListenAddr:    defaultString(strings.TrimSpace(os.Getenv("WEBHOOK_LISTEN_ADDR")), ":8090"),
-		DataDir:              dataDir,
-		DatabasePath:         filepath.Join(dataDir, "bridge.db"),
-		OpenCodeServerURL:    defaultString(os.Getenv("OPENCODE_SERVER_URL"), "http://127.0.0.1:4096"),
-		OpenCodeServerUser:   defaultString(os.Getenv("OPENCODE_SERVER_USERNAME"), "opencode"),
-		OpenCodeServerPass:   strings.TrimSpace(os.Getenv("OPENCODE_SERVER_PASSWORD")),
-		DefaultSessionID:     strings.TrimSpace(os.Getenv("DEFAULT_SESSION_ID")),
-		OpenCodeTimeout:      time.Duration(openCodeTimeoutMs) * time.Millisecond,
-		RelayMode:            defaultString(strings.TrimSpace(os.Getenv("RELAY_MODE")), "last"),
-		RelayFallback:        relayFallback,
-		RelayFallbackDelayMs: relayFallbackDelay,
-		HealthPort:           healthPort,
-		LogLevel:             defaultString(strings.TrimSpace(os.Getenv("LOG_LEVEL")), "info"),
-		LogFilePath:          filepath.Join(dataDir, "logs", "bridge.log"),
-		LogMaxSizeMB:         10,
-		LogMaxBackups:        5,
-		LogMaxAgeDays:        14,
+		BotToken:                         botToken,
+		AdminUserIDs:                     adminIDs,
+		AllowedUserIDs:                   allowedIDs,
+		BotTransport:                     defaultString(os.Getenv("BOT_TRANSPORT"), "polling"),
+		TelegramTransport:                defaultString(os.Getenv("TELEGRAM_TRANSPORT"), "bot"),
+		TelegramAPIID:                    telegramAPIID,
+		TelegramAPIHash:                  strings.TrimSpace(os.Getenv("TELEGRAM_API_HASH")),
+		WebhookURL:                       strings.TrimSpace(os.Getenv("WEBHOOK_URL")),
+		WebhookListenAddr:                defaultString(strings.TrimSpace(os.Getenv("WEBHOOK_LISTEN_ADDR")), ":8090"),
+		WebhookSecretToken:               strings.TrimSpace(os.Getenv("WEBHOOK_SECRET_TOKEN")),
+		WebhookTrustedProxyCIDRs:         parseStringList(os.Getenv("WEBHOOK_TRUSTED_PROXY_CIDRS")),
+		DataDir:                          dataDir,
+		DatabasePath:                     filepath.Join(dataDir, "bridge.db"),
+		ConfigOverlayPath:                defaultString(strings.TrimSpace(os.Getenv("CONFIG_OVERLAY_PATH")), filepath.Join(dataDir, "bridge.yaml")),
+		DatabaseURL:                      strings.TrimSpace(os.Getenv("DATABASE_URL")),
+		OpenCodeServerURL:                defaultString(os.Getenv("OPENCODE_SERVER_URL"), "http://127.0.0.1:4096"),
+		OpenCodeServerURLs:               parseStringList(os.Getenv("OPENCODE_SERVER_URLS")),
+		OpenCodeServerUser:               defaultString(os.Getenv("OPENCODE_SERVER_USERNAME"), "opencode"),
+		OpenCodeServerPass:               strings.TrimSpace(os.Getenv("OPENCODE_SERVER_PASSWORD")),
+		OpenCodeBinary:                   strings.TrimSpace(os.Getenv("OPENCODE_BINARY")),
+		OpenCodeCLIWorkDir:               strings.TrimSpace(os.Getenv("OPENCODE_CLI_WORKDIR")),
+		DefaultSessionID:                 strings.TrimSpace(os.Getenv("DEFAULT_SESSION_ID")),
+		OpenCodeTimeout:                  time.Duration(openCodeTimeoutMs) * time.Millisecond,
+		RelayMode:                        defaultString(strings.TrimSpace(os.Getenv("RELAY_MODE")), "last"),
+		RelayFallback:                    relayFallback,
+		RelayFallbackDelayMs:             relayFallbackDelay,
+		RelayAttachmentThresholdBytes:    relayAttachmentThreshold,
+		HealthPort:                       healthPort,
+		LogLevel:                         defaultString(strings.TrimSpace(os.Getenv("LOG_LEVEL")), "info"),
+		LogFilePath:                      filepath.Join(dataDir, "logs", "bridge.log"),
+		LogMaxSizeMB:                     10,
+		LogMaxBackups:                    5,
+		LogMaxAgeDays:                    14,
+		IdleTimeoutSeconds:               idleTimeoutSeconds,
+		StorageEncryptionPassphrase:      os.Getenv("STORAGE_ENCRYPTION_PASSPHRASE"),
+		SecretsKey:                       os.Getenv("SECRETS_KEY"),
+		AuthzHashedIDs:                   authzHashedIDs,
+		ControlAuthSecrets:               parseStringList(os.Getenv("CONTROL_AUTH_SECRETS")),
+		MetricsEnabled:                   metricsEnabled,
+		MetricsPath:                      defaultString(os.Getenv("METRICS_PATH"), "/metrics"),
+		EventBusBackend:                  defaultString(os.Getenv("EVENT_BUS"), "inprocess"),
+		NATSURL:                          strings.TrimSpace(os.Getenv("NATS_URL")),
+		ReplicaID:                        replicaID,
+		RelayLeaseTTLSeconds:             relayLeaseTTLSeconds,
+		HealthTrustedProxyCIDRs:          parseStringList(os.Getenv("HEALTH_TRUSTED_PROXY_CIDRS")),
+		HealthRateLimitPerMinute:         healthRateLimitPerMinute,
+		HealthRateLimitBurst:             healthRateLimitBurst,
+		RelayMaxTextBytes:                relayMaxTextBytes,
+		DefaultLocale:                    defaultString(strings.ToLower(strings.TrimSpace(os.Getenv("OPENCODE_BOT_LOCALE"))), i18n.DefaultLocale),
+		OpenCodeRetryMaxAttempts:         openCodeRetryMaxAttempts,
+		OpenCodeRetryBaseMs:              openCodeRetryBaseMs,
+		OpenCodeRetryCapMs:               openCodeRetryCapMs,
+		OpenCodeStreamIdleTimeoutSeconds: openCodeStreamIdleTimeoutSeconds,
+		AuthzPolicyFile:                  strings.TrimSpace(os.Getenv("AUTHZ_POLICY_FILE")),
+		AuthzPolicyReloadSeconds:         authzPolicyReloadSeconds,
+		OpenCodeCircuitBreakerThreshold:  openCodeCircuitBreakerThreshold,
+		OpenCodeCircuitBreakerCooldownMs: openCodeCircuitBreakerCooldownMs,
+		OpenCodeRateLimitPerSecond:       openCodeRateLimitPerSecond,
+		OpenCodeRateLimitBurst:           openCodeRateLimitBurst,
+		UsernameCacheTTLSeconds:          usernameCacheTTLSeconds,
+		UsernameNegativeCacheTTLSeconds:  usernameNegativeCacheTTLSeconds,
+		UsernameResolveConcurrency:       usernameResolveConcurrency,
+		DefaultUserRequestsPerDay:        defaultUserRequestsPerDay,
+		DefaultUserTokensPerMonth:        defaultUserTokensPerMonth,
+		ShutdownDrainTimeoutSeconds:      shutdownDrainTimeoutSeconds,
+		ResolverBackends:                 resolverBackends,
+		ResolverDirectoryPath:            defaultString(strings.TrimSpace(os.Getenv("RESOLVER_DIRECTORY_PATH")), filepath.Join(dataDir, "resolver.json")),
+		BotPollingIntervalS:              botPollingIntervalS,
+		SessionsListLimit:                sessionsListLimit,
+		SessionsSource:                   defaultString(strings.ToLower(strings.TrimSpace(os.Getenv("SESSIONS_SOURCE"))), "both"),
+		SessionsShowIDList:               sessionsShowIDList,
+		RelaySSEEnabled:                  relaySSEEnabled,
+		ControlSocketPath:                defaultString(strings.TrimSpace(os.Getenv("CONTROL_SOCKET_PATH")), filepath.Join(dataDir, "control.sock")),
+		ControlWebServer:                 controlWebServer,
 	}
 
 	if err := validate(cfg); err != nil {
@@ -110,18 +380,114 @@ func validate(cfg Config) error {
 	if cfg.BotTransport != "polling" && cfg.BotTransport != "webhook" {
 		return fmt.Errorf("BOT_TRANSPORT must be polling or webhook: got %q", cfg.BotTransport)
 	}
+	// "tdlib" is deliberately not accepted yet: dialTDLib is a hard stub (see
+	// internal/telegram/tdlib.go), so selecting it would only fail bridge
+	// startup once NewTDLibClient tried to dial, after first demanding
+	// TELEGRAM_API_ID/TELEGRAM_API_HASH for no benefit.
+	//
+	// "mtproto" is deliberately not accepted yet: MTProtoClient's auth key
+	// exchange and wire transport aren't implemented (see
+	// internal/telegram/mtproto.go), so selecting it would only fail bridge
+	// startup once NewMTProtoClient tried to dial.
+	if cfg.TelegramTransport != "bot" {
+		return fmt.Errorf("TELEGRAM_TRANSPORT must be bot: got %q", cfg.TelegramTransport)
+	}
 	if cfg.BotTransport == "webhook" && cfg.WebhookURL == "" {
 		return errors.New("WEBHOOK_URL is required when BOT_TRANSPORT=webhook")
 	}
 	if cfg.BotTransport == "webhook" && strings.TrimSpace(cfg.WebhookListenAddr) == "" {
 		return errors.New("WEBHOOK_LISTEN_ADDR is required when BOT_TRANSPORT=webhook")
 	}
-	if cfg.RelayMode != "last" && cfg.RelayMode != "final" {
-		return fmt.Errorf("RELAY_MODE must be last or final: got %q", cfg.RelayMode)
+	if cfg.WebhookSecretToken != "" && !isValidWebhookSecretToken(cfg.WebhookSecretToken) {
+		return errors.New("WEBHOOK_SECRET_TOKEN must be 1-256 chars of A-Z, a-z, 0-9, _ or -")
+	}
+	for _, cidr := range cfg.WebhookTrustedProxyCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("WEBHOOK_TRUSTED_PROXY_CIDRS: invalid CIDR %q: %w", cidr, err)
+		}
+	}
+	if cfg.RelayMode != "last" && cfg.RelayMode != "final" && cfg.RelayMode != "stream" {
+		return fmt.Errorf("RELAY_MODE must be last, final, or stream: got %q", cfg.RelayMode)
+	}
+	if !i18n.IsSupported(cfg.DefaultLocale) {
+		return fmt.Errorf("OPENCODE_BOT_LOCALE must be one of %v: got %q", i18n.SupportedLocales, cfg.DefaultLocale)
 	}
 	if cfg.HealthPort <= 0 {
 		return fmt.Errorf("HEALTH_PORT must be > 0: got %d", cfg.HealthPort)
 	}
+	if cfg.AuthzHashedIDs && strings.TrimSpace(cfg.StorageEncryptionPassphrase) == "" {
+		return errors.New("STORAGE_ENCRYPTION_PASSPHRASE is required when AUTHZ_HASHED_IDS=true")
+	}
+	if cfg.EventBusBackend != "inprocess" && cfg.EventBusBackend != "nats" {
+		return fmt.Errorf("EVENT_BUS must be inprocess or nats: got %q", cfg.EventBusBackend)
+	}
+	if cfg.EventBusBackend == "nats" && cfg.NATSURL == "" {
+		return errors.New("NATS_URL is required when EVENT_BUS=nats")
+	}
+	for _, cidr := range cfg.HealthTrustedProxyCIDRs {
+		if _, err := netip.ParsePrefix(cidr); err != nil {
+			return fmt.Errorf("HEALTH_TRUSTED_PROXY_CIDRS: invalid CIDR %q: %w", cidr, err)
+		}
+	}
+	if cfg.HealthRateLimitPerMinute <= 0 {
+		return fmt.Errorf("HEALTH_RATE_LIMIT_PER_MINUTE must be > 0: got %d", cfg.HealthRateLimitPerMinute)
+	}
+	if cfg.HealthRateLimitBurst <= 0 {
+		return fmt.Errorf("HEALTH_RATE_LIMIT_BURST must be > 0: got %d", cfg.HealthRateLimitBurst)
+	}
+	if cfg.RelayMaxTextBytes <= 0 {
+		return fmt.Errorf("RELAY_MAX_TEXT_BYTES must be > 0: got %d", cfg.RelayMaxTextBytes)
+	}
+	if cfg.OpenCodeRetryMaxAttempts <= 0 {
+		return fmt.Errorf("OPENCODE_RETRY_MAX must be > 0: got %d", cfg.OpenCodeRetryMaxAttempts)
+	}
+	if cfg.OpenCodeRetryBaseMs <= 0 {
+		return fmt.Errorf("OPENCODE_RETRY_BASE_MS must be > 0: got %d", cfg.OpenCodeRetryBaseMs)
+	}
+	if cfg.OpenCodeRetryCapMs <= 0 {
+		return fmt.Errorf("OPENCODE_RETRY_CAP_MS must be > 0: got %d", cfg.OpenCodeRetryCapMs)
+	}
+	if cfg.OpenCodeStreamIdleTimeoutSeconds <= 0 {
+		return fmt.Errorf("OPENCODE_STREAM_IDLE_TIMEOUT_SECONDS must be > 0: got %d", cfg.OpenCodeStreamIdleTimeoutSeconds)
+	}
+	if cfg.AuthzPolicyReloadSeconds <= 0 {
+		return fmt.Errorf("AUTHZ_POLICY_RELOAD_SECONDS must be > 0: got %d", cfg.AuthzPolicyReloadSeconds)
+	}
+	if cfg.OpenCodeCircuitBreakerThreshold <= 0 {
+		return fmt.Errorf("OPENCODE_CIRCUIT_BREAKER_THRESHOLD must be > 0: got %d", cfg.OpenCodeCircuitBreakerThreshold)
+	}
+	if cfg.OpenCodeCircuitBreakerCooldownMs <= 0 {
+		return fmt.Errorf("OPENCODE_CIRCUIT_BREAKER_COOLDOWN_MS must be > 0: got %d", cfg.OpenCodeCircuitBreakerCooldownMs)
+	}
+	if cfg.OpenCodeRateLimitPerSecond <= 0 {
+		return fmt.Errorf("OPENCODE_RATE_LIMIT_PER_SECOND must be > 0: got %d", cfg.OpenCodeRateLimitPerSecond)
+	}
+	if cfg.OpenCodeRateLimitBurst <= 0 {
+		return fmt.Errorf("OPENCODE_RATE_LIMIT_BURST must be > 0: got %d", cfg.OpenCodeRateLimitBurst)
+	}
+	if cfg.UsernameCacheTTLSeconds <= 0 {
+		return fmt.Errorf("USERNAME_CACHE_TTL_SECONDS must be > 0: got %d", cfg.UsernameCacheTTLSeconds)
+	}
+	if cfg.UsernameNegativeCacheTTLSeconds <= 0 {
+		return fmt.Errorf("USERNAME_NEGATIVE_CACHE_TTL_SECONDS must be > 0: got %d", cfg.UsernameNegativeCacheTTLSeconds)
+	}
+	if cfg.UsernameResolveConcurrency <= 0 {
+		return fmt.Errorf("USERNAME_RESOLVE_CONCURRENCY must be > 0: got %d", cfg.UsernameResolveConcurrency)
+	}
+	if cfg.ShutdownDrainTimeoutSeconds <= 0 {
+		return fmt.Errorf("SHUTDOWN_DRAIN_TIMEOUT_SECONDS must be > 0: got %d", cfg.ShutdownDrainTimeoutSeconds)
+	}
+	if len(cfg.ResolverBackends) == 0 {
+		return errors.New("RESOLVER_BACKEND must list at least one backend")
+	}
+	for _, backend := range cfg.ResolverBackends {
+		if backend != "bot_api" && backend != "tdlib" && backend != "directory" {
+			return fmt.Errorf("RESOLVER_BACKEND must be one of bot_api, tdlib, directory: got %q", backend)
+		}
+		if backend == "tdlib" && (cfg.TelegramAPIID == 0 || cfg.TelegramAPIHash == "") {
+			return errors.New("TELEGRAM_API_ID and TELEGRAM_API_HASH are required when RESOLVER_BACKEND includes tdlib")
+		}
+	}
 	return nil
 }
 
@@ -149,6 +515,42 @@ func parseBoolWithDefault(key string, fallback bool) (bool, error) {
 	return v, nil
 }
 
+func parseStringList(raw string) []string {
+	trimmed := strings.TrimSpace(raw)
+	if trimmed == "" {
+		return nil
+	}
+	parts := strings.Split(trimmed, ",")
+	out := make([]string, 0, len(parts))
+	for _, part := range parts {
+		item := strings.TrimSpace(part)
+		if item == "" {
+			continue
+		}
+		out = append(out, item)
+	}
+	return out
+}
+
+func isValidWebhookSecretToken(token string) bool {
+	if len(token) == 0 || len(token) > 256 {
+		return false
+	}
+	for _, r := range token {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_' || r == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseInt64List exposes parseInt64List's "comma-separated int64s" parsing to
+// callers outside this package, e.g. main's ALLOWED_USER_IDS override
+// subscriber re-seeding storage after config.Manager validates a change.
+func ParseInt64List(raw string) ([]int64, error) {
+	return parseInt64List(raw)
+}
+
 func parseInt64List(raw string) ([]int64, error) {
 	trimmed := strings.TrimSpace(raw)
 	if trimmed == "" {
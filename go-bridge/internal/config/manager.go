@@ -0,0 +1,365 @@
+package config
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// overridableKey is one config field Manager/"/config set" is allowed to
+// change after startup: apply parses raw and writes it onto a candidate
+// Config (returning a validation error the same way LoadFromEnv's own
+// parsing does), render turns the field back into its canonical string so
+// Manager can detect whether a reload actually changed it.
+type overridableKey struct {
+	apply  func(cfg *Config, raw string) error
+	render func(cfg Config) string
+}
+
+var overridableKeys = map[string]overridableKey{
+	"RELAY_MODE": {
+		apply:  func(cfg *Config, raw string) error { cfg.RelayMode = raw; return nil },
+		render: func(cfg Config) string { return cfg.RelayMode },
+	},
+	"LOG_LEVEL": {
+		apply:  func(cfg *Config, raw string) error { cfg.LogLevel = raw; return nil },
+		render: func(cfg Config) string { return cfg.LogLevel },
+	},
+	"ALLOWED_USER_IDS": {
+		apply: func(cfg *Config, raw string) error {
+			ids, err := parseInt64List(raw)
+			if err != nil {
+				return err
+			}
+			cfg.AllowedUserIDs = ids
+			return nil
+		},
+		render: func(cfg Config) string {
+			parts := make([]string, len(cfg.AllowedUserIDs))
+			for i, id := range cfg.AllowedUserIDs {
+				parts[i] = strconv.FormatInt(id, 10)
+			}
+			return strings.Join(parts, ",")
+		},
+	},
+	"OPENCODE_TIMEOUT_MS": {
+		apply: func(cfg *Config, raw string) error {
+			ms, err := strconv.Atoi(raw)
+			if err != nil {
+				return fmt.Errorf("OPENCODE_TIMEOUT_MS must be integer: %w", err)
+			}
+			cfg.OpenCodeTimeout = time.Duration(ms) * time.Millisecond
+			return nil
+		},
+		render: func(cfg Config) string { return strconv.FormatInt(cfg.OpenCodeTimeout.Milliseconds(), 10) },
+	},
+}
+
+// OverridableKeys lists the config keys Manager accepts, sorted for stable
+// help text and error messages (e.g. from the /config command).
+func OverridableKeys() []string {
+	keys := make([]string, 0, len(overridableKeys))
+	for key := range overridableKeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ConfigOverridesStore is the persistence Manager needs for the db
+// override layer. It's declared here rather than imported from
+// internal/ports so internal/config — which most of the tree already
+// depends on — doesn't also have to depend on internal/ports; SQLiteStore
+// satisfies this interface structurally.
+type ConfigOverridesStore interface {
+	ListConfigOverrides(ctx context.Context) (map[string]string, error)
+	UpsertConfigOverride(ctx context.Context, key string, value string) error
+	DeleteConfigOverride(ctx context.Context, key string) error
+}
+
+// Manager layers a live-reloadable override set on top of the env vars
+// LoadFromEnv already resolved once at startup: an optional KEY=VALUE file
+// at DATA_DIR/bridge.yaml (despite the extension, this build speaks a
+// simple line format rather than real YAML, since there's no dependency
+// manifest here to vendor a YAML parser with), then per-key rows an admin
+// can set at runtime via "/config set <key> <value>". Every change is
+// validated as a complete Config before it takes effect, so a bad override
+// never reaches Current().
+type Manager struct {
+	base     Config
+	filePath string
+	store    ConfigOverridesStore
+
+	mu            sync.RWMutex
+	fileOverrides map[string]string
+	dbOverrides   map[string]string
+	current       Config
+
+	subMu       sync.Mutex
+	subscribers map[string][]chan string
+}
+
+// NewManager builds a Manager seeded from base (normally config.LoadFromEnv's
+// result), the file layer at filePath, and the db layer from store (which
+// may be nil before a Store exists yet, e.g. during early bootstrap).
+func NewManager(ctx context.Context, base Config, filePath string, store ConfigOverridesStore) (*Manager, error) {
+	m := &Manager{base: base, filePath: filePath, store: store, subscribers: map[string][]chan string{}}
+
+	fileOverrides, err := readKeyValueFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	dbOverrides := map[string]string{}
+	if store != nil {
+		dbOverrides, err = store.ListConfigOverrides(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	merged, err := mergeLayers(base, fileOverrides, dbOverrides)
+	if err != nil {
+		return nil, err
+	}
+
+	m.fileOverrides = fileOverrides
+	m.dbOverrides = dbOverrides
+	m.current = merged
+	return m, nil
+}
+
+// Current returns the effective config as of the last successful layer
+// application.
+func (m *Manager) Current() Config {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.current
+}
+
+// ReloadFile re-reads the file layer and recomputes the effective config.
+// A missing file is treated as an empty layer; a malformed file or an
+// override that fails validate() leaves the previously active config (and
+// the db layer) untouched and returns the error.
+func (m *Manager) ReloadFile() error {
+	fileOverrides, err := readKeyValueFile(m.filePath)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	merged, err := mergeLayers(m.base, fileOverrides, m.dbOverrides)
+	if err != nil {
+		return err
+	}
+
+	previous := m.current
+	m.fileOverrides = fileOverrides
+	m.current = merged
+	m.notifyChanges(previous, merged)
+	return nil
+}
+
+// SetOverride validates key=raw against a candidate config before doing
+// anything else; only once that candidate passes validate() is it
+// persisted to store (when one is configured) and swapped in as current,
+// so a rejected override never touches the db layer.
+func (m *Manager) SetOverride(ctx context.Context, key string, raw string) error {
+	if _, ok := overridableKeys[key]; !ok {
+		return fmt.Errorf("config: unknown override key %q (allowed: %s)", key, strings.Join(OverridableKeys(), ", "))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	candidate := cloneStringMap(m.dbOverrides)
+	candidate[key] = raw
+	merged, err := mergeLayers(m.base, m.fileOverrides, candidate)
+	if err != nil {
+		return err
+	}
+
+	if m.store != nil {
+		if err := m.store.UpsertConfigOverride(ctx, key, raw); err != nil {
+			return err
+		}
+	}
+
+	previous := m.current
+	m.dbOverrides = candidate
+	m.current = merged
+	m.notifyChanges(previous, merged)
+	return nil
+}
+
+// ClearOverride removes key from the db layer, falling back to whatever
+// the file/env layers resolve to underneath it.
+func (m *Manager) ClearOverride(ctx context.Context, key string) error {
+	if _, ok := overridableKeys[key]; !ok {
+		return fmt.Errorf("config: unknown override key %q (allowed: %s)", key, strings.Join(OverridableKeys(), ", "))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	candidate := cloneStringMap(m.dbOverrides)
+	delete(candidate, key)
+	merged, err := mergeLayers(m.base, m.fileOverrides, candidate)
+	if err != nil {
+		return err
+	}
+
+	if m.store != nil {
+		if err := m.store.DeleteConfigOverride(ctx, key); err != nil {
+			return err
+		}
+	}
+
+	previous := m.current
+	m.dbOverrides = candidate
+	m.current = merged
+	m.notifyChanges(previous, merged)
+	return nil
+}
+
+// Subscribe returns a channel that receives key's new rendered value
+// whenever ReloadFile/SetOverride/ClearOverride changes it. The channel is
+// buffered by one and never closed; a slow consumer simply misses
+// intermediate values rather than blocking the change that produced them.
+func (m *Manager) Subscribe(key string) <-chan string {
+	ch := make(chan string, 1)
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+	m.subscribers[key] = append(m.subscribers[key], ch)
+	return ch
+}
+
+func (m *Manager) notifyChanges(previous, next Config) {
+	for key, entry := range overridableKeys {
+		if entry.render(previous) == entry.render(next) {
+			continue
+		}
+		m.publish(key, entry.render(next))
+	}
+}
+
+func (m *Manager) publish(key string, value string) {
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+	for _, ch := range m.subscribers[key] {
+		select {
+		case ch <- value:
+		default:
+			// Buffer full: replace the stale pending value with this one so
+			// the consumer's next read is the latest value.
+			select {
+			case <-ch:
+			default:
+			}
+			select {
+			case ch <- value:
+			default:
+			}
+		}
+	}
+}
+
+// WatchSIGHUP blocks re-reading the file layer every time the process
+// receives SIGHUP, until ctx is done, so an operator can push a new
+// DATA_DIR/bridge.yaml and apply it with "kill -HUP" instead of a restart.
+// onReloadError, if non-nil, is called with any error ReloadFile returns
+// (the previous config stays active either way).
+func (m *Manager) WatchSIGHUP(ctx context.Context, onReloadError func(error)) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+	defer signal.Stop(sigCh)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-sigCh:
+			if err := m.ReloadFile(); err != nil && onReloadError != nil {
+				onReloadError(err)
+			}
+		}
+	}
+}
+
+// mergeLayers applies overrides, in order (later layers win), onto a copy
+// of base and validates the result as a complete Config, the same way
+// LoadFromEnv does, so a layered config can never reach Current() in a
+// state LoadFromEnv itself would have rejected.
+func mergeLayers(base Config, layers ...map[string]string) (Config, error) {
+	cfg := base
+	for _, layer := range layers {
+		for key, raw := range layer {
+			entry, ok := overridableKeys[key]
+			if !ok {
+				return Config{}, fmt.Errorf("config: unknown override key %q (allowed: %s)", key, strings.Join(OverridableKeys(), ", "))
+			}
+			if err := entry.apply(&cfg, raw); err != nil {
+				return Config{}, fmt.Errorf("config: invalid override %s=%q: %w", key, raw, err)
+			}
+		}
+	}
+	if err := validate(cfg); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+// readKeyValueFile parses path as "KEY=VALUE" lines, ignoring blank lines
+// and "#"-prefixed comments. A missing file resolves to an empty layer
+// rather than an error, matching how the rest of this package treats
+// optional on-disk state.
+func readKeyValueFile(path string) (map[string]string, error) {
+	if strings.TrimSpace(path) == "" {
+		return map[string]string{}, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read config overlay %s: %w", path, err)
+	}
+
+	out := map[string]string{}
+	scanner := bufio.NewScanner(strings.NewReader(string(content)))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("config overlay %s: malformed line %q (want KEY=VALUE)", path, line)
+		}
+		out[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read config overlay %s: %w", path, err)
+	}
+	return out, nil
+}
+
+func cloneStringMap(in map[string]string) map[string]string {
+	out := make(map[string]string, len(in))
+	for k, v := range in {
+		out[k] = v
+	}
+	return out
+}
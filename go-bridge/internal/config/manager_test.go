@@ -0,0 +1,219 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func validTestConfig() Config {
+	return Config{
+		BotToken:                         "test-token",
+		AdminUserIDs:                     []int64{1},
+		OpenCodeServerURL:                "http://localhost:4096",
+		BotTransport:                     "polling",
+		TelegramTransport:                "bot",
+		RelayMode:                        "last",
+		DefaultLocale:                    "es",
+		HealthPort:                       8080,
+		EventBusBackend:                  "inprocess",
+		HealthRateLimitPerMinute:         60,
+		HealthRateLimitBurst:             10,
+		RelayMaxTextBytes:                1024,
+		OpenCodeRetryMaxAttempts:         3,
+		OpenCodeRetryBaseMs:              100,
+		OpenCodeRetryCapMs:               1000,
+		OpenCodeStreamIdleTimeoutSeconds: 30,
+		AuthzPolicyReloadSeconds:         30,
+		OpenCodeCircuitBreakerThreshold:  5,
+		OpenCodeCircuitBreakerCooldownMs: 1000,
+		OpenCodeRateLimitPerSecond:       10,
+		OpenCodeRateLimitBurst:           10,
+		OpenCodeTimeout:                  30 * time.Second,
+		UsernameCacheTTLSeconds:          86400,
+		UsernameNegativeCacheTTLSeconds:  300,
+		UsernameResolveConcurrency:       4,
+		ShutdownDrainTimeoutSeconds:      30,
+		ResolverBackends:                 []string{"bot_api"},
+	}
+}
+
+type fakeConfigOverridesStore struct {
+	rows map[string]string
+}
+
+func newFakeConfigOverridesStore() *fakeConfigOverridesStore {
+	return &fakeConfigOverridesStore{rows: map[string]string{}}
+}
+
+func (f *fakeConfigOverridesStore) ListConfigOverrides(ctx context.Context) (map[string]string, error) {
+	out := make(map[string]string, len(f.rows))
+	for k, v := range f.rows {
+		out[k] = v
+	}
+	return out, nil
+}
+
+func (f *fakeConfigOverridesStore) UpsertConfigOverride(ctx context.Context, key string, value string) error {
+	f.rows[key] = value
+	return nil
+}
+
+func (f *fakeConfigOverridesStore) DeleteConfigOverride(ctx context.Context, key string) error {
+	delete(f.rows, key)
+	return nil
+}
+
+func TestNewManagerMergesFileAndDBLayers(t *testing.T) {
+	dir := t.TempDir()
+	overlayPath := filepath.Join(dir, "bridge.yaml")
+	if err := os.WriteFile(overlayPath, []byte("LOG_LEVEL=debug\n# a comment\n\nRELAY_MODE=final\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	store := newFakeConfigOverridesStore()
+	store.rows["RELAY_MODE"] = "stream"
+
+	mgr, err := NewManager(context.Background(), validTestConfig(), overlayPath, store)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+
+	got := mgr.Current()
+	if got.LogLevel != "debug" {
+		t.Errorf("LogLevel = %q, want file layer value debug", got.LogLevel)
+	}
+	if got.RelayMode != "stream" {
+		t.Errorf("RelayMode = %q, want db layer (stream) to win over file layer (final)", got.RelayMode)
+	}
+}
+
+func TestManagerSetOverrideValidatesBeforePersisting(t *testing.T) {
+	store := newFakeConfigOverridesStore()
+	mgr, err := NewManager(context.Background(), validTestConfig(), "", store)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+
+	if err := mgr.SetOverride(context.Background(), "RELAY_MODE", "bogus"); err == nil {
+		t.Fatal("expected SetOverride to reject an invalid RELAY_MODE")
+	}
+	if _, ok := store.rows["RELAY_MODE"]; ok {
+		t.Error("invalid override must not be persisted to the store")
+	}
+	if mgr.Current().RelayMode != "last" {
+		t.Errorf("Current().RelayMode = %q, want unchanged base value after a rejected override", mgr.Current().RelayMode)
+	}
+
+	if err := mgr.SetOverride(context.Background(), "RELAY_MODE", "stream"); err != nil {
+		t.Fatalf("SetOverride: %v", err)
+	}
+	if store.rows["RELAY_MODE"] != "stream" {
+		t.Errorf("store.rows[RELAY_MODE] = %q, want stream", store.rows["RELAY_MODE"])
+	}
+	if mgr.Current().RelayMode != "stream" {
+		t.Errorf("Current().RelayMode = %q, want stream", mgr.Current().RelayMode)
+	}
+}
+
+func TestManagerSetOverrideRejectsUnknownKey(t *testing.T) {
+	mgr, err := NewManager(context.Background(), validTestConfig(), "", nil)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	if err := mgr.SetOverride(context.Background(), "NOT_A_REAL_KEY", "x"); err == nil {
+		t.Fatal("expected an error for an unknown override key")
+	}
+}
+
+func TestManagerClearOverrideFallsBackToFileLayer(t *testing.T) {
+	dir := t.TempDir()
+	overlayPath := filepath.Join(dir, "bridge.yaml")
+	if err := os.WriteFile(overlayPath, []byte("LOG_LEVEL=warn\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	store := newFakeConfigOverridesStore()
+	mgr, err := NewManager(context.Background(), validTestConfig(), overlayPath, store)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+
+	if err := mgr.SetOverride(context.Background(), "LOG_LEVEL", "debug"); err != nil {
+		t.Fatalf("SetOverride: %v", err)
+	}
+	if mgr.Current().LogLevel != "debug" {
+		t.Fatalf("LogLevel = %q, want debug", mgr.Current().LogLevel)
+	}
+
+	if err := mgr.ClearOverride(context.Background(), "LOG_LEVEL"); err != nil {
+		t.Fatalf("ClearOverride: %v", err)
+	}
+	if mgr.Current().LogLevel != "warn" {
+		t.Errorf("LogLevel = %q, want file layer value warn after clearing the db override", mgr.Current().LogLevel)
+	}
+}
+
+func TestManagerReloadFileRejectsMalformedFileWithoutDisturbingCurrent(t *testing.T) {
+	dir := t.TempDir()
+	overlayPath := filepath.Join(dir, "bridge.yaml")
+	if err := os.WriteFile(overlayPath, []byte("LOG_LEVEL=warn\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mgr, err := NewManager(context.Background(), validTestConfig(), overlayPath, nil)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+
+	if err := os.WriteFile(overlayPath, []byte("this is not key=value\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.ReloadFile(); err == nil {
+		t.Fatal("expected ReloadFile to reject a malformed overlay file")
+	}
+	if mgr.Current().LogLevel != "warn" {
+		t.Errorf("LogLevel = %q, want unchanged warn after a rejected reload", mgr.Current().LogLevel)
+	}
+
+	if err := os.WriteFile(overlayPath, []byte("LOG_LEVEL=error\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.ReloadFile(); err != nil {
+		t.Fatalf("ReloadFile: %v", err)
+	}
+	if mgr.Current().LogLevel != "error" {
+		t.Errorf("LogLevel = %q, want error after a valid reload", mgr.Current().LogLevel)
+	}
+}
+
+func TestManagerSubscribeReceivesChangeNotifications(t *testing.T) {
+	mgr, err := NewManager(context.Background(), validTestConfig(), "", nil)
+	if err != nil {
+		t.Fatalf("NewManager: %v", err)
+	}
+	ch := mgr.Subscribe("RELAY_MODE")
+
+	if err := mgr.SetOverride(context.Background(), "RELAY_MODE", "stream"); err != nil {
+		t.Fatalf("SetOverride: %v", err)
+	}
+
+	select {
+	case got := <-ch:
+		if got != "stream" {
+			t.Errorf("notification = %q, want stream", got)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for a Subscribe notification")
+	}
+}
+
+func TestReadKeyValueFileMissingFileIsEmptyLayer(t *testing.T) {
+	layer, err := readKeyValueFile(filepath.Join(t.TempDir(), "does-not-exist.yaml"))
+	if err != nil {
+		t.Fatalf("readKeyValueFile: %v", err)
+	}
+	if len(layer) != 0 {
+		t.Errorf("layer = %v, want empty for a missing file", layer)
+	}
+}
@@ -0,0 +1,115 @@
+// Package crypto provides the encryption-at-rest primitives used by
+// internal/storage: AES-256-GCM for sensitive column values with a key
+// derived from an operator passphrase via scrypt, and HMAC-SHA256 for
+// hashing lookup columns so a stolen database doesn't reveal raw IDs.
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+	keyLen  = 32
+)
+
+// SaltSize is the recommended size for the random salt passed to NewCipher.
+// Callers are expected to generate it once with rand.Read and persist it
+// alongside the database, since scrypt's security depends on the salt being
+// random but doesn't require it to be secret.
+const SaltSize = 16
+
+// Cipher encrypts/decrypts column values with AES-256-GCM and hashes lookup
+// columns with HMAC-SHA256, both keyed off a single passphrase-derived
+// secret.
+type Cipher struct {
+	aead    cipher.AEAD
+	hmacKey []byte
+}
+
+// NewCipher derives an AES-256-GCM key and an independent HMAC key from
+// passphrase and salt via scrypt.
+func NewCipher(passphrase string, salt []byte) (*Cipher, error) {
+	if passphrase == "" {
+		return nil, errors.New("crypto: passphrase must not be empty")
+	}
+	derived, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, keyLen*2)
+	if err != nil {
+		return nil, fmt.Errorf("crypto: derive key: %w", err)
+	}
+	block, err := aes.NewCipher(derived[:keyLen])
+	if err != nil {
+		return nil, fmt.Errorf("crypto: init aes cipher: %w", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("crypto: init gcm: %w", err)
+	}
+	return &Cipher{aead: aead, hmacKey: derived[keyLen:]}, nil
+}
+
+// Encrypt seals plaintext behind a random per-call nonce and returns
+// base64(nonce||ciphertext) so the result fits in a single TEXT column. An
+// empty plaintext encrypts to an empty string so NULL-ish values round-trip
+// without needing a separate "is set" column.
+func (c *Cipher) Encrypt(plaintext string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("crypto: generate nonce: %w", err)
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Decrypt reverses Encrypt.
+func (c *Cipher) Decrypt(encoded string) (string, error) {
+	if encoded == "" {
+		return "", nil
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("crypto: decode ciphertext: %w", err)
+	}
+	nonceSize := c.aead.NonceSize()
+	if len(raw) < nonceSize {
+		return "", errors.New("crypto: ciphertext too short")
+	}
+	nonce, sealed := raw[:nonceSize], raw[nonceSize:]
+	plaintext, err := c.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", fmt.Errorf("crypto: decrypt: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// Hash returns a deterministic HMAC-SHA256 hex digest of value, used to
+// replace a plaintext lookup column (e.g. an opencode session ID) with a
+// blind index: the digest is equality-comparable but cannot be reversed back
+// to the original value without the key.
+func (c *Cipher) Hash(value string) string {
+	mac := hmac.New(sha256.New, c.hmacKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// HashID is Hash for numeric IDs, used to replace plaintext chat/user IDs in
+// lookup columns while keeping them equality-comparable.
+func (c *Cipher) HashID(id int64) string {
+	return c.Hash(strconv.FormatInt(id, 10))
+}
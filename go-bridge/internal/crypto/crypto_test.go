@@ -0,0 +1,145 @@
+package crypto
+
+import (
+	"strings"
+	"testing"
+)
+
+func newTestCipher(t *testing.T) *Cipher {
+	t.Helper()
+	salt := make([]byte, SaltSize)
+	c, err := NewCipher("correct-horse-battery-staple", salt)
+	if err != nil {
+		t.Fatalf("new cipher: %v", err)
+	}
+	return c
+}
+
+func TestNewCipherRejectsEmptyPassphrase(t *testing.T) {
+	if _, err := NewCipher("", make([]byte, SaltSize)); err == nil {
+		t.Fatalf("expected error for empty passphrase")
+	}
+}
+
+func TestEncryptDecryptRoundTrips(t *testing.T) {
+	c := newTestCipher(t)
+
+	encrypted, err := c.Encrypt("hola mundo")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if encrypted == "hola mundo" {
+		t.Fatalf("expected ciphertext to differ from plaintext")
+	}
+
+	decrypted, err := c.Decrypt(encrypted)
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if decrypted != "hola mundo" {
+		t.Fatalf("decrypt: got %q, want %q", decrypted, "hola mundo")
+	}
+}
+
+func TestEncryptDecryptEmptyStringRoundTrips(t *testing.T) {
+	c := newTestCipher(t)
+
+	encrypted, err := c.Encrypt("")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if encrypted != "" {
+		t.Fatalf("expected empty plaintext to encrypt to empty string, got %q", encrypted)
+	}
+
+	decrypted, err := c.Decrypt("")
+	if err != nil {
+		t.Fatalf("decrypt: %v", err)
+	}
+	if decrypted != "" {
+		t.Fatalf("expected empty ciphertext to decrypt to empty string, got %q", decrypted)
+	}
+}
+
+func TestEncryptIsNonDeterministic(t *testing.T) {
+	c := newTestCipher(t)
+
+	first, err := c.Encrypt("hola mundo")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	second, err := c.Encrypt("hola mundo")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if first == second {
+		t.Fatalf("expected two encryptions of the same plaintext to differ (random nonce)")
+	}
+}
+
+func TestDecryptDetectsTampering(t *testing.T) {
+	c := newTestCipher(t)
+
+	encrypted, err := c.Encrypt("hola mundo")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	tampered := []byte(encrypted)
+	tampered[len(tampered)-1] ^= 0x01
+	if _, err := c.Decrypt(string(tampered)); err == nil {
+		t.Fatalf("expected tampered ciphertext to fail to decrypt")
+	}
+}
+
+func TestDecryptRejectsWrongKey(t *testing.T) {
+	c := newTestCipher(t)
+	encrypted, err := c.Encrypt("hola mundo")
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	other, err := NewCipher("a different passphrase entirely", make([]byte, SaltSize))
+	if err != nil {
+		t.Fatalf("new cipher: %v", err)
+	}
+	if _, err := other.Decrypt(encrypted); err == nil {
+		t.Fatalf("expected decrypt with the wrong key to fail")
+	}
+}
+
+func TestHashIsDeterministic(t *testing.T) {
+	c := newTestCipher(t)
+	if c.Hash("opencode-session-id") != c.Hash("opencode-session-id") {
+		t.Fatalf("expected Hash to be deterministic for the same input")
+	}
+	if c.Hash("opencode-session-id") == c.Hash("another-session-id") {
+		t.Fatalf("expected different inputs to hash differently")
+	}
+}
+
+func TestHashDoesNotLeakPlaintext(t *testing.T) {
+	c := newTestCipher(t)
+	digest := c.Hash("opencode-session-id")
+	if strings.Contains(digest, "opencode-session-id") {
+		t.Fatalf("expected hash digest not to contain the plaintext value")
+	}
+}
+
+func TestHashIDMatchesHashOfFormattedID(t *testing.T) {
+	c := newTestCipher(t)
+	if c.HashID(42) != c.Hash("42") {
+		t.Fatalf("expected HashID(42) to equal Hash(\"42\")")
+	}
+}
+
+func TestHashDependsOnKey(t *testing.T) {
+	c := newTestCipher(t)
+	other, err := NewCipher("a different passphrase entirely", make([]byte, SaltSize))
+	if err != nil {
+		t.Fatalf("new cipher: %v", err)
+	}
+	if c.Hash("opencode-session-id") == other.Hash("opencode-session-id") {
+		t.Fatalf("expected Hash to depend on the cipher's key")
+	}
+}
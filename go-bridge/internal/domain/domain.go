@@ -14,6 +14,7 @@ type SessionLink struct {
 type RelayMode string
 
 const (
-	RelayModeLast  RelayMode = "last"
-	RelayModeFinal RelayMode = "final"
+	RelayModeLast   RelayMode = "last"
+	RelayModeFinal  RelayMode = "final"
+	RelayModeStream RelayMode = "stream"
 )
@@ -0,0 +1,65 @@
+// Package eventbus provides ports.EventBus implementations RelayService uses
+// to share the opencode event stream across replicas instead of every
+// replica independently dispatching the same session.
+package eventbus
+
+import (
+	"context"
+	"sync"
+)
+
+// subscriberBuffer is how many pending messages a slow subscriber can queue
+// before Publish starts dropping for it; RelayService republishes on every
+// opencode event, so a dropped message just means a slightly stale cache
+// until the next one arrives.
+const subscriberBuffer = 32
+
+// InProcessBus is a single-process ports.EventBus: Publish fans a message out
+// to every channel Subscribe has handed out for that topic. It reproduces
+// today's single-replica behavior exactly, and is the default bus when
+// EVENT_BUS isn't set to a clustered backend.
+type InProcessBus struct {
+	mu   sync.Mutex
+	subs map[string][]chan []byte
+}
+
+func NewInProcessBus() *InProcessBus {
+	return &InProcessBus{subs: make(map[string][]chan []byte)}
+}
+
+func (b *InProcessBus) Publish(_ context.Context, topic string, data []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, ch := range b.subs[topic] {
+		select {
+		case ch <- data:
+		default:
+			// Slow subscriber; drop rather than block Publish.
+		}
+	}
+	return nil
+}
+
+func (b *InProcessBus) Subscribe(ctx context.Context, topic string) (<-chan []byte, error) {
+	ch := make(chan []byte, subscriberBuffer)
+
+	b.mu.Lock()
+	b.subs[topic] = append(b.subs[topic], ch)
+	b.mu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		subs := b.subs[topic]
+		for i, existing := range subs {
+			if existing == ch {
+				b.subs[topic] = append(subs[:i], subs[i+1:]...)
+				break
+			}
+		}
+		close(ch)
+	}()
+
+	return ch, nil
+}
@@ -0,0 +1,34 @@
+package eventbus
+
+import (
+	"context"
+	"fmt"
+)
+
+// NATSBus is the clustered ports.EventBus backend: every bridge replica
+// publishes/subscribes to the same NATS (or Redis Streams) subject per
+// session instead of relying on process-local channels, so a load-balanced
+// deployment doesn't double-dispatch. No NATS client library is wired in:
+// Publish and Subscribe below fail loudly rather than silently falling back
+// to single-process behavior.
+type NATSBus struct {
+	url string
+}
+
+// NewNATSBus validates url and returns a bus whose Publish/Subscribe always
+// return an error, since no NATS client is wired in; operators run with
+// EVENT_BUS=inprocess (the default) instead.
+func NewNATSBus(url string) (*NATSBus, error) {
+	if url == "" {
+		return nil, fmt.Errorf("eventbus: NATS_URL is required when EVENT_BUS=nats")
+	}
+	return &NATSBus{url: url}, nil
+}
+
+func (b *NATSBus) Publish(_ context.Context, topic string, _ []byte) error {
+	return fmt.Errorf("eventbus: NATS transport to %s not wired up yet for topic %q; run with EVENT_BUS=inprocess or vendor github.com/nats-io/nats.go and implement dial", b.url, topic)
+}
+
+func (b *NATSBus) Subscribe(_ context.Context, topic string) (<-chan []byte, error) {
+	return nil, fmt.Errorf("eventbus: NATS transport to %s not wired up yet for topic %q; run with EVENT_BUS=inprocess or vendor github.com/nats-io/nats.go and implement dial", b.url, topic)
+}
@@ -0,0 +1,88 @@
+// Package i18n provides the bot's user-facing message catalogs, so strings
+// shown to Telegram users (currently the OpenCode error mapping) are keyed
+// lookups against a locale's catalog instead of literals scattered across
+// handlers. New locales are added by extending catalogs; new messages by
+// adding a key to every catalog.
+package i18n
+
+import "fmt"
+
+// DefaultLocale is used whenever a requested locale has no catalog and
+// whenever OPENCODE_BOT_LOCALE is unset.
+const DefaultLocale = "es"
+
+// SupportedLocales lists every locale with a complete catalog, in the order
+// they should be offered to users (e.g. by /lang).
+var SupportedLocales = []string{"es", "en"}
+
+// IsSupported reports whether locale has a registered catalog.
+func IsSupported(locale string) bool {
+	_, ok := catalogs[locale]
+	return ok
+}
+
+var catalogs = map[string]map[string]string{
+	"es": {
+		"err.opencode.unavailable":   "OpenCode no esta disponible. Revisa OPENCODE_SERVER_URL y que el servidor este corriendo.",
+		"err.opencode.unauthorized":  "OpenCode rechazo credenciales. Revisa OPENCODE_SERVER_USERNAME y OPENCODE_SERVER_PASSWORD.",
+		"err.opencode.timeout":       "OpenCode no respondio a tiempo. Revisa OPENCODE_TIMEOUT_MS o la carga del servidor.",
+		"err.opencode.bad_request":   "OpenCode rechazo la solicitud (datos invalidos).",
+		"err.opencode.server_error":  "OpenCode fallo al procesar la solicitud.",
+		"err.opencode.generic":       "No se pudo enviar el mensaje a OpenCode.",
+		"err.opencode.detail_suffix": "%s\nDetalle del servidor:\n%s",
+		"err.quota.exceeded":         "Alcanzaste tu limite de %d solicitudes por dia. Intenta de nuevo manana o pide a un admin que lo ajuste con /quota.",
+	},
+	"en": {
+		"err.opencode.unavailable":   "OpenCode is unavailable. Check OPENCODE_SERVER_URL and that the server is running.",
+		"err.opencode.unauthorized":  "OpenCode rejected the credentials. Check OPENCODE_SERVER_USERNAME and OPENCODE_SERVER_PASSWORD.",
+		"err.opencode.timeout":       "OpenCode did not respond in time. Check OPENCODE_TIMEOUT_MS or server load.",
+		"err.opencode.bad_request":   "OpenCode rejected the request (invalid data).",
+		"err.opencode.server_error":  "OpenCode failed to process the request.",
+		"err.opencode.generic":       "Could not send the message to OpenCode.",
+		"err.opencode.detail_suffix": "%s\nServer detail:\n%s",
+		"err.quota.exceeded":         "You've reached your limit of %d requests per day. Try again tomorrow or ask an admin to adjust it with /quota.",
+	},
+}
+
+// Localizer renders catalog messages for a single, fixed locale.
+type Localizer interface {
+	// Message looks up key in the localizer's locale and formats it with
+	// args via fmt.Sprintf. A key missing from the locale's catalog falls
+	// back to DefaultLocale's catalog, and a key missing from that too is
+	// returned as-is so a typo surfaces instead of panicking.
+	Message(key string, args ...any) string
+	// Locale returns the locale this Localizer was built for (normalized to
+	// DefaultLocale if the requested one had no catalog).
+	Locale() string
+}
+
+type catalogLocalizer struct {
+	locale string
+}
+
+// New returns a Localizer for locale, falling back to DefaultLocale if
+// locale isn't one of SupportedLocales.
+func New(locale string) Localizer {
+	if !IsSupported(locale) {
+		locale = DefaultLocale
+	}
+	return catalogLocalizer{locale: locale}
+}
+
+func (l catalogLocalizer) Locale() string {
+	return l.locale
+}
+
+func (l catalogLocalizer) Message(key string, args ...any) string {
+	template, ok := catalogs[l.locale][key]
+	if !ok {
+		template, ok = catalogs[DefaultLocale][key]
+	}
+	if !ok {
+		return key
+	}
+	if len(args) == 0 {
+		return template
+	}
+	return fmt.Sprintf(template, args...)
+}
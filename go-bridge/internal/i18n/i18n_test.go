@@ -0,0 +1,39 @@
+package i18n
+
+import "testing"
+
+func TestCatalogsHaveTheSameKeys(t *testing.T) {
+	reference := catalogs[DefaultLocale]
+	for _, locale := range SupportedLocales {
+		catalog, ok := catalogs[locale]
+		if !ok {
+			t.Fatalf("locale %q has no catalog", locale)
+		}
+		for key := range reference {
+			if _, ok := catalog[key]; !ok {
+				t.Fatalf("locale %q is missing key %q", locale, key)
+			}
+		}
+	}
+}
+
+func TestNewFallsBackToDefaultLocale(t *testing.T) {
+	if got := New("xx").Locale(); got != DefaultLocale {
+		t.Fatalf("expected unsupported locale to fall back to %q, got %q", DefaultLocale, got)
+	}
+}
+
+func TestMessageFormatsArgs(t *testing.T) {
+	got := New("en").Message("err.opencode.detail_suffix", "base", "detail")
+	want := "base\nServer detail:\ndetail"
+	if got != want {
+		t.Fatalf("Message mismatch: got %q want %q", got, want)
+	}
+}
+
+func TestMessageUnknownKeyReturnsKeyItself(t *testing.T) {
+	const key = "err.opencode.does_not_exist"
+	if got := New("es").Message(key); got != key {
+		t.Fatalf("expected unknown key to pass through, got %q", got)
+	}
+}
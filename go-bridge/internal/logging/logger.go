@@ -11,8 +11,16 @@ import (
 )
 
 func New(cfg config.Config) (*slog.Logger, error) {
+	logger, _, err := NewWithLevel(cfg)
+	return logger, err
+}
+
+// NewWithLevel is New, but also returns the *slog.LevelVar backing the
+// logger's handler so a caller can raise or lower the level afterwards (see
+// config.Manager's "LOG_LEVEL" override) without rebuilding the handler.
+func NewWithLevel(cfg config.Config) (*slog.Logger, *slog.LevelVar, error) {
 	if err := os.MkdirAll(filepath.Dir(cfg.LogFilePath), 0o755); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	rotatingWriter := &lumberjack.Logger{
@@ -23,9 +31,19 @@ func New(cfg config.Config) (*slog.Logger, error) {
 		Compress:   true,
 	}
 
+	level := &slog.LevelVar{}
+	level.Set(parseLevel(cfg.LogLevel))
+
 	writer := io.MultiWriter(os.Stdout, rotatingWriter)
-	handler := slog.NewJSONHandler(writer, &slog.HandlerOptions{Level: parseLevel(cfg.LogLevel)})
-	return slog.New(handler), nil
+	handler := slog.NewJSONHandler(writer, &slog.HandlerOptions{Level: level})
+	return slog.New(handler), level, nil
+}
+
+// ParseLevel exposes parseLevel's env-var string mapping so callers outside
+// this package (config.Manager's "LOG_LEVEL" subscriber) apply the same
+// "debug"/"warn"/"error"/default-info rules when a level changes at runtime.
+func ParseLevel(level string) slog.Level {
+	return parseLevel(level)
 }
 
 func parseLevel(level string) slog.Level {
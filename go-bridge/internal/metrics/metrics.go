@@ -0,0 +1,170 @@
+// Package metrics wraps github.com/prometheus/client_golang so the rest of
+// the bridge can declare counters/gauges/histograms and register them on a
+// dedicated *Registry (rather than the global prometheus.DefaultRegisterer),
+// letting tests construct an isolated registry and assert on metric values
+// with prometheus/client_golang/prometheus/testutil.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// Counter is a monotonically increasing value safe for concurrent use.
+type Counter struct {
+	c prometheus.Counter
+}
+
+// NewCounter creates a standalone, label-less counter for registration.
+func NewCounter(name string, help string) *Counter {
+	return &Counter{c: prometheus.NewCounter(prometheus.CounterOpts{Name: name, Help: help})}
+}
+
+func (c *Counter) Inc() {
+	c.c.Inc()
+}
+
+func (c *Counter) Add(delta float64) {
+	c.c.Add(delta)
+}
+
+// Value returns the counter's current value, for tests to assert on.
+func (c *Counter) Value() float64 {
+	return testutil.ToFloat64(c.c)
+}
+
+// CounterVec is a family of counters sharing a name and help text but keyed
+// by a single label value, e.g. telegram_send_total{result="sendMessage:ok"}.
+type CounterVec struct {
+	v *prometheus.CounterVec
+}
+
+func NewCounterVec(name string, help string, label string) *CounterVec {
+	return &CounterVec{v: prometheus.NewCounterVec(prometheus.CounterOpts{Name: name, Help: help}, []string{label})}
+}
+
+// WithLabelValue returns the counter for the given label value, creating it
+// on first use.
+func (v *CounterVec) WithLabelValue(value string) *Counter {
+	return &Counter{c: v.v.WithLabelValues(value)}
+}
+
+// Gauge is a value that can move up or down, e.g. the number of cached
+// sessions or in-flight opencode streams.
+type Gauge struct {
+	g prometheus.Gauge
+}
+
+func NewGauge(name string, help string) *Gauge {
+	return &Gauge{g: prometheus.NewGauge(prometheus.GaugeOpts{Name: name, Help: help})}
+}
+
+func (g *Gauge) Set(value float64) {
+	g.g.Set(value)
+}
+
+func (g *Gauge) Inc() {
+	g.g.Inc()
+}
+
+func (g *Gauge) Dec() {
+	g.g.Dec()
+}
+
+func (g *Gauge) Add(delta float64) {
+	g.g.Add(delta)
+}
+
+// Value returns the gauge's current value, for tests to assert on.
+func (g *Gauge) Value() float64 {
+	return testutil.ToFloat64(g.g)
+}
+
+// Histogram tracks observations against a fixed set of buckets, exposing the
+// usual _bucket/_sum/_count series.
+type Histogram struct {
+	h prometheus.Histogram
+}
+
+func NewHistogram(name string, help string, buckets []float64) *Histogram {
+	return &Histogram{h: prometheus.NewHistogram(prometheus.HistogramOpts{Name: name, Help: help, Buckets: buckets})}
+}
+
+func (h *Histogram) Observe(value float64) {
+	h.h.Observe(value)
+}
+
+// Registry collects metrics and serves them for a /metrics endpoint via
+// promhttp. Packages that want an isolated registry for testing (rather than
+// the shared Default) can call NewRegistry and pass it into their
+// constructor.
+type Registry struct {
+	reg *prometheus.Registry
+}
+
+func NewRegistry() *Registry {
+	return &Registry{reg: prometheus.NewRegistry()}
+}
+
+// Register adds a CounterVec to the registry and returns it, so callers can
+// declare and register a metric in one line at package init. If a metric
+// with the same name is already registered on this registry - e.g. because a
+// constructor that registers on Default ran more than once, as happens
+// across table-driven tests - the already-registered collector is returned
+// instead of panicking.
+func (r *Registry) Register(vec *CounterVec) *CounterVec {
+	if err := r.reg.Register(vec.v); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return &CounterVec{v: are.ExistingCollector.(*prometheus.CounterVec)}
+		}
+		panic(err)
+	}
+	return vec
+}
+
+// RegisterCounter adds a standalone, label-less Counter.
+func (r *Registry) RegisterCounter(c *Counter) *Counter {
+	if err := r.reg.Register(c.c); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return &Counter{c: are.ExistingCollector.(prometheus.Counter)}
+		}
+		panic(err)
+	}
+	return c
+}
+
+// RegisterGauge adds a Gauge.
+func (r *Registry) RegisterGauge(g *Gauge) *Gauge {
+	if err := r.reg.Register(g.g); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return &Gauge{g: are.ExistingCollector.(prometheus.Gauge)}
+		}
+		panic(err)
+	}
+	return g
+}
+
+// RegisterHistogram adds a Histogram.
+func (r *Registry) RegisterHistogram(h *Histogram) *Histogram {
+	if err := r.reg.Register(h.h); err != nil {
+		if are, ok := err.(prometheus.AlreadyRegisteredError); ok {
+			return &Histogram{h: are.ExistingCollector.(prometheus.Histogram)}
+		}
+		panic(err)
+	}
+	return h
+}
+
+// Handler serves every metric registered on r in Prometheus text exposition
+// format.
+func (r *Registry) Handler() http.Handler {
+	return promhttp.HandlerFor(r.reg, promhttp.HandlerOpts{})
+}
+
+// Default is the process-wide registry backing the bridge's /metrics
+// endpoint; packages that want to be scraped register their counters here
+// unless they're given a dedicated registry (e.g. for isolated tests).
+var Default = NewRegistry()
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCounterVecValueTracksIncrements(t *testing.T) {
+	registry := NewRegistry()
+	total := registry.Register(NewCounterVec("test_requests_total", "Test requests by result.", "result"))
+
+	total.WithLabelValue("ok").Inc()
+	total.WithLabelValue("ok").Inc()
+	total.WithLabelValue("error").Add(3)
+
+	if got := total.WithLabelValue("ok").Value(); got != 2 {
+		t.Fatalf("expected ok=2, got %g", got)
+	}
+	if got := total.WithLabelValue("error").Value(); got != 3 {
+		t.Fatalf("expected error=3, got %g", got)
+	}
+}
+
+func TestGaugeValueTracksSetIncDec(t *testing.T) {
+	registry := NewRegistry()
+	openStreams := registry.RegisterGauge(NewGauge("test_open_streams", "Test open streams."))
+
+	openStreams.Set(5)
+	openStreams.Inc()
+	openStreams.Dec()
+	openStreams.Add(2)
+
+	if got := openStreams.Value(); got != 7 {
+		t.Fatalf("expected 7, got %g", got)
+	}
+}
+
+func TestRegisterIsIdempotentForTheSameName(t *testing.T) {
+	registry := NewRegistry()
+	first := registry.Register(NewCounterVec("test_idempotent_total", "Test idempotent registration.", "result"))
+	first.WithLabelValue("ok").Inc()
+
+	// A second constructor call that registers the same metric name on the
+	// same registry (e.g. a test helper invoked more than once) must reuse
+	// the existing collector rather than panicking.
+	second := registry.Register(NewCounterVec("test_idempotent_total", "Test idempotent registration.", "result"))
+	if got := second.WithLabelValue("ok").Value(); got != 1 {
+		t.Fatalf("expected the second registration to see the first's value, got %g", got)
+	}
+}
+
+func TestHandlerServesRegisteredMetricsAsPrometheusText(t *testing.T) {
+	registry := NewRegistry()
+	total := registry.Register(NewCounterVec("test_handler_requests_total", "Test handler requests.", "result"))
+	total.WithLabelValue("ok").Inc()
+
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	w := httptest.NewRecorder()
+	registry.Handler().ServeHTTP(w, req)
+
+	if w.Code != 200 {
+		t.Fatalf("expected 200, got %d", w.Code)
+	}
+	body := w.Body.String()
+	if !strings.Contains(body, `test_handler_requests_total{result="ok"} 1`) {
+		t.Fatalf("expected scraped text to contain the incremented counter, got %q", body)
+	}
+}
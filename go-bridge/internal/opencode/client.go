@@ -4,40 +4,379 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
+	"math/rand"
 	"net/http"
+	"net/url"
 	"os/exec"
 	"regexp"
 	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+	"unicode"
 
 	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/config"
+	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/ports"
 )
 
 type Client struct {
-	baseURL  string
-	username string
-	password string
-	binary   string
-	cliDir   string
-	timeout  time.Duration
-	http     *http.Client
-	stream   *http.Client
+	baseURL           string
+	username          string
+	password          string
+	binary            string
+	cliDir            string
+	timeout           time.Duration
+	http              *http.Client
+	stream            *http.Client
+	retryMaxAttempts  int
+	retryBase         time.Duration
+	retryCap          time.Duration
+	streamIdleTimeout time.Duration
+
+	// endpoints holds every OPENCODE_SERVER_URLS entry in priority order
+	// (just [baseURL] when only OPENCODE_SERVER_URL is set). endpointIdx is
+	// the index of the endpoint currently preferred; failoverEndpoint
+	// advances it, MTProto-PHONE_MIGRATE_X-style, whenever a request against
+	// it fails outright or comes back 5xx.
+	endpoints        []string
+	endpointIdx      int32
+	sessionEndpoints ports.SessionEndpointsRepository
+
+	// deadlineMu guards the read/write deadline-timer pair below, modeled on
+	// gVisor's gonet deadlineTimer: SetStreamDeadline/SetRequestDeadline each
+	// arm an AfterFunc that closes its cancelCh at the deadline, and a
+	// blocked streamOnce/doRequest selects on that channel to bail out with
+	// errDeadlineExceeded without touching the caller's context.
+	deadlineMu      sync.Mutex
+	streamCancelCh  chan struct{}
+	requestCancelCh chan struct{}
+	streamTimer     *time.Timer
+	requestTimer    *time.Timer
+
+	timeoutMu sync.RWMutex
 }
 
+// errDeadlineExceeded is returned by streamOnce/doRequest when a
+// SetStreamDeadline/SetRequestDeadline bound elapses, distinct from the
+// parent context's own cancellation.
+var errDeadlineExceeded = errors.New("opencode: deadline exceeded")
+
 var sessionIDRegex = regexp.MustCompile(`ses_[A-Za-z0-9]+`)
 var sessionColumnsRegex = regexp.MustCompile(`\s{2,}`)
 var cliUpdatedAtSuffixRegex = regexp.MustCompile(`(?i)\d{1,2}:\d{2}\s*(?:am|pm)(?:\s*·\s*\d{1,2}/\d{1,2}/\d{4})?$`)
 
+// OpenCodeErrorKind classifies an OpenCodeError so callers can branch on it
+// (e.g. to pick a user-facing message) without parsing error strings.
+type OpenCodeErrorKind int
+
+const (
+	KindUnknown OpenCodeErrorKind = iota
+	KindUnavailable
+	KindUnauthorized
+	KindTimeout
+	KindBadRequest
+	KindServerError
+)
+
+// maxErrorDetailBytes and maxErrorDetailLines bound OpenCodeError.Detail, so
+// a large or binary response body never ends up quoted whole in a Telegram
+// message.
+const (
+	maxErrorDetailBytes = 650
+	maxErrorDetailLines = 8
+)
+
+// OpenCodeError is the typed error surface for every failure the OpenCode
+// HTTP client can report, populated at the point request reads the response
+// so the status code and a trimmed body snippet are never lost by the time
+// callers render a user-facing message.
+type OpenCodeError struct {
+	Kind       OpenCodeErrorKind
+	StatusCode int
+	URL        string
+	Detail     string
+	RetryAfter time.Duration
+	Err        error
+}
+
+func (e *OpenCodeError) Error() string {
+	msg := fmt.Sprintf("opencode request to %s failed", e.URL)
+	if e.StatusCode != 0 {
+		msg = fmt.Sprintf("%s (status %d)", msg, e.StatusCode)
+	}
+	if e.Err != nil {
+		msg = fmt.Sprintf("%s: %v", msg, e.Err)
+	}
+	return msg
+}
+
+func (e *OpenCodeError) Unwrap() error {
+	return e.Err
+}
+
+// classifyStatus maps an HTTP status code to an OpenCodeErrorKind.
+func classifyStatus(statusCode int) OpenCodeErrorKind {
+	switch {
+	case statusCode == http.StatusUnauthorized || statusCode == http.StatusForbidden:
+		return KindUnauthorized
+	case statusCode == http.StatusRequestTimeout || statusCode == http.StatusGatewayTimeout:
+		return KindTimeout
+	case statusCode >= 400 && statusCode < 500:
+		return KindBadRequest
+	case statusCode >= 500:
+		return KindServerError
+	default:
+		return KindUnknown
+	}
+}
+
+// isNoContentStatus reports whether statusCode is defined by RFC 9110 §6.4.1
+// to never carry a body (204, 304, and the 1xx informational class), so
+// request can skip reading/decoding the body instead of risking a spurious
+// EOF from trying to inflate or parse content that isn't there.
+func isNoContentStatus(statusCode int) bool {
+	if statusCode == http.StatusNoContent || statusCode == http.StatusNotModified {
+		return true
+	}
+	return statusCode >= 100 && statusCode < 200
+}
+
+// isNotFound reports whether err is an OpenCodeError for a 404 response,
+// used where a missing session/message can be treated as already-gone
+// instead of a failure.
+func isNotFound(err error) bool {
+	var ocErr *OpenCodeError
+	return errors.As(err, &ocErr) && ocErr.StatusCode == http.StatusNotFound
+}
+
+// redactURL strips any userinfo (user:password@) component from rawURL
+// before it's attached to an OpenCodeError, in case baseURL was ever
+// configured with embedded credentials instead of basic auth headers.
+func redactURL(rawURL string) string {
+	parsed, err := url.Parse(rawURL)
+	if err != nil || parsed.User == nil {
+		return rawURL
+	}
+	parsed.User = nil
+	return parsed.String()
+}
+
+// extractErrorDetail returns a short, safe-to-display snippet of an error
+// response body, or "" if the body isn't worth quoting: only text/plain and
+// application/json bodies made entirely of printable/whitespace runes
+// qualify, and the result is capped at maxErrorDetailLines lines and
+// maxErrorDetailBytes bytes with each line tab-indented for readability.
+func extractErrorDetail(contentType string, body []byte) string {
+	mediaType := strings.ToLower(strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0]))
+	if mediaType != "text/plain" && mediaType != "application/json" {
+		return ""
+	}
+	trimmed := strings.TrimSpace(string(body))
+	if trimmed == "" {
+		return ""
+	}
+	for _, r := range trimmed {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return ""
+		}
+	}
+
+	if len(trimmed) > maxErrorDetailBytes {
+		trimmed = trimmed[:maxErrorDetailBytes]
+	}
+	lines := strings.Split(trimmed, "\n")
+	if len(lines) > maxErrorDetailLines {
+		lines = lines[:maxErrorDetailLines]
+	}
+	for i, line := range lines {
+		lines[i] = "\t" + line
+	}
+	return strings.Join(lines, "\n")
+}
+
+// parseRetryAfter reads a Retry-After response header, which RFC 9110 §10.2.3
+// allows to be either a number of seconds or an HTTP-date, returning 0 if the
+// header is absent, malformed, or already in the past.
+func parseRetryAfter(header string) time.Duration {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(header); err == nil {
+		if seconds <= 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if when, err := time.Parse(http.TimeFormat, header); err == nil {
+		if wait := time.Until(when); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// setDeadline stops any existing timer for the given cancelCh/timer pair,
+// then arms a new one at t, following gVisor's gonet deadlineTimer: if
+// Stop() raced an already-firing timer, cancelCh is replaced first so the
+// fired-but-already-closed channel never leaks into the new deadline. A zero
+// t clears the deadline; a t already in the past closes cancelCh immediately.
+func (c *Client) setDeadline(cancelCh *chan struct{}, timer **time.Timer, t time.Time) {
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+
+	if *timer != nil && !(*timer).Stop() {
+		*cancelCh = make(chan struct{})
+	}
+	if t.IsZero() {
+		return
+	}
+
+	if !t.After(time.Now()) {
+		closeCancelCh(*cancelCh)
+		return
+	}
+
+	ch := *cancelCh
+	*timer = time.AfterFunc(time.Until(t), func() {
+		closeCancelCh(ch)
+	})
+}
+
+func closeCancelCh(ch chan struct{}) {
+	select {
+	case <-ch:
+	default:
+		close(ch)
+	}
+}
+
+// SetStreamDeadline bounds how long StreamEvents may wait for its next SSE
+// read before that connection is abandoned and reconnected; it does not
+// affect the ctx passed to StreamEvents.
+func (c *Client) SetStreamDeadline(t time.Time) {
+	c.setDeadline(&c.streamCancelCh, &c.streamTimer, t)
+}
+
+// SetRequestDeadline bounds a single request() call, letting a caller such
+// as WaitForAssistantMessage cap one turn without canceling the session-wide
+// context its polling loop runs under.
+func (c *Client) SetRequestDeadline(t time.Time) {
+	c.setDeadline(&c.requestCancelCh, &c.requestTimer, t)
+}
+
+func (c *Client) streamCancel() chan struct{} {
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+	return c.streamCancelCh
+}
+
+func (c *Client) requestCancel() chan struct{} {
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+	return c.requestCancelCh
+}
+
+// doWithDeadline runs req through c.http, returning errDeadlineExceeded the
+// moment SetRequestDeadline's bound elapses instead of waiting for http.Do
+// to return on its own; the underlying round trip is left to finish in the
+// background (req's context still governs it) and its result is simply
+// discarded via the buffered channel.
+func (c *Client) doWithDeadline(req *http.Request) (*http.Response, error) {
+	type doResult struct {
+		res *http.Response
+		err error
+	}
+	resultCh := make(chan doResult, 1)
+	go func() {
+		res, err := c.http.Do(req)
+		resultCh <- doResult{res: res, err: err}
+	}()
+
+	select {
+	case result := <-resultCh:
+		return result.res, result.err
+	case <-c.requestCancel():
+		return nil, errDeadlineExceeded
+	}
+}
+
 type Event struct {
-	Type      string
-	SessionID string
-	Text      string
-	Final     bool
+	Type        string
+	SessionID   string
+	Text        string
+	Final       bool
+	Attachments []Attachment
+	Parts       []Part
+}
+
+// Attachment describes a file/image artifact an opencode event referenced
+// (e.g. a diff written to disk or a generated image), so the relay can
+// upload it as a Telegram document/photo instead of inlining it as text.
+type Attachment struct {
+	Path string
+	MIME string
+}
+
+// Part is one typed element of an OpenCode message's "parts" array, letting
+// callers distinguish assistant prose from tool activity, reasoning traces,
+// file edits, and usage deltas instead of having it all flattened into a
+// single string. Kind names which of the pointer fields (if any) is set;
+// a plain-text part only ever populates Text.
+type Part struct {
+	Kind       string
+	Text       string
+	ToolCall   *ToolCall
+	ToolResult *ToolResult
+	Reasoning  *Reasoning
+	FileEdit   *FileEdit
+	Usage      *Usage
+}
+
+// ToolCall is a "tool-call" part: the name and arguments OpenCode invoked a
+// tool with.
+type ToolCall struct {
+	ID   string
+	Name string
+	Args map[string]any
+}
+
+// ToolResult is a "tool-result" part: the output a prior ToolCall produced,
+// or the error it failed with.
+type ToolResult struct {
+	ID      string
+	Output  string
+	IsError bool
+}
+
+// Reasoning is a "reasoning" part: a model's intermediate reasoning trace,
+// kept separate from Text so callers can choose to hide or collapse it.
+type Reasoning struct {
+	Text string
+}
+
+// FileEdit is a "file-edit"/"patch" part: a diff OpenCode applied to a file
+// on disk.
+type FileEdit struct {
+	Path  string
+	Patch string
+}
+
+// Usage is a "step-finish"/"usage" part: the token accounting for a
+// completed generation step.
+type Usage struct {
+	InputTokens  int
+	OutputTokens int
+	CacheRead    int
+	Model        string
 }
 
 type SessionSummary struct {
@@ -58,68 +397,204 @@ type ModelInfo struct {
 	Favorite bool
 }
 
+// AssistantSnapshot captures the most recent assistant message seen for a
+// session, including any image/file parts it carries. Revision is
+// Count*1,000,000 plus the length of Last plus the attachment count, so it
+// differs from a previous snapshot's whenever one of those changes (unless a
+// change in Last's length is exactly offset by the attachment count), so
+// callers that incrementally edit a Telegram message (see
+// BridgeService.waitForAssistantResponse) can tell a stale snapshot from one
+// worth re-rendering without diffing Last itself on every poll.
 type AssistantSnapshot struct {
-	Count int
-	Last  string
+	Count       int
+	Last        string
+	Attachments []Attachment
+	Parts       []Part
+	Revision    int
 }
 
-func NewClient(cfg config.Config) *Client {
+// NewClient builds a Client against cfg.OpenCodeServerURLs when set (falling
+// back to the single cfg.OpenCodeServerURL otherwise), failing over between
+// them on connection errors and 5xx responses. sessionEndpoints may be nil,
+// in which case every session always uses the pool's currently-preferred
+// endpoint with no per-session stickiness.
+func NewClient(cfg config.Config, sessionEndpoints ports.SessionEndpointsRepository) *Client {
+	transport := newOpenCodeTransport(
+		cfg.OpenCodeCircuitBreakerThreshold,
+		time.Duration(cfg.OpenCodeCircuitBreakerCooldownMs)*time.Millisecond,
+		cfg.OpenCodeRateLimitPerSecond,
+		cfg.OpenCodeRateLimitBurst,
+	)
+	endpoints := make([]string, 0, len(cfg.OpenCodeServerURLs))
+	for _, url := range cfg.OpenCodeServerURLs {
+		endpoints = append(endpoints, strings.TrimRight(url, "/"))
+	}
+	if len(endpoints) == 0 {
+		endpoints = []string{strings.TrimRight(cfg.OpenCodeServerURL, "/")}
+	}
 	return &Client{
-		baseURL:  strings.TrimRight(cfg.OpenCodeServerURL, "/"),
-		username: cfg.OpenCodeServerUser,
-		password: cfg.OpenCodeServerPass,
-		binary:   cfg.OpenCodeBinary,
-		cliDir:   cfg.OpenCodeCLIWorkDir,
-		timeout:  cfg.OpenCodeTimeout,
-		http:     &http.Client{Timeout: cfg.OpenCodeTimeout},
-		stream:   &http.Client{},
+		baseURL:           endpoints[0],
+		username:          cfg.OpenCodeServerUser,
+		password:          cfg.OpenCodeServerPass,
+		binary:            cfg.OpenCodeBinary,
+		cliDir:            cfg.OpenCodeCLIWorkDir,
+		timeout:           cfg.OpenCodeTimeout,
+		http:              &http.Client{Timeout: cfg.OpenCodeTimeout, Transport: transport},
+		stream:            &http.Client{Transport: transport},
+		retryMaxAttempts:  cfg.OpenCodeRetryMaxAttempts,
+		retryBase:         time.Duration(cfg.OpenCodeRetryBaseMs) * time.Millisecond,
+		retryCap:          time.Duration(cfg.OpenCodeRetryCapMs) * time.Millisecond,
+		streamIdleTimeout: time.Duration(cfg.OpenCodeStreamIdleTimeoutSeconds) * time.Second,
+		streamCancelCh:    make(chan struct{}),
+		requestCancelCh:   make(chan struct{}),
+		endpoints:         endpoints,
+		sessionEndpoints:  sessionEndpoints,
 	}
 }
 
 func CheckConnectivity(ctx context.Context, cfg config.Config) error {
-	client := NewClient(cfg)
+	client := NewClient(cfg, nil)
 	_, err := client.request(ctx, http.MethodGet, "/global/health", nil)
 	return err
 }
 
+// currentEndpoint returns the base URL the pool currently prefers, falling
+// back to baseURL for a Client built as a struct literal (as client_test.go
+// does) rather than via NewClient, which is what always populates endpoints.
+func (c *Client) currentEndpoint() string {
+	if len(c.endpoints) == 0 {
+		return c.baseURL
+	}
+	idx := atomic.LoadInt32(&c.endpointIdx)
+	return c.endpoints[int(idx)%len(c.endpoints)]
+}
+
+// failoverEndpoint advances the pool's preferred endpoint past failedBaseURL
+// so the next attempt tries a different host, wrapping back to the first
+// endpoint after the last one — the same data-center-migration idea MTProto
+// clients use on PHONE_MIGRATE_X, just triggered by connection errors/5xx
+// instead of an explicit redirect reply.
+func (c *Client) failoverEndpoint(failedBaseURL string) {
+	if len(c.endpoints) < 2 {
+		return
+	}
+	idx := atomic.LoadInt32(&c.endpointIdx)
+	if c.endpoints[int(idx)%len(c.endpoints)] != failedBaseURL {
+		return
+	}
+	next := (int(idx) + 1) % len(c.endpoints)
+	atomic.StoreInt32(&c.endpointIdx, int32(next))
+	slog.Default().Warn("opencode failing over to next endpoint",
+		"from", redactURL(failedBaseURL), "to", redactURL(c.endpoints[next]))
+}
+
+// preferSessionEndpoint switches the pool's preferred endpoint to whichever
+// one sessionID was last known to live on, if that endpoint is still in the
+// configured pool. It's a best-effort hint, not a hard pin: a subsequent
+// connection error still fails over normally via failoverEndpoint.
+func (c *Client) preferSessionEndpoint(ctx context.Context, sessionID string) {
+	if c.sessionEndpoints == nil || sessionID == "" {
+		return
+	}
+	baseURL, ok, err := c.sessionEndpoints.GetSessionEndpoint(ctx, sessionID)
+	if err != nil || !ok {
+		return
+	}
+	for i, endpoint := range c.endpoints {
+		if endpoint == baseURL {
+			atomic.StoreInt32(&c.endpointIdx, int32(i))
+			return
+		}
+	}
+}
+
+// rememberSessionEndpoint persists the endpoint sessionID was just served
+// from, so a later reconnect (possibly after a bridge restart) prefers the
+// same OpenCode server instead of whichever one the pool probes next.
+func (c *Client) rememberSessionEndpoint(ctx context.Context, sessionID string) {
+	if c.sessionEndpoints == nil || sessionID == "" {
+		return
+	}
+	_ = c.sessionEndpoints.UpsertSessionEndpoint(ctx, sessionID, c.currentEndpoint())
+}
+
+// PromptAttachment is an inbound file (photo/document/voice) to attach to a
+// prompt sent to OpenCode. Unlike Attachment, which names a path OpenCode
+// itself wrote to, a PromptAttachment carries bytes downloaded from Telegram
+// that haven't touched OpenCode's filesystem.
+type PromptAttachment struct {
+	Filename string
+	MIME     string
+	Data     []byte
+}
+
 func (c *Client) RunPrompt(ctx context.Context, prompt string, sessionID string, model string) (string, error) {
+	resolved, _, err := c.RunPromptWithAttachments(ctx, prompt, sessionID, model, nil)
+	return resolved, err
+}
+
+// RunPromptWithAttachments behaves like RunPrompt but also attaches inbound
+// Telegram photo/document/voice files to the prompt, encoded as file parts
+// OpenCode can read inline. It returns the (possibly newly created) session
+// ID and the OpenCode ID of the user message it posted, so callers such as
+// /retry and /edit can later rewind the session to before that turn.
+func (c *Client) RunPromptWithAttachments(ctx context.Context, prompt string, sessionID string, model string, attachments []PromptAttachment) (string, string, error) {
 	resolved := strings.TrimSpace(sessionID)
 	if resolved == "" {
 		created, err := c.CreateSession(ctx)
 		if err != nil {
-			return "", err
+			return "", "", err
 		}
 		resolved = created
+	} else {
+		c.preferSessionEndpoint(ctx, resolved)
 	}
 
-	body := map[string]any{
-		"parts": []map[string]string{{
-			"type": "text",
-			"text": prompt,
-		}},
-	}
-	if strings.TrimSpace(model) != "" {
-		body["model"] = strings.TrimSpace(model)
-	}
-	if _, err := c.request(ctx, http.MethodPost, "/session/"+resolved+"/message", body); err != nil {
-		if strings.Contains(err.Error(), "status 404") {
+	// WithRetryUnsafe is safe here specifically because this is the initial
+	// send of a prompt: no assistant reply exists yet for this turn, so a
+	// retried POST can't duplicate a reply the user already received.
+	body := promptMessageBody(prompt, model, attachments)
+	raw, err := c.request(ctx, http.MethodPost, "/session/"+resolved+"/message", body, WithRetryUnsafe())
+	if err != nil {
+		if isNotFound(err) {
 			created, createErr := c.CreateSession(ctx)
 			if createErr != nil {
-				return "", createErr
+				return "", "", createErr
 			}
 			resolved = created
-			if _, retryErr := c.request(ctx, http.MethodPost, "/session/"+resolved+"/message", body); retryErr != nil {
-				return "", retryErr
+			retryRaw, retryErr := c.request(ctx, http.MethodPost, "/session/"+resolved+"/message", body, WithRetryUnsafe())
+			if retryErr != nil {
+				return "", "", retryErr
 			}
-			return resolved, nil
+			c.rememberSessionEndpoint(ctx, resolved)
+			return resolved, promptMessageID(retryRaw), nil
 		}
-		return "", err
+		return "", "", err
+	}
+	c.rememberSessionEndpoint(ctx, resolved)
+	return resolved, promptMessageID(raw), nil
+}
+
+// promptMessageID extracts the ID of the user message OpenCode just created
+// from a /session/{id}/message response body, returning "" if the shape is
+// unexpected rather than failing the whole prompt call.
+func promptMessageID(raw []byte) string {
+	var payload map[string]any
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return ""
 	}
-	return resolved, nil
+	if info, ok := payload["info"].(map[string]any); ok {
+		if id := firstString(info, "id"); id != "" {
+			return id
+		}
+	}
+	return firstString(payload, "id", "messageID", "messageId")
 }
 
 func (c *Client) CreateSession(ctx context.Context) (string, error) {
-	raw, err := c.request(ctx, http.MethodPost, "/session", nil)
+	// Creating a session has no prior state to duplicate, so retrying this
+	// POST on a transient failure is safe.
+	raw, err := c.request(ctx, http.MethodPost, "/session", nil, WithRetryUnsafe())
 	if err != nil {
 		return "", err
 	}
@@ -132,9 +607,32 @@ func (c *Client) CreateSession(ctx context.Context) (string, error) {
 	if payload.ID == "" {
 		return "", fmt.Errorf("opencode create session returned empty id")
 	}
+	c.rememberSessionEndpoint(ctx, payload.ID)
 	return payload.ID, nil
 }
 
+// promptMessageBody builds the /session/{id}/message request body for a
+// prompt, encoding any attachments as file parts carrying a base64 data URL
+// so OpenCode can read them without a shared filesystem with the bridge.
+func promptMessageBody(prompt string, model string, attachments []PromptAttachment) map[string]any {
+	parts := make([]map[string]string, 0, len(attachments)+1)
+	parts = append(parts, map[string]string{"type": "text", "text": prompt})
+	for _, attachment := range attachments {
+		parts = append(parts, map[string]string{
+			"type":     "file",
+			"mime":     attachment.MIME,
+			"filename": attachment.Filename,
+			"url":      "data:" + attachment.MIME + ";base64," + base64.StdEncoding.EncodeToString(attachment.Data),
+		})
+	}
+
+	body := map[string]any{"parts": parts}
+	if strings.TrimSpace(model) != "" {
+		body["model"] = strings.TrimSpace(model)
+	}
+	return body
+}
+
 func (c *Client) GetLastAssistantMessage(ctx context.Context, sessionID string) (string, error) {
 	snapshot, err := c.GetAssistantSnapshot(ctx, sessionID)
 	if err != nil {
@@ -156,27 +654,81 @@ func (c *Client) GetAssistantSnapshot(ctx context.Context, sessionID string) (As
 
 	count := 0
 	last := ""
+	var lastAttachments []Attachment
+	var lastParts []Part
+	lastSet := false
 	fallback := ""
 	for i := len(messages) - 1; i >= 0; i-- {
 		message := messages[i]
 		role, _ := message["role"].(string)
-		text := extractText(message)
-		if fallback == "" && strings.TrimSpace(text) != "" && !isUserRole(role) {
-			fallback = strings.TrimSpace(text)
+		parts := extractParts(message)
+		text := strings.TrimSpace(RenderAsText(parts))
+		if fallback == "" && text != "" && !isUserRole(role) {
+			fallback = text
 		}
 		if !isAssistantRole(role) {
 			continue
 		}
 		count++
-		if last == "" && strings.TrimSpace(text) != "" {
-			last = strings.TrimSpace(text)
+		if lastSet {
+			continue
+		}
+		if attachments := extractMessageAttachments(message); text != "" || len(attachments) > 0 {
+			last = text
+			lastAttachments = attachments
+			lastParts = parts
+			lastSet = true
 		}
 	}
-	if last == "" {
+	if last == "" && !lastSet {
 		last = fallback
 	}
 
-	return AssistantSnapshot{Count: count, Last: last}, nil
+	revision := count*1_000_000 + len(last) + len(lastAttachments)
+	return AssistantSnapshot{Count: count, Last: last, Attachments: lastAttachments, Parts: lastParts, Revision: revision}, nil
+}
+
+// extractMessageAttachments pulls image/file parts out of a message from
+// /session/{id}/message, as opposed to extractAttachments which reads the
+// top-level "attachments" field of an SSE event payload.
+func extractMessageAttachments(raw map[string]any) []Attachment {
+	parts, ok := raw["parts"].([]any)
+	if !ok {
+		return nil
+	}
+	out := make([]Attachment, 0, len(parts))
+	for _, item := range parts {
+		part, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		partType := firstString(part, "type")
+		if partType != "file" && partType != "image" {
+			continue
+		}
+		path := firstString(part, "path", "file", "filePath")
+		if path == "" {
+			continue
+		}
+		out = append(out, Attachment{Path: path, MIME: firstString(part, "mime", "mimeType")})
+	}
+	return out
+}
+
+// SetTimeout changes the per-request/CLI-turn timeout while the client is in
+// use, e.g. from config.Manager's "OPENCODE_TIMEOUT_MS" subscriber, so an
+// admin override takes effect on the next call without a restart.
+func (c *Client) SetTimeout(d time.Duration) {
+	c.timeoutMu.Lock()
+	c.timeout = d
+	c.http.Timeout = d
+	c.timeoutMu.Unlock()
+}
+
+func (c *Client) currentTimeout() time.Duration {
+	c.timeoutMu.RLock()
+	defer c.timeoutMu.RUnlock()
+	return c.timeout
 }
 
 func (c *Client) WaitForAssistantMessage(ctx context.Context, sessionID string, previous AssistantSnapshot, interval time.Duration) (string, error) {
@@ -184,8 +736,17 @@ func (c *Client) WaitForAssistantMessage(ctx context.Context, sessionID string,
 		interval = 2 * time.Second
 	}
 
+	turnTimeout := c.currentTimeout()
+	if turnTimeout <= 0 {
+		turnTimeout = defaultRetryCap
+	}
+
 	for {
+		// Bound this one snapshot request so a single slow turn can't hang
+		// the poll loop, without touching ctx, which spans the whole wait.
+		c.SetRequestDeadline(time.Now().Add(turnTimeout))
 		now, err := c.GetAssistantSnapshot(ctx, sessionID)
+		c.SetRequestDeadline(time.Time{})
 		if err != nil {
 			return "", err
 		}
@@ -211,6 +772,35 @@ func (c *Client) CompactSession(ctx context.Context, sessionID string) error {
 	return err
 }
 
+// AbortSession tells OpenCode to stop generating for sessionID, used when a
+// user cancels an in-flight prompt so the remote agent actually halts
+// instead of continuing to run after the bridge stops polling it.
+func (c *Client) AbortSession(ctx context.Context, sessionID string) error {
+	_, err := c.request(ctx, http.MethodPost, "/session/"+sessionID+"/abort", nil)
+	return err
+}
+
+// RewindSession deletes each of messageIDs from sessionID, used by /retry and
+// /edit to undo the last prompt turn before re-running it so the session
+// doesn't just accumulate duplicate turns. It's best-effort: a message that's
+// already gone (status 404) is treated as success, since the desired end
+// state (message absent) already holds.
+func (c *Client) RewindSession(ctx context.Context, sessionID string, messageIDs []string) error {
+	for _, messageID := range messageIDs {
+		messageID = strings.TrimSpace(messageID)
+		if messageID == "" {
+			continue
+		}
+		if _, err := c.request(ctx, http.MethodDelete, "/session/"+sessionID+"/message/"+messageID, nil, WithRetryUnsafe()); err != nil {
+			if isNotFound(err) {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
 func (c *Client) GetStatus(ctx context.Context, sessionID string) (StatusReport, error) {
 	if strings.TrimSpace(sessionID) == "" {
 		return StatusReport{SessionID: "", Status: "unknown", Model: ""}, nil
@@ -339,7 +929,7 @@ func (c *Client) listSessionsFromCLI(ctx context.Context) ([]SessionSummary, err
 		return nil, fmt.Errorf("opencode binary is empty")
 	}
 
-	cmdCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	cmdCtx, cancel := context.WithTimeout(ctx, c.currentTimeout())
 	defer cancel()
 	cmd := exec.CommandContext(cmdCtx, c.binary, "session", "list")
 	if strings.TrimSpace(c.cliDir) != "" {
@@ -440,6 +1030,27 @@ func maxInt(a int, b int) int {
 	return b
 }
 
+// streamRetryBase and streamRetryCap bound the backoff StreamEvents uses
+// between reconnect attempts to /event, distinct from the request retry
+// knobs since a dropped long-lived stream warrants its own, wider cap.
+const (
+	streamRetryBase = 500 * time.Millisecond
+	streamRetryCap  = 30 * time.Second
+)
+
+// defaultStreamIdleTimeout is the StreamEvents read watchdog fallback when a
+// Client wasn't built through NewClient (e.g. in tests).
+const defaultStreamIdleTimeout = 90 * time.Second
+
+// StreamEvents opens a long-lived SSE connection to /event and reconnects
+// with jittered exponential backoff whenever the connection drops: on a scan
+// error, a non-2xx status, or the idle-read watchdog force-closing the body
+// after streamIdleTimeout with no bytes. Last-Event-ID is carried across
+// reconnects from the most recent "id:" line seen, so OpenCode can resume
+// from where the dropped connection left off instead of replaying
+// everything. Each reconnect also emits a synthetic Event{Type: "reconnect"}
+// (no SessionID, so RelayService.handleEvent ignores it) so callers that
+// care about gaps can still see one.
 func (c *Client) StreamEvents(ctx context.Context) (<-chan Event, <-chan error) {
 	events := make(chan Event)
 	errs := make(chan error, 1)
@@ -448,40 +1059,136 @@ func (c *Client) StreamEvents(ctx context.Context) (<-chan Event, <-chan error)
 		defer close(events)
 		defer close(errs)
 
-		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+"/event", nil)
-		if err != nil {
-			errs <- err
-			return
-		}
-		req.SetBasicAuth(c.username, c.password)
-		req.Header.Set("Accept", "text/event-stream")
+		lastEventID := ""
+		attempt := 0
+		for {
+			if ctx.Err() != nil {
+				return
+			}
 
-		res, err := c.stream.Do(req)
-		if err != nil {
-			errs <- err
-			return
+			sawEvent, err := c.streamOnce(ctx, &lastEventID, events)
+			if ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				streamReconnectTotal.Inc()
+				slog.Default().Warn("opencode event stream disconnected, reconnecting", "attempt", attempt+1, "error", err)
+			}
+			if sawEvent {
+				attempt = 0
+			}
+
+			select {
+			case events <- Event{Type: "reconnect"}:
+			case <-ctx.Done():
+				return
+			}
+
+			wait := backoffWithJitter(attempt, streamRetryBase, streamRetryCap)
+			attempt++
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
 		}
-		defer res.Body.Close()
+	}()
 
-		if res.StatusCode >= 400 {
-			errs <- fmt.Errorf("opencode event stream status %d", res.StatusCode)
-			return
+	return events, errs
+}
+
+// streamOnce dials /event once, carrying lastEventID as a Last-Event-ID
+// header when set, and forwards parsed events until the connection ends for
+// any reason. It returns whether at least one event was successfully parsed
+// and forwarded, used by StreamEvents to decide whether to reset its backoff.
+func (c *Client) streamOnce(ctx context.Context, lastEventID *string, events chan<- Event) (bool, error) {
+	base := c.currentEndpoint()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, base+"/event", nil)
+	if err != nil {
+		return false, err
+	}
+	req.SetBasicAuth(c.username, c.password)
+	req.Header.Set("Accept", "text/event-stream")
+	if *lastEventID != "" {
+		req.Header.Set("Last-Event-ID", *lastEventID)
+	}
+
+	res, err := c.stream.Do(req)
+	if err != nil {
+		c.failoverEndpoint(base)
+		return false, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode >= 400 {
+		if res.StatusCode >= 500 {
+			c.failoverEndpoint(base)
 		}
+		return false, fmt.Errorf("opencode event stream status %d", res.StatusCode)
+	}
+
+	idleTimeout := c.streamIdleTimeout
+	if idleTimeout <= 0 {
+		idleTimeout = defaultStreamIdleTimeout
+	}
+	idleTimer := time.AfterFunc(idleTimeout, func() { _ = res.Body.Close() })
+	defer idleTimer.Stop()
 
-		scanner := bufio.NewScanner(res.Body)
-		buffer := make([]byte, 0, 64*1024)
-		scanner.Buffer(buffer, 4*1024*1024)
+	scanner := bufio.NewScanner(res.Body)
+	buffer := make([]byte, 0, 64*1024)
+	scanner.Buffer(buffer, 4*1024*1024)
 
-		var data bytes.Buffer
+	// scanner.Scan() blocks with no way to select on it directly, so it runs
+	// on its own goroutine and reports each line (or the terminal error)
+	// over lines; done unblocks a send once this call returns so that
+	// goroutine never leaks past a deadline/ctx-triggered exit.
+	type scanResult struct {
+		line string
+		ok   bool
+		err  error
+	}
+	done := make(chan struct{})
+	defer close(done)
+	lines := make(chan scanResult)
+	go func() {
 		for scanner.Scan() {
-			line := scanner.Text()
+			select {
+			case lines <- scanResult{line: scanner.Text(), ok: true}:
+			case <-done:
+				return
+			}
+		}
+		select {
+		case lines <- scanResult{ok: false, err: scanner.Err()}:
+		case <-done:
+		}
+	}()
+
+	sawEvent := false
+	var data bytes.Buffer
+	for {
+		select {
+		case <-ctx.Done():
+			return sawEvent, nil
+		case <-c.streamCancel():
+			return sawEvent, errDeadlineExceeded
+		case result := <-lines:
+			if !result.ok {
+				if result.err != nil && ctx.Err() == nil {
+					return sawEvent, result.err
+				}
+				return sawEvent, nil
+			}
+			idleTimer.Reset(idleTimeout)
+			line := result.line
 			if line == "" {
 				event, ok := parseSSEData(strings.TrimSpace(data.String()))
 				if ok {
 					select {
 					case events <- event:
+						sawEvent = true
 					case <-ctx.Done():
-						return
+						return sawEvent, nil
 					}
 				}
 				data.Reset()
@@ -489,18 +1196,160 @@ func (c *Client) StreamEvents(ctx context.Context) (<-chan Event, <-chan error)
 			}
 			if strings.HasPrefix(line, "data:") {
 				data.WriteString(strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+				continue
+			}
+			if strings.HasPrefix(line, "id:") {
+				*lastEventID = strings.TrimSpace(strings.TrimPrefix(line, "id:"))
 			}
 		}
+	}
+}
+
+// requestOptions configures a single request call; see WithRetryUnsafe.
+type requestOptions struct {
+	retryUnsafe bool
+}
+
+type requestOption func(*requestOptions)
+
+// WithRetryUnsafe opts a request using a non-idempotent HTTP method (e.g.
+// POST) into the same transient-failure retry behavior GET/HEAD/OPTIONS get
+// by default. Only pass this when the specific endpoint is known to be safe
+// to repeat, e.g. RewindSession's DELETE, which already treats "already
+// gone" (404) as success.
+func WithRetryUnsafe() requestOption {
+	return func(o *requestOptions) { o.retryUnsafe = true }
+}
+
+// defaultRetry* are the request fallbacks when a Client wasn't built through
+// NewClient (e.g. in tests), so retry behavior is never silently disabled by
+// a zero value.
+const (
+	defaultRetryMaxAttempts = 3
+	defaultRetryBase        = 250 * time.Millisecond
+	defaultRetryCap         = 5 * time.Second
+)
+
+// isIdempotentMethod reports whether method is safe to retry by default
+// without a caller asserting it via WithRetryUnsafe.
+func isIdempotentMethod(method string) bool {
+	switch strings.ToUpper(method) {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
+
+// isRetryableError reports whether err represents a transient OpenCode
+// failure worth retrying: a timeout or connection failure, a 502/503/504
+// upstream hiccup, or a 429 the server asked us to back off from. Every
+// other 4xx is treated as a permanent rejection of the request and never
+// retried.
+func isRetryableError(err error) bool {
+	var ocErr *OpenCodeError
+	if !errors.As(err, &ocErr) {
+		return false
+	}
+	if ocErr.Kind == KindTimeout || ocErr.Kind == KindUnavailable {
+		return true
+	}
+	switch ocErr.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// backoffWithJitter implements full-jitter exponential backoff: a uniform
+// random duration between 0 and min(cap, base*2^attempt). attempt is
+// 0-indexed on the failed attempt that just happened.
+func backoffWithJitter(attempt int, base, cap time.Duration) time.Duration {
+	if base <= 0 {
+		base = defaultRetryBase
+	}
+	if cap <= 0 {
+		cap = defaultRetryCap
+	}
+	if attempt > 30 {
+		attempt = 30
+	}
+	upper := base * time.Duration(int64(1)<<uint(attempt))
+	if upper <= 0 || upper > cap {
+		upper = cap
+	}
+	if upper <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(upper) + 1))
+}
+
+// retryDelay picks how long to wait before the next attempt, honoring a
+// server-supplied Retry-After over the computed backoff when present.
+func retryDelay(err error, attempt int, base, cap time.Duration) time.Duration {
+	var ocErr *OpenCodeError
+	if errors.As(err, &ocErr) && ocErr.RetryAfter > 0 {
+		return ocErr.RetryAfter
+	}
+	return backoffWithJitter(attempt, base, cap)
+}
+
+// request sends an OpenCode API call, retrying transient failures
+// (isRetryableError) with exponential backoff and full jitter. Only
+// idempotent methods (GET/HEAD/OPTIONS) are retried by default; pass
+// WithRetryUnsafe for a caller-verified-safe mutating request.
+func (c *Client) request(ctx context.Context, method string, path string, body any, opts ...requestOption) ([]byte, error) {
+	options := requestOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	retryable := options.retryUnsafe || isIdempotentMethod(method)
+
+	maxAttempts := c.retryMaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultRetryMaxAttempts
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < maxAttempts; attempt++ {
+		raw, err := c.doRequest(ctx, method, path, body)
+		if err == nil {
+			return raw, nil
+		}
+		lastErr = err
 
-		if err := scanner.Err(); err != nil && ctx.Err() == nil {
-			errs <- err
+		if ctx.Err() != nil || !retryable || attempt == maxAttempts-1 || !isRetryableError(err) {
+			return nil, err
 		}
-	}()
 
-	return events, errs
+		wait := retryDelay(err, attempt, c.retryBase, c.retryCap)
+		slog.Default().Warn("opencode request retrying after transient failure",
+			"method", method, "path", path, "attempt", attempt+1, "max_attempts", maxAttempts,
+			"wait_ms", wait.Milliseconds(), "error", err)
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+	return nil, lastErr
+}
+
+func (c *Client) doRequest(ctx context.Context, method string, path string, body any) ([]byte, error) {
+	start := time.Now()
+	raw, err := c.doRequestInner(ctx, method, path, body)
+	requestDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		requestTotal.WithLabelValue("error").Inc()
+	} else {
+		requestTotal.WithLabelValue("ok").Inc()
+	}
+	return raw, err
 }
 
-func (c *Client) request(ctx context.Context, method string, path string, body any) ([]byte, error) {
+func (c *Client) doRequestInner(ctx context.Context, method string, path string, body any) ([]byte, error) {
 	var payload io.Reader
 	if body != nil {
 		raw, err := json.Marshal(body)
@@ -510,7 +1359,9 @@ func (c *Client) request(ctx context.Context, method string, path string, body a
 		payload = bytes.NewReader(raw)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, payload)
+	base := c.currentEndpoint()
+	requestURL := base + path
+	req, err := http.NewRequestWithContext(ctx, method, requestURL, payload)
 	if err != nil {
 		return nil, err
 	}
@@ -520,22 +1371,47 @@ func (c *Client) request(ctx context.Context, method string, path string, body a
 	req.Header.Set("Accept", "application/json")
 	req.SetBasicAuth(c.username, c.password)
 
-	res, err := c.http.Do(req)
+	res, err := c.doWithDeadline(req)
 	if err != nil {
-		return nil, err
+		if errors.Is(err, errDeadlineExceeded) {
+			return nil, &OpenCodeError{Kind: KindTimeout, URL: redactURL(requestURL), Err: err}
+		}
+		kind := KindUnavailable
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			kind = KindTimeout
+		} else {
+			c.failoverEndpoint(base)
+		}
+		return nil, &OpenCodeError{Kind: kind, URL: redactURL(requestURL), Err: err}
 	}
 	defer res.Body.Close()
 
+	if isNoContentStatus(res.StatusCode) {
+		_, _ = io.Copy(io.Discard, res.Body)
+		return []byte("{}"), nil
+	}
+
 	raw, readErr := io.ReadAll(res.Body)
 	if readErr != nil {
-		return nil, readErr
+		_, _ = io.Copy(io.Discard, res.Body)
+		return nil, &OpenCodeError{Kind: KindUnavailable, URL: redactURL(requestURL), Err: readErr}
 	}
 	if res.StatusCode >= 400 {
 		msg := strings.TrimSpace(string(raw))
 		if msg == "" {
 			msg = fmt.Sprintf("opencode status %d", res.StatusCode)
 		}
-		return nil, fmt.Errorf("%s (status %d)", msg, res.StatusCode)
+		if res.StatusCode >= 500 {
+			c.failoverEndpoint(base)
+		}
+		return nil, &OpenCodeError{
+			Kind:       classifyStatus(res.StatusCode),
+			StatusCode: res.StatusCode,
+			URL:        redactURL(requestURL),
+			Detail:     extractErrorDetail(res.Header.Get("Content-Type"), raw),
+			RetryAfter: parseRetryAfter(res.Header.Get("Retry-After")),
+			Err:        fmt.Errorf("%s (status %d)", msg, res.StatusCode),
+		}
 	}
 	if len(bytes.TrimSpace(raw)) == 0 {
 		return []byte("{}"), nil
@@ -564,7 +1440,8 @@ func parseSSEData(data string) (Event, bool) {
 	}
 
 	sessionID := firstString(payload, "sessionID", "sessionId", "session", "id")
-	text := extractText(payload)
+	parts := extractParts(payload)
+	text := RenderAsText(parts)
 
 	final := false
 	if value, ok := payload["final"].(bool); ok {
@@ -577,7 +1454,27 @@ func parseSSEData(data string) (Event, bool) {
 		final = true
 	}
 
-	return Event{Type: eventType, SessionID: sessionID, Text: strings.TrimSpace(text), Final: final}, true
+	return Event{Type: eventType, SessionID: sessionID, Text: strings.TrimSpace(text), Final: final, Attachments: extractAttachments(payload), Parts: parts}, true
+}
+
+func extractAttachments(raw map[string]any) []Attachment {
+	rawAttachments, ok := raw["attachments"].([]any)
+	if !ok {
+		return nil
+	}
+	out := make([]Attachment, 0, len(rawAttachments))
+	for _, item := range rawAttachments {
+		entry, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		path := firstString(entry, "path", "file", "filePath")
+		if path == "" {
+			continue
+		}
+		out = append(out, Attachment{Path: path, MIME: firstString(entry, "mime", "mimeType")})
+	}
+	return out
 }
 
 func firstString(raw map[string]any, keys ...string) string {
@@ -597,28 +1494,146 @@ func firstString(raw map[string]any, keys ...string) string {
 }
 
 func extractText(raw map[string]any) string {
+	return RenderAsText(extractParts(raw))
+}
+
+// extractParts walks every element of raw["parts"], classifying each one by
+// its OpenCode "type" field into a typed Part instead of flattening
+// everything to text. A flat top-level text/content/message field (as some
+// event payloads carry instead of a parts array) is wrapped as a single
+// text Part so callers only ever need to look at Parts.
+func extractParts(raw map[string]any) []Part {
 	if text := firstString(raw, "text", "content", "message"); text != "" {
-		return text
+		return []Part{{Kind: "text", Text: text}}
 	}
 
-	parts, ok := raw["parts"].([]any)
+	rawParts, ok := raw["parts"].([]any)
 	if !ok {
-		return ""
+		return nil
 	}
-	chunks := make([]string, 0, len(parts))
-	for _, item := range parts {
+	parts := make([]Part, 0, len(rawParts))
+	for _, item := range rawParts {
 		part, ok := item.(map[string]any)
 		if !ok {
 			continue
 		}
-		text := firstString(part, "text", "content")
-		if text != "" {
-			chunks = append(chunks, text)
+		parts = append(parts, partFromPayload(part))
+	}
+	return parts
+}
+
+// partFromPayload classifies a single element of a "parts" array by its
+// OpenCode "type" field. Unrecognized types fall back to a text Part when a
+// text/content field is present, and otherwise keep their raw type as Kind
+// with no populated sub-payload, so callers can still see that the part
+// existed without the process failing on an OpenCode schema addition.
+func partFromPayload(part map[string]any) Part {
+	switch firstString(part, "type") {
+	case "tool", "tool-call", "tool_call":
+		args, _ := part["args"].(map[string]any)
+		if args == nil {
+			args, _ = part["input"].(map[string]any)
+		}
+		return Part{
+			Kind: "tool_call",
+			ToolCall: &ToolCall{
+				ID:   firstString(part, "id", "toolCallID", "callID"),
+				Name: firstString(part, "tool", "name"),
+				Args: args,
+			},
+		}
+	case "tool-result", "tool_result":
+		return Part{
+			Kind: "tool_result",
+			ToolResult: &ToolResult{
+				ID:      firstString(part, "id", "toolCallID", "callID"),
+				Output:  firstString(part, "output", "result", "text"),
+				IsError: firstBool(part, "isError", "error"),
+			},
+		}
+	case "reasoning":
+		return Part{
+			Kind:      "reasoning",
+			Reasoning: &Reasoning{Text: firstString(part, "text", "content")},
+		}
+	case "file-edit", "patch", "file":
+		return Part{
+			Kind: "file_edit",
+			FileEdit: &FileEdit{
+				Path:  firstString(part, "path", "file", "filePath"),
+				Patch: firstString(part, "patch", "diff", "content"),
+			},
+		}
+	case "step-finish", "usage":
+		return usagePart(part)
+	default:
+		if text := firstString(part, "text", "content"); text != "" {
+			return Part{Kind: "text", Text: text}
+		}
+		return Part{Kind: firstString(part, "type")}
+	}
+}
+
+// usagePart reads the token accounting OpenCode attaches to a "step-finish"
+// part, tolerating either a "tokens" or "usage" key and either a nested
+// cache.read count or a flat cacheRead one, since the exact shape has
+// drifted across OpenCode server versions.
+func usagePart(part map[string]any) Part {
+	usage := &Usage{Model: firstString(part, "model", "modelID")}
+	tokens, ok := part["tokens"].(map[string]any)
+	if !ok {
+		tokens, _ = part["usage"].(map[string]any)
+	}
+	if tokens != nil {
+		usage.InputTokens = firstInt(tokens, "input", "inputTokens")
+		usage.OutputTokens = firstInt(tokens, "output", "outputTokens")
+		if cache, ok := tokens["cache"].(map[string]any); ok {
+			usage.CacheRead = firstInt(cache, "read")
+		} else {
+			usage.CacheRead = firstInt(tokens, "cacheRead", "cache_read")
+		}
+	}
+	return Part{Kind: "usage", Usage: usage}
+}
+
+// RenderAsText is the compatibility path back to the pre-Part string
+// rendering: it joins every text Part (plain assistant prose), ignoring
+// tool calls, reasoning, file edits, and usage, so existing callers that
+// only want the message body keep working unchanged against the new
+// typed Parts.
+func RenderAsText(parts []Part) string {
+	chunks := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if part.Kind == "text" && part.Text != "" {
+			chunks = append(chunks, part.Text)
 		}
 	}
 	return strings.Join(chunks, "\n")
 }
 
+// firstBool returns the first of keys present on raw with a bool value,
+// defaulting to false, mirroring firstString's "first match wins" lookup.
+func firstBool(raw map[string]any, keys ...string) bool {
+	for _, key := range keys {
+		if value, ok := raw[key].(bool); ok {
+			return value
+		}
+	}
+	return false
+}
+
+// firstInt returns the first of keys present on raw with a numeric value,
+// defaulting to 0. JSON numbers decode as float64, so this truncates rather
+// than parsing an int directly.
+func firstInt(raw map[string]any, keys ...string) int {
+	for _, key := range keys {
+		if value, ok := raw[key].(float64); ok {
+			return int(value)
+		}
+	}
+	return 0
+}
+
 func sessionToSummary(raw map[string]any) SessionSummary {
 	id := firstString(raw, "id")
 	title := firstString(raw, "title")
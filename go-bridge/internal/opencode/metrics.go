@@ -0,0 +1,13 @@
+package opencode
+
+import "github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/metrics"
+
+var (
+	requestDuration = metrics.Default.RegisterHistogram(metrics.NewHistogram(
+		"opencode_request_duration_seconds", "Duration of OpenCode API requests.",
+		[]float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}))
+	requestTotal = metrics.Default.Register(metrics.NewCounterVec(
+		"opencode_request_total", "OpenCode API requests by outcome.", "result"))
+	streamReconnectTotal = metrics.Default.RegisterCounter(metrics.NewCounter(
+		"opencode_stream_reconnect_total", "Times the OpenCode SSE event stream was reconnected after dropping."))
+)
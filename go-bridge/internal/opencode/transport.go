@@ -0,0 +1,202 @@
+package opencode
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrUpstreamUnavailable is returned (wrapped in an OpenCodeError with
+// KindUnavailable, same as any other transport failure) when a per-host
+// circuit breaker is open, so a server that's already failing doesn't also
+// get buried under every request the bridge would otherwise have retried
+// against it.
+var ErrUpstreamUnavailable = errors.New("opencode: upstream circuit breaker open")
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// circuitBreaker is a per-host breaker: it stays closed while consecutive
+// failures remain under failureThreshold, opens (short-circuiting every
+// request) for cooldown once that threshold is crossed, then lets exactly
+// one half-open probe through to decide whether to close again or reopen.
+type circuitBreaker struct {
+	failureThreshold int
+	cooldown         time.Duration
+
+	mu          sync.Mutex
+	state       breakerState
+	consecutive int
+	openedAt    time.Time
+}
+
+func newCircuitBreaker(failureThreshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{failureThreshold: failureThreshold, cooldown: cooldown}
+}
+
+// allow reports whether a request against host may proceed, transitioning
+// open to half-open once cooldown has elapsed. Exactly one caller observes
+// that transition (and gets true); any other caller that finds the breaker
+// already half-open is short-circuited like an open breaker, so only the
+// probe that triggered the transition is in flight at a time.
+func (b *circuitBreaker) allow(host string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = breakerHalfOpen
+		slog.Default().Warn("opencode circuit breaker half-open, probing", "host", host)
+		return true
+	case breakerHalfOpen:
+		return false
+	default:
+		return true
+	}
+}
+
+func (b *circuitBreaker) recordSuccess(host string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != breakerClosed {
+		slog.Default().Info("opencode circuit breaker closed", "host", host)
+	}
+	b.state = breakerClosed
+	b.consecutive = 0
+}
+
+func (b *circuitBreaker) recordFailure(host string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state == breakerHalfOpen {
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+		slog.Default().Warn("opencode circuit breaker reopened after failed probe", "host", host)
+		return
+	}
+	b.consecutive++
+	if b.state == breakerClosed && b.consecutive >= b.failureThreshold {
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+		slog.Default().Warn("opencode circuit breaker opened", "host", host, "consecutive_failures", b.consecutive)
+	}
+}
+
+// hostCircuitBreakers tracks one circuitBreaker per upstream host, in case
+// a Client's baseURL (and therefore its OpenCode server) ever changes over
+// the transport's lifetime, so breaker state for one host never leaks into
+// another.
+type hostCircuitBreakers struct {
+	failureThreshold int
+	cooldown         time.Duration
+
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+func newHostCircuitBreakers(failureThreshold int, cooldown time.Duration) *hostCircuitBreakers {
+	return &hostCircuitBreakers{failureThreshold: failureThreshold, cooldown: cooldown, breakers: map[string]*circuitBreaker{}}
+}
+
+func (h *hostCircuitBreakers) forHost(host string) *circuitBreaker {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	b, ok := h.breakers[host]
+	if !ok {
+		b = newCircuitBreaker(h.failureThreshold, h.cooldown)
+		h.breakers[host] = b
+	}
+	return b
+}
+
+// tokenBucket is a token-bucket rate limiter: it refills at refillPerSecond
+// tokens/sec up to burst capacity, and wait blocks the caller until a token
+// is available or ctx is done.
+type tokenBucket struct {
+	refillPerSecond float64
+	burst           float64
+
+	mu         sync.Mutex
+	tokens     float64
+	lastRefill time.Time
+}
+
+func newTokenBucket(refillPerSecond float64, burst float64) *tokenBucket {
+	return &tokenBucket{refillPerSecond: refillPerSecond, burst: burst, tokens: burst, lastRefill: time.Now()}
+}
+
+func (b *tokenBucket) wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens += now.Sub(b.lastRefill).Seconds() * b.refillPerSecond
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.lastRefill = now
+
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - b.tokens) / b.refillPerSecond * float64(time.Second))
+		b.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
+
+// openCodeTransport is the http.RoundTripper middleware installed on both
+// Client.http and Client.stream: it waits for the rate limiter, then
+// short-circuits to ErrUpstreamUnavailable while the target host's circuit
+// breaker is open, recording the outcome of every round trip that does go
+// through so repeated upstream failures trip the breaker.
+type openCodeTransport struct {
+	next     http.RoundTripper
+	breakers *hostCircuitBreakers
+	limiter  *tokenBucket
+}
+
+func newOpenCodeTransport(breakerThreshold int, breakerCooldown time.Duration, rateLimitPerSecond, rateLimitBurst int) *openCodeTransport {
+	return &openCodeTransport{
+		next:     http.DefaultTransport,
+		breakers: newHostCircuitBreakers(breakerThreshold, breakerCooldown),
+		limiter:  newTokenBucket(float64(rateLimitPerSecond), float64(rateLimitBurst)),
+	}
+}
+
+func (t *openCodeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter.wait(req.Context()); err != nil {
+		return nil, err
+	}
+
+	host := req.URL.Host
+	breaker := t.breakers.forHost(host)
+	if !breaker.allow(host) {
+		return nil, ErrUpstreamUnavailable
+	}
+
+	res, err := t.next.RoundTrip(req)
+	if err != nil || (res != nil && res.StatusCode >= 500) {
+		breaker.recordFailure(host)
+		return res, err
+	}
+	breaker.recordSuccess(host)
+	return res, nil
+}
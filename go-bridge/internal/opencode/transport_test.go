@@ -0,0 +1,147 @@
+package opencode
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerOpensAfterThresholdFailures(t *testing.T) {
+	breaker := newCircuitBreaker(2, time.Hour)
+
+	if !breaker.allow("host") {
+		t.Fatalf("expected a fresh breaker to allow requests")
+	}
+	breaker.recordFailure("host")
+	if !breaker.allow("host") {
+		t.Fatalf("expected the breaker to still allow requests below the threshold")
+	}
+	breaker.recordFailure("host")
+	if breaker.allow("host") {
+		t.Fatalf("expected the breaker to open once the failure threshold is reached")
+	}
+}
+
+func TestCircuitBreakerHalfOpenAllowsOneProbeAfterCooldown(t *testing.T) {
+	breaker := newCircuitBreaker(1, 10*time.Millisecond)
+
+	breaker.recordFailure("host")
+	if breaker.allow("host") {
+		t.Fatalf("expected the breaker to be open immediately after tripping")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if !breaker.allow("host") {
+		t.Fatalf("expected the breaker to let a half-open probe through after cooldown")
+	}
+	if breaker.allow("host") {
+		t.Fatalf("expected a second concurrent caller to be denied while a probe is in flight")
+	}
+}
+
+func TestCircuitBreakerSuccessfulProbeCloses(t *testing.T) {
+	breaker := newCircuitBreaker(1, 10*time.Millisecond)
+
+	breaker.recordFailure("host")
+	time.Sleep(20 * time.Millisecond)
+	if !breaker.allow("host") {
+		t.Fatalf("expected the half-open probe to be allowed")
+	}
+	breaker.recordSuccess("host")
+
+	if !breaker.allow("host") {
+		t.Fatalf("expected the breaker to be closed after a successful probe")
+	}
+}
+
+func TestCircuitBreakerFailedProbeReopens(t *testing.T) {
+	breaker := newCircuitBreaker(1, 10*time.Millisecond)
+
+	breaker.recordFailure("host")
+	time.Sleep(20 * time.Millisecond)
+	if !breaker.allow("host") {
+		t.Fatalf("expected the half-open probe to be allowed")
+	}
+	breaker.recordFailure("host")
+
+	if breaker.allow("host") {
+		t.Fatalf("expected the breaker to reopen after a failed probe")
+	}
+}
+
+func TestTokenBucketBlocksUntilTokensRefill(t *testing.T) {
+	bucket := newTokenBucket(100, 1)
+
+	if err := bucket.wait(context.Background()); err != nil {
+		t.Fatalf("expected the first token to be available immediately, got %v", err)
+	}
+
+	start := time.Now()
+	if err := bucket.wait(context.Background()); err != nil {
+		t.Fatalf("expected the second token to eventually refill, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 5*time.Millisecond {
+		t.Fatalf("expected wait to block for refill, took %v", elapsed)
+	}
+}
+
+func TestTokenBucketWaitRespectsContextCancellation(t *testing.T) {
+	bucket := newTokenBucket(1, 1)
+	_ = bucket.wait(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancel()
+
+	if err := bucket.wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context deadline exceeded, got %v", err)
+	}
+}
+
+func TestOpenCodeTransportRoundTripMapsStatusesToBreakerOutcomes(t *testing.T) {
+	var status int32 = http.StatusInternalServerError
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(int(atomic.LoadInt32(&status)))
+	}))
+	defer server.Close()
+
+	transport := newOpenCodeTransport(1, time.Hour, 1000, 1000)
+	client := &http.Client{Transport: transport}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	if _, err := client.Do(req); err != nil {
+		t.Fatalf("unexpected error on first request: %v", err)
+	}
+
+	req2, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	if _, err := client.Do(req2); !errors.Is(err, ErrUpstreamUnavailable) {
+		t.Fatalf("expected the breaker to short-circuit the second request, got %v", err)
+	}
+
+	atomic.StoreInt32(&status, http.StatusOK)
+	req3, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	if _, err := client.Do(req3); !errors.Is(err, ErrUpstreamUnavailable) {
+		t.Fatalf("expected the breaker to still be open before cooldown elapses, got %v", err)
+	}
+}
+
+func TestOpenCodeTransportRoundTripRecordsSuccess(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	transport := newOpenCodeTransport(1, time.Hour, 1000, 1000)
+	client := &http.Client{Transport: transport}
+
+	for i := 0; i < 5; i++ {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		if _, err := client.Do(req); err != nil {
+			t.Fatalf("expected repeated successes to keep the breaker closed, got %v", err)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package ports
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 type ChatRecipient struct {
 	TelegramChatID int64
@@ -17,11 +20,61 @@ type AuthzRepository interface {
 	ListAdmins(ctx context.Context) ([]int64, error)
 }
 
+// AuthzEnforcer authorizes a (subject, object, action) tuple against a
+// policy, e.g. "may user:42 perform write on chat:100". Unlike
+// AuthzRepository, which answers the coarse "is this user allowed to use
+// the bot at all" question from the admin/allow lists, an AuthzEnforcer
+// answers finer-grained "may this subject do this to this object"
+// questions for services like SessionLinkService to gate per-call.
+type AuthzEnforcer interface {
+	Enforce(subject, object, action string) (bool, error)
+}
+
 type SessionLinkRepository interface {
 	GetSessionLink(ctx context.Context, chatID int64, userID int64) (string, bool, error)
 	UpsertSessionLink(ctx context.Context, chatID int64, userID int64, sessionID string) error
 	ClearSessionLink(ctx context.Context, chatID int64, userID int64) error
 	FindRecipientsBySession(ctx context.Context, sessionID string) ([]ChatRecipient, error)
+	// MarkRecipientFailing records that sends to chatID are currently failing
+	// permanently (e.g. Telegram reports the bot was blocked), so
+	// FindRecipientsBySession skips it until the given time.
+	MarkRecipientFailing(ctx context.Context, chatID int64, until time.Time) error
+}
+
+// ChatSettingsRepository persists per-chat bridge settings that aren't
+// specific to any one user, such as whether a group chat shares one OpenCode
+// session across its members or keeps the usual per-user sessions.
+type ChatSettingsRepository interface {
+	GetChatMode(ctx context.Context, chatID int64) (mode string, ok bool, err error)
+	UpsertChatMode(ctx context.Context, chatID int64, mode string) error
+}
+
+// UserPrefsRepository persists per-user runtime preference overrides set via
+// the /config command (see BridgeService.getEffectivePrefs), keyed by an
+// arbitrary config key such as "sessions_limit" or "locale".
+type UserPrefsRepository interface {
+	GetUserPref(ctx context.Context, userID int64, key string) (value string, ok bool, err error)
+	UpsertUserPref(ctx context.Context, userID int64, key string, value string) error
+	ListUserPrefs(ctx context.Context, userID int64) (map[string]string, error)
+}
+
+// PromptHistoryEntry is one recorded turn: the prompt text sent to OpenCode
+// and the session/message IDs needed to rewind it, used by /retry, /edit and
+// /s to redo or amend the last prompt.
+type PromptHistoryEntry struct {
+	SessionID       string
+	MessageID       string
+	PromptText      string
+	AssistantMsgIDs []int64
+}
+
+// PromptHistoryRepository persists the last prompt turn per (chat, user) so
+// /retry, /edit and /s can re-run or amend it without the caller needing to
+// resend the original text. GetLastPrompt returns only the most recent
+// entry per key; the SQLite store retains the last five.
+type PromptHistoryRepository interface {
+	RecordPrompt(ctx context.Context, chatID int64, userID int64, entry PromptHistoryEntry) error
+	GetLastPrompt(ctx context.Context, chatID int64, userID int64) (PromptHistoryEntry, bool, error)
 }
 
 type SessionModelRepository interface {
@@ -33,3 +86,113 @@ type SessionModelRepository interface {
 type TelegramClient interface {
 	SendMessage(ctx context.Context, chatID int64, text string) error
 }
+
+type Attachment struct {
+	Filename string
+	MIME     string
+	Data     []byte
+}
+
+// TelegramMediaSender is an optional capability TelegramClient implementations
+// can provide to upload documents/photos instead of sending plain text.
+type TelegramMediaSender interface {
+	SendDocument(ctx context.Context, chatID int64, attachment Attachment, caption string) error
+	SendPhoto(ctx context.Context, chatID int64, attachment Attachment, caption string) error
+}
+
+// TelegramMessageEditor is an optional capability TelegramClient implementations
+// can provide to edit a message in place instead of posting a new one each
+// time, used by the relay's stream mode to progressively reveal output.
+type TelegramMessageEditor interface {
+	SendMessageReturningID(ctx context.Context, chatID int64, text string) (int64, error)
+	EditMessageText(ctx context.Context, chatID int64, messageID int64, text string) error
+}
+
+// StreamMessageRepository persists the Telegram message a stream-mode relay
+// is currently editing for a given session/chat, keyed so a bridge restart
+// mid-stream resumes editing instead of starting a new message.
+type StreamMessageRepository interface {
+	GetStreamMessage(ctx context.Context, sessionID string, chatID int64) (messageID int64, textOffset int, found bool, err error)
+	UpsertStreamMessage(ctx context.Context, sessionID string, chatID int64, messageID int64, textOffset int) error
+	ClearStreamMessage(ctx context.Context, sessionID string, chatID int64) error
+}
+
+// EventBus lets multiple RelayService replicas share the opencode event
+// stream instead of each replica independently (and redundantly) receiving
+// and dispatching the same events. A topic is keyed by opencode session ID.
+type EventBus interface {
+	Publish(ctx context.Context, topic string, data []byte) error
+	// Subscribe returns a channel of raw message payloads published to topic.
+	// The channel is closed when ctx is done.
+	Subscribe(ctx context.Context, topic string) (<-chan []byte, error)
+}
+
+// LeaseRepository grants a short-lived, renewable lease so exactly one
+// replica is the "leader" allowed to flush a given session to Telegram at a
+// time, even though every replica receives every event over the EventBus.
+type LeaseRepository interface {
+	// ClaimLease attempts to become (or renew, as) the holder of key for ttl.
+	// It returns ok=false without error when another holder's lease is still
+	// live.
+	ClaimLease(ctx context.Context, key string, holder string, ttl time.Duration) (ok bool, err error)
+}
+
+// ConfigOverridesRepository persists the db layer of config.Manager's
+// layered config: per-key rows an admin has set at runtime via
+// "/config set <key> <value>", applied on top of the env and file layers
+// and surviving a restart.
+type ConfigOverridesRepository interface {
+	ListConfigOverrides(ctx context.Context) (map[string]string, error)
+	UpsertConfigOverride(ctx context.Context, key string, value string) error
+	DeleteConfigOverride(ctx context.Context, key string) error
+}
+
+// UsernameIndexEntry is one cached @username -> Telegram user ID mapping.
+// Found is false for a negative-cached entry (the username didn't resolve to
+// any chat), so ResolveService can apply a shorter TTL to it than to a
+// positive hit without a separate table.
+type UsernameIndexEntry struct {
+	Username  string
+	UserID    int64
+	Found     bool
+	UpdatedAt time.Time
+}
+
+// UsernameIndexRepository persists the username_index cache ResolveService
+// consults before calling Telegram's getChat, so repeat admin onboarding
+// (re-running the same ADMIN_USERNAMES/ALLOWED_USERNAMES list) doesn't
+// re-resolve usernames that were already looked up recently.
+type UsernameIndexRepository interface {
+	// LookupUsername is the fast path for callers that only need the user
+	// ID, with no TTL/negative-cache bookkeeping: ok is true for any positive
+	// entry, whatever its age; a negative-cached entry reports ok=false.
+	LookupUsername(ctx context.Context, username string) (userID int64, ok bool, err error)
+	// GetUsernameIndexEntry returns the raw cached entry regardless of age,
+	// so ResolveService can apply its own TTL/negative-TTL revalidation
+	// policy before deciding whether to call getChat again.
+	GetUsernameIndexEntry(ctx context.Context, username string) (UsernameIndexEntry, bool, error)
+	UpsertUsernameIndex(ctx context.Context, username string, userID int64) error
+	MarkUsernameNotFound(ctx context.Context, username string) error
+	ListUsernameIndex(ctx context.Context) ([]UsernameIndexEntry, error)
+}
+
+// SessionEndpointsRepository persists the OpenCode server (by base URL) each
+// session was created on, so opencode.Client's multi-endpoint failover
+// prefers the DC/host a session already lives on when reconnecting, instead
+// of picking whichever endpoint currently probes healthiest and risking a
+// "session not found" against a server that never saw that session.
+type SessionEndpointsRepository interface {
+	GetSessionEndpoint(ctx context.Context, sessionID string) (baseURL string, ok bool, err error)
+	UpsertSessionEndpoint(ctx context.Context, sessionID string, baseURL string) error
+}
+
+// SecretsRepository persists named secrets (the bot token, the OpenCode
+// server password, and so on) as AES-GCM blobs under a master key, so an
+// operator can provision them once in the database and stop passing them as
+// plaintext env vars. RotateSecrets re-encrypts every stored row under a new
+// master key.
+type SecretsRepository interface {
+	GetSecret(ctx context.Context, name string) (value string, ok bool, err error)
+	PutSecret(ctx context.Context, name string, value string) error
+	RotateSecrets(ctx context.Context, newMasterKey string) (rotated int, err error)
+}
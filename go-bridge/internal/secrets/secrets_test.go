@@ -0,0 +1,102 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestSecretStringRedactsString(t *testing.T) {
+	s := SecretString("super-secret-token")
+	if got := s.String(); got != Redacted {
+		t.Fatalf("expected String() to redact, got %q", got)
+	}
+	if got := fmt.Sprintf("%v", s); got != Redacted {
+		t.Fatalf("expected %%v to redact, got %q", got)
+	}
+	if got := s.Reveal(); got != "super-secret-token" {
+		t.Fatalf("expected Reveal() to return the underlying value, got %q", got)
+	}
+}
+
+func TestSecretStringLogValueRedacts(t *testing.T) {
+	s := SecretString("super-secret-token")
+	if got := s.LogValue().String(); got != Redacted {
+		t.Fatalf("expected LogValue() to redact, got %q", got)
+	}
+}
+
+type fakeSecretsRepo struct {
+	values map[string]string
+}
+
+func (f *fakeSecretsRepo) GetSecret(ctx context.Context, name string) (string, bool, error) {
+	value, ok := f.values[name]
+	return value, ok, nil
+}
+func (f *fakeSecretsRepo) PutSecret(ctx context.Context, name string, value string) error {
+	f.values[name] = value
+	return nil
+}
+func (f *fakeSecretsRepo) RotateSecrets(ctx context.Context, newMasterKey string) (int, error) {
+	return len(f.values), nil
+}
+
+func TestResolverPrefersStoreOverEnvFallback(t *testing.T) {
+	store := &fakeSecretsRepo{values: map[string]string{"bot_token": "stored-value"}}
+	resolver := NewResolver(store)
+
+	value, err := resolver.Resolve(context.Background(), "bot_token", "env-value")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if value.Reveal() != "stored-value" {
+		t.Fatalf("expected the stored value to win, got %q", value.Reveal())
+	}
+}
+
+func TestResolverFallsBackToEnvWhenUnset(t *testing.T) {
+	store := &fakeSecretsRepo{values: map[string]string{}}
+	resolver := NewResolver(store)
+
+	value, err := resolver.Resolve(context.Background(), "bot_token", "env-value")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if value.Reveal() != "env-value" {
+		t.Fatalf("expected the env fallback, got %q", value.Reveal())
+	}
+}
+
+func TestResolverFallsBackWithNilStore(t *testing.T) {
+	resolver := NewResolver(nil)
+
+	value, err := resolver.Resolve(context.Background(), "bot_token", "env-value")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if value.Reveal() != "env-value" {
+		t.Fatalf("expected the env fallback with a nil store, got %q", value.Reveal())
+	}
+}
+
+type erroringSecretsRepo struct{}
+
+func (erroringSecretsRepo) GetSecret(ctx context.Context, name string) (string, bool, error) {
+	return "", false, errors.New("boom")
+}
+func (erroringSecretsRepo) PutSecret(ctx context.Context, name string, value string) error {
+	return errors.New("boom")
+}
+func (erroringSecretsRepo) RotateSecrets(ctx context.Context, newMasterKey string) (int, error) {
+	return 0, errors.New("boom")
+}
+
+func TestResolverPropagatesStoreErrors(t *testing.T) {
+	resolver := NewResolver(erroringSecretsRepo{})
+
+	if _, err := resolver.Resolve(context.Background(), "bot_token", "env-value"); err == nil {
+		t.Fatalf("expected the store error to propagate")
+	}
+}
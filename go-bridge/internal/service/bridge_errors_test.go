@@ -0,0 +1,52 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/i18n"
+	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/opencode"
+)
+
+func TestUserFacingOpenCodeErrorEveryKindEveryLocale(t *testing.T) {
+	kinds := []opencode.OpenCodeErrorKind{
+		opencode.KindUnavailable,
+		opencode.KindUnauthorized,
+		opencode.KindTimeout,
+		opencode.KindBadRequest,
+		opencode.KindServerError,
+		opencode.KindUnknown,
+	}
+
+	for _, locale := range i18n.SupportedLocales {
+		localizer := i18n.New(locale)
+		for _, kind := range kinds {
+			err := &opencode.OpenCodeError{Kind: kind, StatusCode: 500, URL: "http://example.invalid"}
+			msg := userFacingOpenCodeError(err, localizer)
+			if msg == "" {
+				t.Fatalf("locale %q kind %v: expected non-empty message", locale, kind)
+			}
+			if msg == opencodeErrorKey(kind) {
+				t.Fatalf("locale %q kind %v: message fell back to the raw key %q", locale, kind, msg)
+			}
+		}
+	}
+}
+
+func TestUserFacingOpenCodeErrorIncludesDetail(t *testing.T) {
+	err := &opencode.OpenCodeError{Kind: opencode.KindBadRequest, StatusCode: 400, Detail: "\tfield X is required"}
+	msg := userFacingOpenCodeError(err, i18n.New("en"))
+	if !strings.Contains(msg, "field X is required") {
+		t.Fatalf("expected detail to be included, got %q", msg)
+	}
+}
+
+func TestUserFacingOpenCodeErrorNonTypedFallback(t *testing.T) {
+	if msg := userFacingOpenCodeError(nil, i18n.New("es")); msg != i18n.New("es").Message("err.opencode.generic") {
+		t.Fatalf("expected generic message for nil error, got %q", msg)
+	}
+	if msg := userFacingOpenCodeError(errors.New("local timeout waiting for assistant"), i18n.New("en")); msg != i18n.New("en").Message("err.opencode.timeout") {
+		t.Fatalf("expected timeout message for local timeout error, got %q", msg)
+	}
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/telegram"
+)
+
+func newMentionTestService(username string) *BridgeService {
+	s := &BridgeService{}
+	s.botUser.resolved = true
+	s.botUser.user = telegram.User{Username: username}
+	return s
+}
+
+func TestShouldRespondInGroupMentionAfterNonASCIIText(t *testing.T) {
+	s := newMentionTestService("testbot")
+	// The leading emoji is a surrogate pair in UTF-16 (2 code units) but a
+	// single rune in Go's UTF-8 string, so byte-slicing at the Bot API's
+	// UTF-16 offset/length would miss the mention entirely.
+	text := "\U0001F600 @testbot hola"
+	message := telegram.Message{
+		Text: text,
+		Entities: []telegram.MessageEntity{
+			{Type: "mention", Offset: 3, Length: 8},
+		},
+	}
+	if !s.shouldRespondInGroup(context.Background(), message) {
+		t.Fatalf("expected mention after a surrogate-pair emoji to be detected")
+	}
+}
+
+func TestShouldRespondInGroupNoMention(t *testing.T) {
+	s := newMentionTestService("testbot")
+	message := telegram.Message{Text: "hola sin mencion"}
+	if s.shouldRespondInGroup(context.Background(), message) {
+		t.Fatalf("expected no mention to be detected")
+	}
+}
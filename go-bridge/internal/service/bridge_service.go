@@ -5,13 +5,20 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
+	"os"
+	"path/filepath"
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf16"
 
+	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/config"
+	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/i18n"
 	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/opencode"
 	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/ports"
+	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/storage"
 	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/telegram"
 )
 
@@ -19,24 +26,114 @@ var sessionIDPattern = regexp.MustCompile(`^ses_[A-Za-z0-9]+$`)
 
 type InteractiveTelegramClient interface {
 	SendMessage(ctx context.Context, chatID int64, text string) error
+	SendMessageReturningID(ctx context.Context, chatID int64, text string) (int64, error)
+	EditMessageText(ctx context.Context, chatID int64, messageID int64, text string) error
 	SendChatAction(ctx context.Context, chatID int64, action string) error
 	SendMessageWithInlineKeyboard(ctx context.Context, chatID int64, text string, rows [][]telegram.InlineKeyboardButton) error
+	SendMessageWithInlineKeyboardReturningID(ctx context.Context, chatID int64, text string, rows [][]telegram.InlineKeyboardButton) (int64, error)
 	AnswerCallbackQuery(ctx context.Context, callbackQueryID string, text string) error
+	DownloadFile(ctx context.Context, fileID string) ([]byte, error)
+	SendPhoto(ctx context.Context, chatID int64, attachment ports.Attachment, caption string) error
+	SendDocument(ctx context.Context, chatID int64, attachment ports.Attachment, caption string) error
+	GetMe(ctx context.Context) (telegram.User, error)
+	DeleteMessage(ctx context.Context, chatID int64, messageID int64) error
+}
+
+// Chat modes stored via ports.ChatSettingsRepository. Private is the default:
+// every user in a chat keeps their own (chat_id, user_id) session. Shared
+// pools one session per chat, keyed by sharedSessionUserID, for group use.
+const (
+	chatModePrivate = "private"
+	chatModeShared  = "shared"
+)
+
+// sharedSessionUserID is the sentinel SessionLinkRepository user ID a shared
+// chat's session is stored under, reusing the existing (chat_id, user_id)
+// schema instead of adding a parallel one keyed by chat_id alone. It is never
+// a real Telegram user ID, which are always positive.
+const sharedSessionUserID int64 = 0
+
+const (
+	telegramGroupChatType      = "group"
+	telegramSupergroupChatType = "supergroup"
+)
+
+// bridgeStreamEditInterval throttles how often waitForAssistantResponse
+// edits the in-flight placeholder message: at most one edit every 2s, twice
+// RelayService's streamEditInterval, which keeps well inside Telegram's
+// ~1 edit/sec budget.
+const bridgeStreamEditInterval = 2 * time.Second
+
+// bridgeStreamEditMinDelta lets a large jump in assistant output bypass
+// bridgeStreamEditInterval so a long first paragraph doesn't sit unedited
+// for the full throttle window.
+const bridgeStreamEditMinDelta = 200
+
+// maxInboundAttachmentBytes bounds inbound photo/document/voice downloads so
+// a user can't abuse the bridge into proxying arbitrarily large files to
+// OpenCode.
+const maxInboundAttachmentBytes = 20 * 1024 * 1024
+
+// allowedAttachmentMIMEPrefixes whitelists the inbound attachment kinds this
+// bridge forwards to OpenCode. Attachments that report no MIME type (common
+// for Telegram photos) are allowed through and typed explicitly in
+// downloadInboundAttachment instead.
+var allowedAttachmentMIMEPrefixes = []string{"image/", "audio/", "application/pdf", "text/"}
+
+// attachmentWithinWhitelist enforces the bridge-wide size/type whitelist against
+// an inbound message's Photo/Document/Voice, if any, before it is downloaded.
+func attachmentWithinWhitelist(message telegram.Message) bool {
+	switch {
+	case len(message.Photo) > 0:
+		largest := message.Photo[len(message.Photo)-1]
+		return largest.FileSize <= maxInboundAttachmentBytes
+	case message.Document != nil:
+		return attachmentMIMEAllowed(message.Document.MIME) && message.Document.FileSize <= maxInboundAttachmentBytes
+	case message.Voice != nil:
+		return attachmentMIMEAllowed(message.Voice.MIME) && message.Voice.FileSize <= maxInboundAttachmentBytes
+	default:
+		return true
+	}
+}
+
+func attachmentMIMEAllowed(mimeType string) bool {
+	trimmed := strings.TrimSpace(mimeType)
+	if trimmed == "" {
+		return true
+	}
+	for _, prefix := range allowedAttachmentMIMEPrefixes {
+		if strings.HasPrefix(trimmed, prefix) {
+			return true
+		}
+	}
+	return false
 }
 
 type BridgeService struct {
-	logger      *slog.Logger
-	opencode    *opencode.Client
-	telegramAPI InteractiveTelegramClient
-	authzRepo   ports.AuthzRepository
-	models      ports.SessionModelRepository
-	sessions    *SessionLinkService
-	queue       *KeyedQueue
-	sessionsCfg struct {
+	logger       *slog.Logger
+	opencode     *opencode.Client
+	telegramAPI  InteractiveTelegramClient
+	authzRepo    ports.AuthzRepository
+	models       ports.SessionModelRepository
+	chatSettings ports.ChatSettingsRepository
+	userPrefs    ports.UserPrefsRepository
+	promptHist   ports.PromptHistoryRepository
+	configMgr    *config.Manager
+	sessions     *SessionLinkService
+	rateLimiter  *storage.RateLimiter
+	queue        *KeyedQueue
+	sessionsCfg  struct {
 		limit      int
 		source     string
 		showIDList bool
 	}
+	defaultLocale string
+	botUser       struct {
+		mu       sync.Mutex
+		resolved bool
+		user     telegram.User
+		err      error
+	}
 }
 
 func NewBridgeService(
@@ -45,22 +142,37 @@ func NewBridgeService(
 	telegramClient InteractiveTelegramClient,
 	authzRepo ports.AuthzRepository,
 	modelRepo ports.SessionModelRepository,
+	chatSettingsRepo ports.ChatSettingsRepository,
+	userPrefsRepo ports.UserPrefsRepository,
+	promptHistoryRepo ports.PromptHistoryRepository,
+	configMgr *config.Manager,
 	sessions *SessionLinkService,
+	rateLimiter *storage.RateLimiter,
 	sessionsListLimit int,
 	sessionsSource string,
 	sessionsShowIDList bool,
+	defaultLocale string,
 ) *BridgeService {
 	if sessionsListLimit <= 0 {
 		sessionsListLimit = 5
 	}
+	if !i18n.IsSupported(defaultLocale) {
+		defaultLocale = i18n.DefaultLocale
+	}
 	return &BridgeService{
-		logger:      logger,
-		opencode:    opencodeClient,
-		telegramAPI: telegramClient,
-		authzRepo:   authzRepo,
-		models:      modelRepo,
-		sessions:    sessions,
-		queue:       NewKeyedQueue(),
+		logger:        logger,
+		opencode:      opencodeClient,
+		telegramAPI:   telegramClient,
+		authzRepo:     authzRepo,
+		models:        modelRepo,
+		chatSettings:  chatSettingsRepo,
+		userPrefs:     userPrefsRepo,
+		promptHist:    promptHistoryRepo,
+		configMgr:     configMgr,
+		sessions:      sessions,
+		rateLimiter:   rateLimiter,
+		defaultLocale: defaultLocale,
+		queue:         NewKeyedQueue(),
 		sessionsCfg: struct {
 			limit      int
 			source     string
@@ -73,8 +185,93 @@ func NewBridgeService(
 	}
 }
 
+// chatMode returns the chat's configured session mode, defaulting to
+// chatModePrivate when unset or when the repository isn't wired.
+func (s *BridgeService) chatMode(ctx context.Context, chatID int64) string {
+	if s.chatSettings == nil {
+		return chatModePrivate
+	}
+	mode, ok, err := s.chatSettings.GetChatMode(ctx, chatID)
+	if err != nil || !ok {
+		return chatModePrivate
+	}
+	return mode
+}
+
+// effectiveSessionUserID returns the user ID session lookups/mutations
+// should key on: userID itself in private mode, or sharedSessionUserID in
+// shared mode, so every member of a shared chat reads and writes the same
+// session_links row.
+func (s *BridgeService) effectiveSessionUserID(ctx context.Context, chatID int64, userID int64) int64 {
+	if s.chatMode(ctx, chatID) == chatModeShared {
+		return sharedSessionUserID
+	}
+	return userID
+}
+
+// resolveBotUser fetches and caches the bot's own Telegram profile, used to
+// recognize @mentions of the bot in shouldRespondInGroup. A failed lookup is
+// not cached, so the next call retries.
+func (s *BridgeService) resolveBotUser(ctx context.Context) (telegram.User, error) {
+	s.botUser.mu.Lock()
+	defer s.botUser.mu.Unlock()
+	if s.botUser.resolved {
+		return s.botUser.user, nil
+	}
+	user, err := s.telegramAPI.GetMe(ctx)
+	if err != nil {
+		return telegram.User{}, err
+	}
+	s.botUser.user = user
+	s.botUser.resolved = true
+	return user, nil
+}
+
+// shouldRespondInGroup reports whether the bot should act on message in a
+// shared-mode group chat: only when the bot is @mentioned, targeted by a
+// /command@botname, or the message replies directly to one of the bot's own
+// messages. Private-mode chats and non-group chats always respond, so callers
+// should only consult this once they already know the chat is shared.
+func (s *BridgeService) shouldRespondInGroup(ctx context.Context, message telegram.Message) bool {
+	if message.ReplyToMessage != nil {
+		if botUser, err := s.resolveBotUser(ctx); err == nil && message.ReplyToMessage.From.ID == botUser.ID {
+			return true
+		}
+	}
+
+	botUser, err := s.resolveBotUser(ctx)
+	if err != nil || strings.TrimSpace(botUser.Username) == "" {
+		return false
+	}
+	mention := "@" + botUser.Username
+
+	text := message.Text
+	if text == "" {
+		text = message.Caption
+	}
+	// Entity Offset/Length are UTF-16 code-unit counts per the Bot API, not
+	// byte indices, so any non-ASCII text preceding the entity (e.g. the
+	// bot's own Spanish replies) would otherwise slice text at the wrong
+	// byte offset.
+	units := utf16.Encode([]rune(text))
+	for _, entity := range message.Entities {
+		if entity.Type != "mention" && entity.Type != "bot_command" {
+			continue
+		}
+		if entity.Offset < 0 || entity.Length < 0 || entity.Offset+entity.Length > len(units) {
+			continue
+		}
+		span := string(utf16.Decode(units[entity.Offset : entity.Offset+entity.Length]))
+		if strings.EqualFold(span, mention) || (entity.Type == "bot_command" && strings.Contains(strings.ToLower(span), strings.ToLower(mention))) {
+			return true
+		}
+	}
+	return false
+}
+
 func (s *BridgeService) HandleUpdate(ctx context.Context, update telegram.Update) {
 	if update.CallbackQuery != nil {
+		updatesTotal.WithLabelValue("callback_query").Inc()
 		s.handleCallbackQuery(ctx, update.CallbackQuery)
 		return
 	}
@@ -89,13 +286,25 @@ func (s *BridgeService) HandleUpdate(ctx context.Context, update telegram.Update
 
 	text := strings.TrimSpace(message.Text)
 	if text == "" {
+		text = strings.TrimSpace(message.Caption)
+	}
+	hasAttachment := len(message.Photo) > 0 || message.Document != nil || message.Voice != nil
+	if text == "" && !hasAttachment {
 		return
 	}
 
 	if strings.HasPrefix(text, "/") {
+		updatesTotal.WithLabelValue("command").Inc()
+		start := time.Now()
 		s.handleCommand(ctx, *message)
+		commandDuration.Observe(time.Since(start).Seconds())
 		return
 	}
+	if hasAttachment {
+		updatesTotal.WithLabelValue("attachment").Inc()
+	} else {
+		updatesTotal.WithLabelValue("text").Inc()
+	}
 
 	allowed, err := s.authzRepo.IsAllowed(ctx, message.From.ID)
 	if err != nil {
@@ -106,10 +315,39 @@ func (s *BridgeService) HandleUpdate(ctx context.Context, update telegram.Update
 		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "No autorizado. Pide acceso al admin con tu userId.")
 		return
 	}
+	if !attachmentWithinWhitelist(*message) {
+		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "Adjunto no permitido (tipo o tamano no soportado).")
+		return
+	}
+
+	prefs := s.getEffectivePrefs(ctx, message.From.ID)
+	if s.rateLimiter != nil {
+		allow, quota, err := s.rateLimiter.Allow(ctx, message.From.ID)
+		if err != nil {
+			s.logger.Error("quota check failed", "error", err, "user_id", message.From.ID)
+		} else if !allow {
+			if err := s.rateLimiter.RecordDenial(ctx, message.From.ID, fmt.Sprintf("daily request limit %d reached", quota.DailyRequestLimit)); err != nil {
+				s.logger.Error("record quota denial failed", "error", err, "user_id", message.From.ID)
+			}
+			_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, i18n.New(prefs.locale).Message("err.quota.exceeded", quota.DailyRequestLimit))
+			return
+		}
+	}
+
+	isGroupChat := message.Chat.Type == telegramGroupChatType || message.Chat.Type == telegramSupergroupChatType
+	if isGroupChat && s.chatMode(ctx, message.Chat.ID) == chatModeShared && !s.shouldRespondInGroup(ctx, *message) {
+		return
+	}
+
+	prompt := text
+	if prompt == "" {
+		prompt = "Analiza el archivo adjunto."
+	}
 
-	queueKey := fmt.Sprintf("%d:%d", message.Chat.ID, message.From.ID)
+	sessionUserID := s.effectiveSessionUserID(ctx, message.Chat.ID, message.From.ID)
+	queueKey := fmt.Sprintf("%d:%d", message.Chat.ID, sessionUserID)
 	err = s.queue.Run(ctx, queueKey, func(ctx context.Context) error {
-		sessionID, err := s.sessions.GetSession(ctx, message.Chat.ID, message.From.ID)
+		sessionID, err := s.sessions.GetSession(ctx, message.Chat.ID, sessionUserID)
 		if err != nil {
 			return err
 		}
@@ -129,12 +367,20 @@ func (s *BridgeService) HandleUpdate(ctx context.Context, update telegram.Update
 				model = storedModel
 			}
 		}
-		newSessionID, err := s.opencode.RunPrompt(ctx, text, sessionID, model)
+		var attachments []opencode.PromptAttachment
+		if hasAttachment {
+			attachment, err := s.downloadInboundAttachment(ctx, *message)
+			if err != nil {
+				return err
+			}
+			attachments = []opencode.PromptAttachment{attachment}
+		}
+		newSessionID, userMessageID, err := s.opencode.RunPromptWithAttachments(ctx, prompt, sessionID, model, attachments)
 		if err != nil {
 			return err
 		}
 		if newSessionID != "" && newSessionID != sessionID {
-			if err := s.sessions.SetSession(ctx, message.Chat.ID, message.From.ID, newSessionID); err != nil {
+			if err := s.sessions.SetSession(ctx, message.Chat.ID, sessionUserID, newSessionID); err != nil {
 				return err
 			}
 			if strings.TrimSpace(model) != "" {
@@ -143,27 +389,85 @@ func (s *BridgeService) HandleUpdate(ctx context.Context, update telegram.Update
 				}
 			}
 		}
-		if err := s.telegramAPI.SendMessage(ctx, message.Chat.ID, "Procesando solicitud..."); err != nil {
-			return err
-		}
-
-		responseText, waitErr := s.waitForAssistantResponse(ctx, message.Chat.ID, newSessionID, previousSnapshot)
+		responseText, assistantMsgIDs, tokensUsed, waitErr := s.waitForAssistantResponse(ctx, message.Chat.ID, newSessionID, previousSnapshot, prefs.streamEdits)
 		if waitErr != nil {
 			return waitErr
 		}
+		if s.rateLimiter != nil {
+			if err := s.rateLimiter.RecordTokens(ctx, message.From.ID, tokensUsed); err != nil {
+				s.logger.Error("record token usage failed", "chat_id", message.Chat.ID, "user_id", message.From.ID, "error", err)
+			}
+		}
+		s.recordPromptHistory(ctx, message.Chat.ID, sessionUserID, newSessionID, userMessageID, prompt, assistantMsgIDs)
 		if strings.TrimSpace(responseText) == "" {
 			return s.telegramAPI.SendMessage(ctx, message.Chat.ID, "OpenCode no devolvio texto en esta respuesta.")
 		}
-		return s.telegramAPI.SendMessage(ctx, message.Chat.ID, responseText)
+		return nil
 	})
 	if err != nil {
 		if errors.Is(err, context.Canceled) {
-			s.logger.Info("prompt canceled during shutdown", "chat_id", message.Chat.ID, "user_id", message.From.ID)
+			s.logger.Info("prompt canceled", "chat_id", message.Chat.ID, "user_id", message.From.ID)
 			return
 		}
 		s.logger.Error("handle prompt failed", "error", err, "chat_id", message.Chat.ID, "user_id", message.From.ID)
-		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, userFacingOpenCodeError(err))
+		if prefs.notifyOnError {
+			_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, userFacingOpenCodeError(err, i18n.New(prefs.locale)))
+		}
+	}
+}
+
+// recordPromptHistory saves the turn that just ran so a later /retry, /edit
+// or /s can redo or amend it. Failures are logged, not propagated: losing the
+// history entry shouldn't fail a prompt that otherwise succeeded.
+func (s *BridgeService) recordPromptHistory(ctx context.Context, chatID int64, sessionUserID int64, sessionID string, userMessageID string, promptText string, assistantMsgIDs []int64) {
+	if s.promptHist == nil || sessionID == "" {
+		return
+	}
+	entry := ports.PromptHistoryEntry{
+		SessionID:       sessionID,
+		MessageID:       userMessageID,
+		PromptText:      promptText,
+		AssistantMsgIDs: assistantMsgIDs,
+	}
+	if err := s.promptHist.RecordPrompt(ctx, chatID, sessionUserID, entry); err != nil {
+		s.logger.Error("record prompt history failed", "chat_id", chatID, "error", err)
+	}
+}
+
+// downloadInboundAttachment resolves message's Photo/Document/Voice (the
+// caller has already checked one is present) to its bytes via the Telegram
+// getFile API, so it can be forwarded to OpenCode as a PromptAttachment.
+func (s *BridgeService) downloadInboundAttachment(ctx context.Context, message telegram.Message) (opencode.PromptAttachment, error) {
+	var fileID, filename, mimeType string
+	switch {
+	case len(message.Photo) > 0:
+		largest := message.Photo[len(message.Photo)-1]
+		fileID = largest.FileID
+		filename = "photo.jpg"
+		mimeType = "image/jpeg"
+	case message.Document != nil:
+		fileID = message.Document.FileID
+		filename = message.Document.FileName
+		mimeType = message.Document.MIME
+	case message.Voice != nil:
+		fileID = message.Voice.FileID
+		filename = "voice.ogg"
+		mimeType = message.Voice.MIME
+	default:
+		return opencode.PromptAttachment{}, fmt.Errorf("message has no downloadable attachment")
+	}
+	if strings.TrimSpace(filename) == "" {
+		filename = "file"
+	}
+	if strings.TrimSpace(mimeType) == "" {
+		mimeType = "application/octet-stream"
+	}
+
+	data, err := s.telegramAPI.DownloadFile(ctx, fileID)
+	if err != nil {
+		return opencode.PromptAttachment{}, fmt.Errorf("download telegram file: %w", err)
 	}
+	return opencode.PromptAttachment{Filename: filename, MIME: mimeType, Data: data}, nil
 }
 
 func (s *BridgeService) handleCallbackQuery(ctx context.Context, query *telegram.CallbackQuery) {
@@ -174,6 +478,12 @@ func (s *BridgeService) handleCallbackQuery(ctx context.Context, query *telegram
 	if data == "" {
 		return
 	}
+
+	if data == "cancel" {
+		s.handleCancelCallback(ctx, query)
+		return
+	}
+
 	if !strings.HasPrefix(data, "session_use:") {
 		_ = s.telegramAPI.AnswerCallbackQuery(ctx, query.ID, "Accion no soportada")
 		return
@@ -191,7 +501,7 @@ func (s *BridgeService) handleCallbackQuery(ctx context.Context, query *telegram
 		return
 	}
 
-	if err := s.sessions.SetSession(ctx, query.Message.Chat.ID, query.From.ID, sessionID); err != nil {
+	if err := s.sessions.SetSession(ctx, query.Message.Chat.ID, s.effectiveSessionUserID(ctx, query.Message.Chat.ID, query.From.ID), sessionID); err != nil {
 		s.logger.Error("set session from callback failed", "error", err, "session_id", sessionID)
 		_ = s.telegramAPI.AnswerCallbackQuery(ctx, query.ID, "No se pudo cambiar sesion")
 		return
@@ -216,20 +526,34 @@ func (s *BridgeService) handleCommand(ctx context.Context, message telegram.Mess
 		s.handleStatus(ctx, message)
 	case "compact":
 		s.handleCompact(ctx, message)
+	case "cancel":
+		s.handleCancel(ctx, message)
 	case "session":
 		s.handleSession(ctx, message, args)
 	case "sessions":
 		s.handleSessions(ctx, message)
 	case "models":
 		s.handleModels(ctx, message, args)
+	case "config":
+		s.handleConfig(ctx, message, args)
+	case "lang":
+		s.handleLang(ctx, message, args)
+	case "retry":
+		s.handleRetry(ctx, message)
+	case "edit":
+		s.handleEdit(ctx, message, args)
+	case "s":
+		s.handleSubstitute(ctx, message, args)
 	case "allow":
 		s.handleAllow(ctx, message, args)
 	case "deny":
 		s.handleDeny(ctx, message, args)
 	case "list":
 		s.handleList(ctx, message)
+	case "quota":
+		s.handleQuota(ctx, message, args)
 	default:
-		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "Comando no soportado aun. Usa /start /status /session /sessions /compact /models /allow /deny /list.")
+		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "Comando no soportado aun. Usa /start /status /session /sessions /compact /cancel /models /config /lang /retry /edit /s /allow /deny /list /quota.")
 	}
 }
 
@@ -240,7 +564,7 @@ func (s *BridgeService) handleStart(ctx context.Context, message telegram.Messag
 		return
 	}
 	if allowed {
-		_, _ = s.sessions.GetSession(ctx, message.Chat.ID, message.From.ID)
+		_, _ = s.sessions.GetSession(ctx, message.Chat.ID, s.effectiveSessionUserID(ctx, message.Chat.ID, message.From.ID))
 		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "Bot listo. Puedes enviar instrucciones para OpenCode.")
 		return
 	}
@@ -251,7 +575,7 @@ func (s *BridgeService) handleStatus(ctx context.Context, message telegram.Messa
 	if !s.requireAllowed(ctx, message) {
 		return
 	}
-	sessionID, err := s.sessions.GetSession(ctx, message.Chat.ID, message.From.ID)
+	sessionID, err := s.sessions.GetSession(ctx, message.Chat.ID, s.effectiveSessionUserID(ctx, message.Chat.ID, message.From.ID))
 	if err != nil {
 		s.logger.Error("get session for status failed", "error", err)
 		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "No se pudo obtener la sesion actual.")
@@ -284,7 +608,7 @@ func (s *BridgeService) handleCompact(ctx context.Context, message telegram.Mess
 	if !s.requireAllowed(ctx, message) {
 		return
 	}
-	sessionID, err := s.sessions.GetSession(ctx, message.Chat.ID, message.From.ID)
+	sessionID, err := s.sessions.GetSession(ctx, message.Chat.ID, s.effectiveSessionUserID(ctx, message.Chat.ID, message.From.ID))
 	if err != nil {
 		s.logger.Error("get session for compact failed", "error", err)
 		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "No se pudo obtener la sesion actual.")
@@ -302,17 +626,474 @@ func (s *BridgeService) handleCompact(ctx context.Context, message telegram.Mess
 	_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "Sesion compactada.")
 }
 
+func (s *BridgeService) handleCancel(ctx context.Context, message telegram.Message) {
+	if !s.requireAllowed(ctx, message) {
+		return
+	}
+	if !s.cancelPrompt(ctx, message.Chat.ID, message.From.ID) {
+		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "No hay nada que cancelar.")
+	}
+}
+
+func (s *BridgeService) handleCancelCallback(ctx context.Context, query *telegram.CallbackQuery) {
+	if query.Message == nil {
+		return
+	}
+	message := telegram.Message{From: query.From, Chat: query.Message.Chat}
+	allowed, err := s.authzRepo.IsAllowed(ctx, query.From.ID)
+	if err != nil {
+		s.logger.Error("auth check failed", "error", err)
+		return
+	}
+	if !allowed {
+		_ = s.telegramAPI.AnswerCallbackQuery(ctx, query.ID, "No autorizado")
+		return
+	}
+	if s.cancelPrompt(ctx, message.Chat.ID, message.From.ID) {
+		_ = s.telegramAPI.AnswerCallbackQuery(ctx, query.ID, "Cancelando...")
+		return
+	}
+	_ = s.telegramAPI.AnswerCallbackQuery(ctx, query.ID, "No hay nada que cancelar.")
+}
+
+// cancelPrompt aborts the in-flight prompt for (chatID, userID), both
+// locally (canceling the context waitForAssistantResponse is polling with,
+// via KeyedQueue.Cancel) and remotely (telling OpenCode to stop generating),
+// so /cancel and the "Cancelar" inline button actually halt the agent
+// instead of just hiding its output. Reports whether a prompt was running.
+func (s *BridgeService) cancelPrompt(ctx context.Context, chatID int64, userID int64) bool {
+	sessionUserID := s.effectiveSessionUserID(ctx, chatID, userID)
+	queueKey := fmt.Sprintf("%d:%d", chatID, sessionUserID)
+	if !s.queue.Cancel(queueKey) {
+		return false
+	}
+	if sessionID, err := s.sessions.GetSession(ctx, chatID, sessionUserID); err == nil && sessionID != "" {
+		if abortErr := s.opencode.AbortSession(ctx, sessionID); abortErr != nil {
+			s.logger.Error("abort opencode session failed", "error", abortErr, "session_id", sessionID)
+		}
+	}
+	return true
+}
+
+func (s *BridgeService) handleRetry(ctx context.Context, message telegram.Message) {
+	if !s.requireAllowed(ctx, message) {
+		return
+	}
+	entry, ok := s.lastPromptOrNotify(ctx, message)
+	if !ok {
+		return
+	}
+	s.rerunPrompt(ctx, message, entry, entry.PromptText)
+}
+
+func (s *BridgeService) handleEdit(ctx context.Context, message telegram.Message, args []string) {
+	if !s.requireAllowed(ctx, message) {
+		return
+	}
+	newText := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(message.Text), "/edit"))
+	if newText == "" {
+		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "Uso: /edit <nuevo texto>")
+		return
+	}
+	entry, ok := s.lastPromptOrNotify(ctx, message)
+	if !ok {
+		return
+	}
+	s.rerunPrompt(ctx, message, entry, newText)
+}
+
+// substitutionPattern matches a telegabber-style "/pattern/replacement/"
+// argument to /s, with "/" inside pattern or replacement escaped as "\/".
+var substitutionPattern = regexp.MustCompile(`^/((?:\\.|[^/\\])*)/((?:\\.|[^/\\])*)/$`)
+
+func (s *BridgeService) handleSubstitute(ctx context.Context, message telegram.Message, args []string) {
+	if !s.requireAllowed(ctx, message) {
+		return
+	}
+	raw := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(message.Text), "/s"))
+	match := substitutionPattern.FindStringSubmatch(raw)
+	if match == nil {
+		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "Uso: /s /patron/reemplazo/")
+		return
+	}
+	pattern := strings.ReplaceAll(match[1], `\/`, "/")
+	replacement := strings.ReplaceAll(match[2], `\/`, "/")
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "Patron invalido: "+err.Error())
+		return
+	}
+	entry, ok := s.lastPromptOrNotify(ctx, message)
+	if !ok {
+		return
+	}
+	newText := re.ReplaceAllString(entry.PromptText, replacement)
+	s.rerunPrompt(ctx, message, entry, newText)
+}
+
+// lastPromptOrNotify fetches (chatID, userID)'s last recorded prompt turn,
+// sending a user-facing message and reporting ok=false if there isn't one.
+func (s *BridgeService) lastPromptOrNotify(ctx context.Context, message telegram.Message) (ports.PromptHistoryEntry, bool) {
+	if s.promptHist == nil {
+		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "No hay historial de prompts disponible.")
+		return ports.PromptHistoryEntry{}, false
+	}
+	sessionUserID := s.effectiveSessionUserID(ctx, message.Chat.ID, message.From.ID)
+	entry, found, err := s.promptHist.GetLastPrompt(ctx, message.Chat.ID, sessionUserID)
+	if err != nil {
+		s.logger.Error("get last prompt failed", "error", err)
+		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "No se pudo obtener el ultimo prompt.")
+		return ports.PromptHistoryEntry{}, false
+	}
+	if !found {
+		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "No hay un prompt previo para reintentar.")
+		return ports.PromptHistoryEntry{}, false
+	}
+	return entry, true
+}
+
+// rerunPrompt re-runs promptText in entry's session on behalf of message's
+// chat/user, used by /retry, /edit and /s. It first deletes the previous
+// turn's Telegram messages and best-effort rewinds the OpenCode session past
+// entry's user message, so the retry replaces rather than just appends to the
+// prior turn. It goes through the same KeyedQueue as normal prompts, so a
+// retry serializes behind any prompt already in flight for this chat/user.
+func (s *BridgeService) rerunPrompt(ctx context.Context, message telegram.Message, entry ports.PromptHistoryEntry, promptText string) {
+	chatID := message.Chat.ID
+	sessionUserID := s.effectiveSessionUserID(ctx, chatID, message.From.ID)
+	queueKey := fmt.Sprintf("%d:%d", chatID, sessionUserID)
+
+	for _, messageID := range entry.AssistantMsgIDs {
+		if err := s.telegramAPI.DeleteMessage(ctx, chatID, messageID); err != nil {
+			s.logger.Error("delete previous assistant message failed", "chat_id", chatID, "message_id", messageID, "error", err)
+		}
+	}
+	if entry.SessionID != "" && entry.MessageID != "" {
+		if err := s.opencode.RewindSession(ctx, entry.SessionID, []string{entry.MessageID}); err != nil {
+			s.logger.Error("rewind session failed", "session_id", entry.SessionID, "error", err)
+		}
+	}
+
+	prefs := s.getEffectivePrefs(ctx, message.From.ID)
+	if s.rateLimiter != nil {
+		allow, quota, err := s.rateLimiter.Allow(ctx, message.From.ID)
+		if err != nil {
+			s.logger.Error("quota check failed", "error", err, "user_id", message.From.ID)
+		} else if !allow {
+			if err := s.rateLimiter.RecordDenial(ctx, message.From.ID, fmt.Sprintf("daily request limit %d reached", quota.DailyRequestLimit)); err != nil {
+				s.logger.Error("record quota denial failed", "error", err, "user_id", message.From.ID)
+			}
+			_ = s.telegramAPI.SendMessage(ctx, chatID, i18n.New(prefs.locale).Message("err.quota.exceeded", quota.DailyRequestLimit))
+			return
+		}
+	}
+	err := s.queue.Run(ctx, queueKey, func(ctx context.Context) error {
+		previousSnapshot := opencode.AssistantSnapshot{}
+		if snapshot, snapshotErr := s.opencode.GetAssistantSnapshot(ctx, entry.SessionID); snapshotErr == nil {
+			previousSnapshot = snapshot
+		}
+		model := ""
+		if storedModel, ok, modelErr := s.models.GetSessionModel(ctx, entry.SessionID); modelErr == nil && ok {
+			model = storedModel
+		}
+		newSessionID, userMessageID, err := s.opencode.RunPromptWithAttachments(ctx, promptText, entry.SessionID, model, nil)
+		if err != nil {
+			return err
+		}
+		if newSessionID != "" && newSessionID != entry.SessionID {
+			if err := s.sessions.SetSession(ctx, chatID, sessionUserID, newSessionID); err != nil {
+				return err
+			}
+		}
+		responseText, assistantMsgIDs, tokensUsed, waitErr := s.waitForAssistantResponse(ctx, chatID, newSessionID, previousSnapshot, prefs.streamEdits)
+		if waitErr != nil {
+			return waitErr
+		}
+		if s.rateLimiter != nil {
+			if err := s.rateLimiter.RecordTokens(ctx, message.From.ID, tokensUsed); err != nil {
+				s.logger.Error("record token usage failed", "chat_id", chatID, "user_id", message.From.ID, "error", err)
+			}
+		}
+		s.recordPromptHistory(ctx, chatID, sessionUserID, newSessionID, userMessageID, promptText, assistantMsgIDs)
+		if strings.TrimSpace(responseText) == "" {
+			return s.telegramAPI.SendMessage(ctx, chatID, "OpenCode no devolvio texto en esta respuesta.")
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		s.logger.Error("rerun prompt failed", "error", err, "chat_id", chatID)
+		if prefs.notifyOnError {
+			_ = s.telegramAPI.SendMessage(ctx, chatID, userFacingOpenCodeError(err, i18n.New(prefs.locale)))
+		}
+	}
+}
+
+// configKeyDef describes one /config knob: its storage key and the label
+// shown alongside its value in the "/config" listing.
+type configKeyDef struct {
+	key   string
+	label string
+}
+
+var configKeyDefs = []configKeyDef{
+	{"sessions_limit", "Limite de sesiones mostradas en /sessions"},
+	{"sessions_source", "Fuente de sesiones (endpoint|cli|both)"},
+	{"sessions_show_id_list", "Mostrar lista numerada en /sessions (true|false)"},
+	{"locale", "Idioma preferido (es|en) para los mensajes de error de OpenCode"},
+	{"stream_edits", "Editar el mensaje en vivo mientras OpenCode responde (true|false)"},
+	{"notify_on_error", "Avisar por Telegram cuando falla el envio a OpenCode (true|false)"},
+}
+
+func isKnownConfigKey(key string) bool {
+	for _, def := range configKeyDefs {
+		if def.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// validateConfigValue parses and normalizes raw for key, returning the string
+// form persisted via ports.UserPrefsRepository, or an error with a
+// user-facing (Spanish) explanation.
+func validateConfigValue(key string, raw string) (string, error) {
+	switch key {
+	case "sessions_limit":
+		n, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil || n <= 0 || n > 50 {
+			return "", fmt.Errorf("debe ser un numero entre 1 y 50")
+		}
+		return strconv.Itoa(n), nil
+	case "sessions_source":
+		v := strings.ToLower(strings.TrimSpace(raw))
+		if v != "endpoint" && v != "cli" && v != "both" {
+			return "", fmt.Errorf("debe ser 'endpoint', 'cli' o 'both'")
+		}
+		return v, nil
+	case "sessions_show_id_list", "stream_edits", "notify_on_error":
+		return parseBoolPref(raw)
+	case "locale":
+		v := strings.ToLower(strings.TrimSpace(raw))
+		if !i18n.IsSupported(v) {
+			return "", fmt.Errorf("debe ser uno de %v", i18n.SupportedLocales)
+		}
+		return v, nil
+	default:
+		return "", fmt.Errorf("clave de configuracion desconocida")
+	}
+}
+
+func parseBoolPref(raw string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "true", "1", "si", "on":
+		return "true", nil
+	case "false", "0", "no", "off":
+		return "false", nil
+	default:
+		return "", fmt.Errorf("debe ser true o false")
+	}
+}
+
+// effectivePrefs is the per-user view of every /config knob: sessionsCfg's
+// values as defaults, overridden per key by whatever the user has set via
+// /config and persisted in ports.UserPrefsRepository.
+type effectivePrefs struct {
+	sessionsLimit      int
+	sessionsSource     string
+	sessionsShowIDList bool
+	locale             string
+	streamEdits        bool
+	notifyOnError      bool
+}
+
+func (p effectivePrefs) display(key string) string {
+	switch key {
+	case "sessions_limit":
+		return strconv.Itoa(p.sessionsLimit)
+	case "sessions_source":
+		return p.sessionsSource
+	case "sessions_show_id_list":
+		return strconv.FormatBool(p.sessionsShowIDList)
+	case "locale":
+		return p.locale
+	case "stream_edits":
+		return strconv.FormatBool(p.streamEdits)
+	case "notify_on_error":
+		return strconv.FormatBool(p.notifyOnError)
+	default:
+		return "?"
+	}
+}
+
+// getEffectivePrefs merges sessionsCfg's defaults with userID's stored
+// /config overrides, if any. A repository error or missing userPrefs
+// repository silently falls back to defaults, matching chatMode's behavior
+// for chatSettings.
+func (s *BridgeService) getEffectivePrefs(ctx context.Context, userID int64) effectivePrefs {
+	prefs := effectivePrefs{
+		sessionsLimit:      s.sessionsCfg.limit,
+		sessionsSource:     s.sessionsCfg.source,
+		sessionsShowIDList: s.sessionsCfg.showIDList,
+		locale:             s.defaultLocale,
+		streamEdits:        true,
+		notifyOnError:      true,
+	}
+	if s.userPrefs == nil {
+		return prefs
+	}
+	stored, err := s.userPrefs.ListUserPrefs(ctx, userID)
+	if err != nil {
+		return prefs
+	}
+	if v, ok := stored["sessions_limit"]; ok {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			prefs.sessionsLimit = n
+		}
+	}
+	if v, ok := stored["sessions_source"]; ok && v != "" {
+		prefs.sessionsSource = v
+	}
+	if v, ok := stored["sessions_show_id_list"]; ok {
+		prefs.sessionsShowIDList = v == "true"
+	}
+	if v, ok := stored["locale"]; ok && v != "" {
+		prefs.locale = v
+	}
+	if v, ok := stored["stream_edits"]; ok {
+		prefs.streamEdits = v == "true"
+	}
+	if v, ok := stored["notify_on_error"]; ok {
+		prefs.notifyOnError = v == "true"
+	}
+	return prefs
+}
+
+// handleConfig implements telegabber-style "/config [key] [value]": no
+// arguments lists every knob, one argument shows it, two sets it for the
+// calling user.
+func (s *BridgeService) handleConfig(ctx context.Context, message telegram.Message, args []string) {
+	if !s.requireAllowed(ctx, message) {
+		return
+	}
+	if s.userPrefs == nil {
+		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "Configuracion por usuario no disponible.")
+		return
+	}
+	prefs := s.getEffectivePrefs(ctx, message.From.ID)
+
+	if len(args) == 0 {
+		lines := []string{"Configuracion actual:"}
+		for _, def := range configKeyDefs {
+			lines = append(lines, fmt.Sprintf("- %s: %s (%s)", def.key, prefs.display(def.key), def.label))
+		}
+		lines = append(lines, "Uso: /config <clave> | /config <clave> <valor>")
+		if s.configMgr != nil {
+			lines = append(lines, "Admins: /config set <clave> <valor> ("+strings.Join(config.OverridableKeys(), ", ")+")")
+		}
+		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, strings.Join(lines, "\n"))
+		return
+	}
+
+	if strings.EqualFold(args[0], "set") {
+		s.handleConfigSet(ctx, message, args[1:])
+		return
+	}
+
+	key := strings.ToLower(strings.TrimSpace(args[0]))
+	if !isKnownConfigKey(key) {
+		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "Clave de configuracion desconocida: "+key)
+		return
+	}
+
+	if len(args) == 1 {
+		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, fmt.Sprintf("%s: %s", key, prefs.display(key)))
+		return
+	}
+
+	stored, err := validateConfigValue(key, strings.Join(args[1:], " "))
+	if err != nil {
+		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "Valor invalido para "+key+": "+err.Error())
+		return
+	}
+	if err := s.userPrefs.UpsertUserPref(ctx, message.From.ID, key, stored); err != nil {
+		s.logger.Error("set user pref failed", "error", err, "key", key, "user_id", message.From.ID)
+		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "No se pudo guardar la configuracion.")
+		return
+	}
+	_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, fmt.Sprintf("%s actualizado: %s", key, stored))
+}
+
+// handleConfigSet implements the admin-only "/config set <key> <value>"
+// form, distinct from the per-user keys handleConfig otherwise manages: it
+// writes to the db layer of the process-wide config.Manager (RELAY_MODE,
+// LOG_LEVEL, ALLOWED_USER_IDS, OPENCODE_TIMEOUT_MS) rather than a per-user
+// preference, taking effect immediately for every chat without a restart.
+func (s *BridgeService) handleConfigSet(ctx context.Context, message telegram.Message, args []string) {
+	if !s.requireAdmin(ctx, message) {
+		return
+	}
+	if s.configMgr == nil {
+		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "Configuracion en caliente no disponible.")
+		return
+	}
+	if len(args) < 2 {
+		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "Uso: /config set <clave> <valor>. Claves: "+strings.Join(config.OverridableKeys(), ", "))
+		return
+	}
+	key := strings.ToUpper(strings.TrimSpace(args[0]))
+	value := strings.Join(args[1:], " ")
+	if err := s.configMgr.SetOverride(ctx, key, value); err != nil {
+		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "No se pudo actualizar "+key+": "+err.Error())
+		return
+	}
+	_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, fmt.Sprintf("%s actualizado: %s", key, value))
+}
+
+// handleLang implements "/lang [locale]" as a shorthand for "/config locale
+// [locale]": no argument reports the calling user's current locale, one
+// argument sets it. It's the same underlying "locale" preference as
+// /config, just under the friendlier name used by similar bots.
+func (s *BridgeService) handleLang(ctx context.Context, message telegram.Message, args []string) {
+	if !s.requireAllowed(ctx, message) {
+		return
+	}
+	if s.userPrefs == nil {
+		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "Configuracion por usuario no disponible.")
+		return
+	}
+	if len(args) == 0 {
+		prefs := s.getEffectivePrefs(ctx, message.From.ID)
+		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, fmt.Sprintf("Idioma actual: %s. Uso: /lang <%s>", prefs.locale, strings.Join(i18n.SupportedLocales, "|")))
+		return
+	}
+	stored, err := validateConfigValue("locale", args[0])
+	if err != nil {
+		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "Idioma invalido: "+err.Error())
+		return
+	}
+	if err := s.userPrefs.UpsertUserPref(ctx, message.From.ID, "locale", stored); err != nil {
+		s.logger.Error("set locale pref failed", "error", err, "user_id", message.From.ID)
+		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "No se pudo guardar el idioma.")
+		return
+	}
+	_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "Idioma actualizado: "+stored)
+}
+
 func (s *BridgeService) handleSessions(ctx context.Context, message telegram.Message) {
 	if !s.requireAllowed(ctx, message) {
 		return
 	}
-	current, err := s.sessions.GetSession(ctx, message.Chat.ID, message.From.ID)
+	prefs := s.getEffectivePrefs(ctx, message.From.ID)
+	current, err := s.sessions.GetSession(ctx, message.Chat.ID, s.effectiveSessionUserID(ctx, message.Chat.ID, message.From.ID))
 	if err != nil {
 		s.logger.Error("get session for sessions failed", "error", err)
 		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "No se pudo obtener la sesion actual.")
 		return
 	}
-	list, err := s.opencode.ListSessionsWithCurrent(ctx, current, s.sessionsCfg.limit, s.sessionsCfg.source)
+	list, err := s.opencode.ListSessionsWithCurrent(ctx, current, prefs.sessionsLimit, prefs.sessionsSource)
 	if err != nil {
 		s.logger.Error("list sessions failed", "error", err)
 		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "No se pudieron listar sesiones de OpenCode.")
@@ -323,7 +1104,7 @@ func (s *BridgeService) handleSessions(ctx context.Context, message telegram.Mes
 		return
 	}
 	lines := make([]string, 0, len(list)+2)
-	if s.sessionsCfg.showIDList {
+	if prefs.sessionsShowIDList {
 		lines = append(lines, "Sesiones recientes:")
 	} else {
 		lines = append(lines, "Sesiones recientes (toca para seleccionar):")
@@ -334,7 +1115,7 @@ func (s *BridgeService) handleSessions(ctx context.Context, message telegram.Mes
 		if item.ID == current {
 			suffix = " [actual]"
 		}
-		if s.sessionsCfg.showIDList {
+		if prefs.sessionsShowIDList {
 			lines = append(lines, fmt.Sprintf("%d. %s (%s)%s", i+1, item.Title, item.ID, suffix))
 		}
 
@@ -364,7 +1145,7 @@ func (s *BridgeService) handleSession(ctx context.Context, message telegram.Mess
 		return
 	}
 	if len(args) == 0 {
-		current, err := s.sessions.GetSession(ctx, message.Chat.ID, message.From.ID)
+		current, err := s.sessions.GetSession(ctx, message.Chat.ID, s.effectiveSessionUserID(ctx, message.Chat.ID, message.From.ID))
 		if err != nil {
 			s.logger.Error("get session failed", "error", err)
 			_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "No se pudo obtener la sesion actual.")
@@ -373,22 +1154,25 @@ func (s *BridgeService) handleSession(ctx context.Context, message telegram.Mess
 		if current == "" {
 			current = "(nueva en el proximo mensaje)"
 		}
-		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "Sesion actual: "+current+"\nUso: /session list | /session use <ses_...> | /session new")
+		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "Sesion actual: "+current+"\nUso: /session list | /session use <ses_...> | /session new | /session mode private|shared")
 		return
 	}
 
 	switch args[0] {
 	case "list":
 		s.handleSessions(ctx, message)
+	case "mode":
+		s.handleSessionMode(ctx, message, args[1:])
 	case "new":
-		if err := s.sessions.ClearSession(ctx, message.Chat.ID, message.From.ID); err != nil {
+		sessionUserID := s.effectiveSessionUserID(ctx, message.Chat.ID, message.From.ID)
+		if err := s.sessions.ClearSession(ctx, message.Chat.ID, sessionUserID); err != nil {
 			s.logger.Error("clear session failed", "error", err)
 			_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "No se pudo reiniciar la sesion.")
 			return
 		}
 		defaultSessionID := s.sessions.DefaultSessionID()
 		if defaultSessionID != "" {
-			if err := s.sessions.SetSession(ctx, message.Chat.ID, message.From.ID, defaultSessionID); err != nil {
+			if err := s.sessions.SetSession(ctx, message.Chat.ID, sessionUserID, defaultSessionID); err != nil {
 				s.logger.Error("set default session failed", "error", err)
 				_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "Sesion reiniciada, pero no se pudo aplicar la sesion default.")
 				return
@@ -402,7 +1186,7 @@ func (s *BridgeService) handleSession(ctx context.Context, message telegram.Mess
 			_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "Uso: /session use <ses_...>")
 			return
 		}
-		if err := s.sessions.SetSession(ctx, message.Chat.ID, message.From.ID, args[1]); err != nil {
+		if err := s.sessions.SetSession(ctx, message.Chat.ID, s.effectiveSessionUserID(ctx, message.Chat.ID, message.From.ID), args[1]); err != nil {
 			s.logger.Error("set session failed", "error", err)
 			_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "No se pudo cambiar la sesion.")
 			return
@@ -413,6 +1197,40 @@ func (s *BridgeService) handleSession(ctx context.Context, message telegram.Mess
 	}
 }
 
+// handleSessionMode implements "/session mode [private|shared]". Gated on
+// requireAdmin since that's the only notion of "admin" this bridge tracks;
+// it doesn't yet integrate with Telegram's own per-chat admin roles (that
+// would need a getChatMember call this package hasn't implemented), so for
+// now any bridge admin can flip the mode of any group the bot is in.
+func (s *BridgeService) handleSessionMode(ctx context.Context, message telegram.Message, args []string) {
+	if !s.requireAdmin(ctx, message) {
+		return
+	}
+	if message.Chat.Type != telegramGroupChatType && message.Chat.Type != telegramSupergroupChatType {
+		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "El modo de sesion compartida solo aplica a chats grupales.")
+		return
+	}
+	if s.chatSettings == nil {
+		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "Configuracion de chat no disponible.")
+		return
+	}
+	if len(args) == 0 {
+		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "Modo de sesion actual: "+s.chatMode(ctx, message.Chat.ID)+"\nUso: /session mode private | /session mode shared")
+		return
+	}
+	mode := strings.ToLower(strings.TrimSpace(args[0]))
+	if mode != chatModePrivate && mode != chatModeShared {
+		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "Uso: /session mode private | /session mode shared")
+		return
+	}
+	if err := s.chatSettings.UpsertChatMode(ctx, message.Chat.ID, mode); err != nil {
+		s.logger.Error("set chat mode failed", "error", err, "chat_id", message.Chat.ID)
+		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "No se pudo cambiar el modo de sesion.")
+		return
+	}
+	_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "Modo de sesion actualizado: "+mode)
+}
+
 func (s *BridgeService) handleModels(ctx context.Context, message telegram.Message, args []string) {
 	if !s.requireAllowed(ctx, message) {
 		return
@@ -442,7 +1260,7 @@ func (s *BridgeService) handleModels(ctx context.Context, message telegram.Messa
 		return
 	}
 
-	sessionID, err := s.sessions.GetSession(ctx, message.Chat.ID, message.From.ID)
+	sessionID, err := s.sessions.GetSession(ctx, message.Chat.ID, s.effectiveSessionUserID(ctx, message.Chat.ID, message.From.ID))
 	if err != nil {
 		s.logger.Error("get session for models failed", "error", err)
 		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "No se pudo obtener la sesion actual.")
@@ -574,6 +1392,64 @@ func (s *BridgeService) handleList(ctx context.Context, message telegram.Message
 	_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, text)
 }
 
+// handleQuota implements the admin-only "/quota set <user> <requests/day>
+// <tokens/month>" and "/quota show <user>" subcommands against rateLimiter.
+func (s *BridgeService) handleQuota(ctx context.Context, message telegram.Message, args []string) {
+	if !s.requireAdmin(ctx, message) {
+		return
+	}
+	if s.rateLimiter == nil {
+		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "Rate limiting no esta habilitado.")
+		return
+	}
+	if len(args) < 2 {
+		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "Uso: /quota set <telegramUserId> <solicitudes/dia> <tokens/mes> | /quota show <telegramUserId>")
+		return
+	}
+
+	userID, err := parseTelegramID(args[1])
+	if err != nil {
+		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "telegramUserId invalido.")
+		return
+	}
+
+	switch args[0] {
+	case "set":
+		if len(args) != 4 {
+			_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "Uso: /quota set <telegramUserId> <solicitudes/dia> <tokens/mes>")
+			return
+		}
+		dailyRequests, err := strconv.Atoi(args[2])
+		if err != nil {
+			_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "solicitudes/dia invalido.")
+			return
+		}
+		monthlyTokens, err := strconv.Atoi(args[3])
+		if err != nil {
+			_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "tokens/mes invalido.")
+			return
+		}
+		if err := s.rateLimiter.SetLimits(ctx, userID, dailyRequests, monthlyTokens); err != nil {
+			s.logger.Error("set quota failed", "error", err, "target_user_id", userID)
+			_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "No se pudo actualizar la cuota.")
+			return
+		}
+		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, fmt.Sprintf("Cuota actualizada para %d: %d solicitudes/dia, %d tokens/mes", userID, dailyRequests, monthlyTokens))
+	case "show":
+		status, err := s.rateLimiter.Status(ctx, userID)
+		if err != nil {
+			s.logger.Error("get quota failed", "error", err, "target_user_id", userID)
+			_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "No se pudo consultar la cuota.")
+			return
+		}
+		text := fmt.Sprintf("Cuota de %d\n• Solicitudes: %d/%d\n• Tokens: %d/%d\n• Ventana desde: %s",
+			userID, status.RequestCount, status.DailyRequestLimit, status.TokenCount, status.MonthlyTokenLimit, status.WindowStart.Format(time.RFC3339))
+		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, text)
+	default:
+		_ = s.telegramAPI.SendMessage(ctx, message.Chat.ID, "Uso: /quota set <telegramUserId> <solicitudes/dia> <tokens/mes> | /quota show <telegramUserId>")
+	}
+}
+
 func parseTelegramID(value string) (int64, error) {
 	trimmed := strings.TrimSpace(value)
 	if trimmed == "" {
@@ -600,14 +1476,106 @@ func formatIDList(items []int64) string {
 	return strings.Join(parts, ", ")
 }
 
-func (s *BridgeService) waitForAssistantResponse(ctx context.Context, chatID int64, sessionID string, previous opencode.AssistantSnapshot) (string, error) {
+// assistantStream tracks the placeholder message waitForAssistantResponse is
+// incrementally editing for a single chat, rotating into a follow-up message
+// once the content overflows Telegram's per-message limit. Unlike
+// RelayService's stream mode it keeps this bookkeeping in memory rather than
+// in a ports.StreamMessageRepository: a BridgeService reply lives entirely
+// within one waitForAssistantResponse call, so there is nothing to resume
+// across a restart.
+type assistantStream struct {
+	chatID     int64
+	messageID  int64
+	messageIDs []int64
+	offset     int
+	lastEditAt time.Time
+	lastLen    int
+}
+
+// startAssistantStream posts the initial placeholder message, attaching a
+// persistent "Cancelar" button so the user can abort without typing /cancel,
+// and returns the stream state used to edit it in place as the assistant's
+// reply grows.
+func (s *BridgeService) startAssistantStream(ctx context.Context, chatID int64, placeholder string) (*assistantStream, error) {
+	buttons := [][]telegram.InlineKeyboardButton{{{Text: "Cancelar", CallbackData: "cancel"}}}
+	messageID, err := s.telegramAPI.SendMessageWithInlineKeyboardReturningID(ctx, chatID, placeholder, buttons)
+	if err != nil {
+		return nil, fmt.Errorf("send stream placeholder: %w", err)
+	}
+	return &assistantStream{chatID: chatID, messageID: messageID, messageIDs: []int64{messageID}}, nil
+}
+
+// cancelAssistantStream edits the in-flight placeholder to "Cancelado." using
+// a fresh context, since the one waitForAssistantResponse was polling with is
+// already canceled by the time this runs.
+func (s *BridgeService) cancelAssistantStream(stream *assistantStream) {
+	cleanupCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.telegramAPI.EditMessageText(cleanupCtx, stream.chatID, stream.messageID, "Cancelado."); err != nil {
+		s.logger.Error("cancel stream edit failed", "chat_id", stream.chatID, "error", err)
+	}
+}
+
+// updateAssistantStream edits the in-flight message(s) to reflect text's
+// current content. Edits are throttled to bridgeStreamEditInterval unless
+// force is set (used for the authoritative final update) or the unsent
+// portion grew by at least bridgeStreamEditMinDelta bytes. When the unsent
+// portion would overflow Telegram's per-message limit it finalizes the
+// current message with a continuation marker and starts editing a new one
+// from the overflow point, mirroring RelayService.streamEdit's rotation.
+func (s *BridgeService) updateAssistantStream(ctx context.Context, stream *assistantStream, text string, force bool) error {
+	if strings.TrimSpace(text) == "" || stream.offset > len(text) {
+		return nil
+	}
+	if !force && time.Since(stream.lastEditAt) < bridgeStreamEditInterval && len(text)-stream.lastLen < bridgeStreamEditMinDelta {
+		return nil
+	}
+
+	remaining := text[stream.offset:]
+	if len(remaining) <= telegramMessageMaxChars {
+		if err := s.telegramAPI.EditMessageText(ctx, stream.chatID, stream.messageID, remaining); err != nil {
+			return err
+		}
+	} else {
+		if err := s.telegramAPI.EditMessageText(ctx, stream.chatID, stream.messageID, remaining[:telegramMessageMaxChars]+streamContinuationSuffix); err != nil {
+			return fmt.Errorf("finalize rotated stream message: %w", err)
+		}
+		newOffset := stream.offset + telegramMessageMaxChars
+		newID, err := s.telegramAPI.SendMessageReturningID(ctx, stream.chatID, streamContinuationPrefix+text[newOffset:])
+		if err != nil {
+			return fmt.Errorf("start rotated stream message: %w", err)
+		}
+		stream.messageID = newID
+		stream.messageIDs = append(stream.messageIDs, newID)
+		stream.offset = newOffset
+	}
+
+	stream.lastEditAt = time.Now()
+	stream.lastLen = len(text)
+	return nil
+}
+
+// waitForAssistantResponse polls sessionID until OpenCode produces a new
+// assistant reply, incrementally editing a placeholder message as it arrives.
+// streamEdits controls only the in-progress edits (the user's "stream_edits"
+// /config preference): the authoritative final edit always happens so the
+// reply is still delivered when disabled, just without the live preview. The
+// returned message IDs are every Telegram message the reply ended up in
+// (usually one, more if it overflowed into continuation messages), recorded
+// so /retry and /edit can delete them before re-running the prompt.
+func (s *BridgeService) waitForAssistantResponse(ctx context.Context, chatID int64, sessionID string, previous opencode.AssistantSnapshot, streamEdits bool) (string, []int64, int, error) {
 	if strings.TrimSpace(sessionID) == "" {
-		return "", fmt.Errorf("session id is empty")
+		return "", nil, 0, fmt.Errorf("session id is empty")
 	}
 
 	waitCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
 	defer cancel()
 
+	stream, err := s.startAssistantStream(waitCtx, chatID, "Procesando solicitud...")
+	if err != nil {
+		return "", nil, 0, err
+	}
+
 	stopTyping := make(chan struct{})
 	defer close(stopTyping)
 
@@ -628,17 +1596,35 @@ func (s *BridgeService) waitForAssistantResponse(ctx context.Context, chatID int
 
 	_ = s.telegramAPI.SendChatAction(waitCtx, chatID, "typing")
 
+	finalize := func(snapshot opencode.AssistantSnapshot) (string, []int64, int, error) {
+		trimmed := strings.TrimSpace(snapshot.Last)
+		if trimmed != "" {
+			if err := s.updateAssistantStream(waitCtx, stream, trimmed, true); err != nil {
+				s.logger.Error("final assistant stream edit failed", "chat_id", chatID, "session_id", sessionID, "error", err)
+			}
+		}
+		if len(snapshot.Attachments) > 0 {
+			s.sendAssistantAttachments(waitCtx, chatID, snapshot.Attachments)
+		}
+		return trimmed, stream.messageIDs, usageTokens(snapshot), nil
+	}
+
 	lastState := "unknown"
 	lastSnapshot := previous
 	for {
 		now, snapErr := s.opencode.GetAssistantSnapshot(waitCtx, sessionID)
 		if snapErr == nil {
 			lastSnapshot = now
-			if now.Count > previous.Count && strings.TrimSpace(now.Last) != "" {
-				return strings.TrimSpace(now.Last), nil
+			if trimmed := strings.TrimSpace(now.Last); streamEdits && trimmed != "" && now.Revision != previous.Revision {
+				if err := s.updateAssistantStream(waitCtx, stream, trimmed, false); err != nil {
+					s.logger.Error("assistant stream edit failed", "chat_id", chatID, "session_id", sessionID, "error", err)
+				}
 			}
-			if strings.TrimSpace(now.Last) != "" && strings.TrimSpace(now.Last) != strings.TrimSpace(previous.Last) {
-				return strings.TrimSpace(now.Last), nil
+			if now.Count > previous.Count && hasAssistantContent(now) {
+				return finalize(now)
+			}
+			if hasAssistantContent(now) && strings.TrimSpace(now.Last) != strings.TrimSpace(previous.Last) {
+				return finalize(now)
 			}
 		}
 
@@ -647,40 +1633,84 @@ func (s *BridgeService) waitForAssistantResponse(ctx context.Context, chatID int
 			lastState = state
 		}
 		if stateErr == nil && isErrorState(state) {
-			if strings.TrimSpace(lastSnapshot.Last) != "" {
-				return strings.TrimSpace(lastSnapshot.Last), nil
+			if hasAssistantContent(lastSnapshot) {
+				return finalize(lastSnapshot)
 			}
-			return "", fmt.Errorf("opencode session entered error state: %s", state)
+			return "", nil, 0, fmt.Errorf("opencode session entered error state: %s", state)
 		}
 		if stateErr == nil && isIdleState(state) {
 			snapshot, idleSnapErr := s.opencode.GetAssistantSnapshot(waitCtx, sessionID)
-			if idleSnapErr == nil && strings.TrimSpace(snapshot.Last) != "" {
+			if idleSnapErr == nil && hasAssistantContent(snapshot) {
 				lastSnapshot = snapshot
-				return strings.TrimSpace(snapshot.Last), nil
+				return finalize(snapshot)
 			}
 		}
 
 		if waitCtx.Err() != nil {
 			if errors.Is(waitCtx.Err(), context.Canceled) {
-				return "", context.Canceled
+				s.cancelAssistantStream(stream)
+				return "", nil, 0, context.Canceled
 			}
 			if errors.Is(waitCtx.Err(), context.DeadlineExceeded) {
-				return "", fmt.Errorf("timeout waiting for OpenCode response")
+				return "", nil, 0, fmt.Errorf("timeout waiting for OpenCode response")
 			}
-			return "", waitCtx.Err()
+			return "", nil, 0, waitCtx.Err()
 		}
 
 		select {
 		case <-waitCtx.Done():
 			if errors.Is(waitCtx.Err(), context.Canceled) {
-				return "", context.Canceled
+				s.cancelAssistantStream(stream)
+				return "", nil, 0, context.Canceled
 			}
-			return "", fmt.Errorf("timeout waiting for OpenCode response (state=%s assistant_count=%d last_len=%d)", lastState, lastSnapshot.Count, len(lastSnapshot.Last))
+			return "", nil, 0, fmt.Errorf("timeout waiting for OpenCode response (state=%s assistant_count=%d last_len=%d)", lastState, lastSnapshot.Count, len(lastSnapshot.Last))
 		case <-time.After(2 * time.Second):
 		}
 	}
 }
 
+// usageTokens sums the input/output token counts from every usage part in
+// snapshot, so waitForAssistantResponse's caller can feed a turn's real
+// OpenCode usage into RateLimiter.RecordTokens instead of guessing a flat
+// cost per prompt.
+func usageTokens(snapshot opencode.AssistantSnapshot) int {
+	total := 0
+	for _, part := range snapshot.Parts {
+		if part.Usage != nil {
+			total += part.Usage.InputTokens + part.Usage.OutputTokens
+		}
+	}
+	return total
+}
+
+// hasAssistantContent reports whether snapshot carries anything worth
+// rendering: assistant text, or an image/file part with none.
+func hasAssistantContent(snapshot opencode.AssistantSnapshot) bool {
+	return strings.TrimSpace(snapshot.Last) != "" || len(snapshot.Attachments) > 0
+}
+
+// sendAssistantAttachments uploads each of an assistant reply's image/file
+// parts to chatID, choosing SendPhoto or SendDocument the same way
+// RelayService.sendToRecipient does for relayed attachments.
+func (s *BridgeService) sendAssistantAttachments(ctx context.Context, chatID int64, attachments []opencode.Attachment) {
+	for _, attachment := range attachments {
+		data, err := os.ReadFile(attachment.Path)
+		if err != nil {
+			s.logger.Error("assistant attachment read failed", "chat_id", chatID, "path", attachment.Path, "error", err)
+			continue
+		}
+		port := ports.Attachment{Filename: filepath.Base(attachment.Path), MIME: attachment.MIME, Data: data}
+		if isImageMIME(port.MIME, port.Filename) {
+			err = s.telegramAPI.SendPhoto(ctx, chatID, port, "")
+		} else {
+			err = s.telegramAPI.SendDocument(ctx, chatID, port, "")
+		}
+		if err != nil {
+			s.logger.Error("assistant attachment send failed", "chat_id", chatID, "path", attachment.Path, "error", err)
+		}
+	}
+}
+
 func isIdleState(state string) bool {
 	trimmed := strings.ToLower(strings.TrimSpace(state))
 	return trimmed == "idle" || trimmed == "completed" || trimmed == "done" || trimmed == "ready"
@@ -737,19 +1767,47 @@ func normalizeUnixMillis(raw int64) int64 {
 	}
 }
 
-func userFacingOpenCodeError(err error) string {
-	if err == nil {
-		return "No se pudo enviar el mensaje a OpenCode."
+// opencodeErrorKey maps an OpenCodeError's Kind to its i18n catalog key.
+func opencodeErrorKey(kind opencode.OpenCodeErrorKind) string {
+	switch kind {
+	case opencode.KindUnavailable:
+		return "err.opencode.unavailable"
+	case opencode.KindUnauthorized:
+		return "err.opencode.unauthorized"
+	case opencode.KindTimeout:
+		return "err.opencode.timeout"
+	case opencode.KindBadRequest:
+		return "err.opencode.bad_request"
+	case opencode.KindServerError:
+		return "err.opencode.server_error"
+	default:
+		return "err.opencode.generic"
 	}
-	text := strings.ToLower(strings.TrimSpace(err.Error()))
-	if strings.Contains(text, "connect: connection refused") || strings.Contains(text, "no such host") {
-		return "OpenCode no esta disponible. Revisa OPENCODE_SERVER_URL y que el servidor este corriendo."
+}
+
+// userFacingOpenCodeError renders err as a message in localizer's locale.
+// Errors from the OpenCode client carry a typed *opencode.OpenCodeError, so
+// this is mostly a switch over Kind rather than string-matching. The one
+// remaining string check covers errors this package raises itself (e.g.
+// waitForAssistantResponse's local "timeout waiting for..." error), which
+// never go through the client's request path and so are never
+// OpenCodeErrors; it's a plain substring check rather than its own catalog
+// key/kind since it's the only such case.
+func userFacingOpenCodeError(err error, localizer i18n.Localizer) string {
+	if err == nil {
+		return localizer.Message("err.opencode.generic")
 	}
-	if strings.Contains(text, "status 401") || strings.Contains(text, "status 403") || strings.Contains(text, "unauthorized") {
-		return "OpenCode rechazo credenciales. Revisa OPENCODE_SERVER_USERNAME y OPENCODE_SERVER_PASSWORD."
+
+	var ocErr *opencode.OpenCodeError
+	if errors.As(err, &ocErr) {
+		msg := localizer.Message(opencodeErrorKey(ocErr.Kind))
+		if ocErr.Detail != "" {
+			msg = localizer.Message("err.opencode.detail_suffix", msg, ocErr.Detail)
+		}
+		return msg
 	}
-	if strings.Contains(text, "context deadline exceeded") || strings.Contains(text, "timeout") {
-		return "OpenCode no respondio a tiempo. Revisa OPENCODE_TIMEOUT_MS o la carga del servidor."
+	if strings.Contains(strings.ToLower(err.Error()), "timeout") {
+		return localizer.Message("err.opencode.timeout")
 	}
-	return "No se pudo enviar el mensaje a OpenCode."
+	return localizer.Message("err.opencode.generic")
 }
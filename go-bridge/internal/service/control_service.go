@@ -21,6 +21,15 @@ type ControlService struct {
 	opencode   *opencode.Client
 	listLimit  int
 	listSource string
+	relay      *RelayService
+}
+
+// SetRelayService wires the RelayService so SessionNew can cancel any relay
+// work still in flight for the session being replaced. Set post-construction
+// (mirroring SetActivityHook) since RelayService is built after
+// ControlService in cmd/bridge/main.go.
+func (s *ControlService) SetRelayService(relay *RelayService) {
+	s.relay = relay
 }
 
 func NewControlService(
@@ -69,9 +78,19 @@ func (s *ControlService) SessionUse(ctx context.Context, chatID int64, userID in
 }
 
 func (s *ControlService) SessionNew(ctx context.Context, chatID int64, userID int64) (string, error) {
+	previousID, err := s.sessions.GetSession(ctx, chatID, userID)
+	if err != nil {
+		return "", err
+	}
 	if err := s.sessions.ClearSession(ctx, chatID, userID); err != nil {
 		return "", err
 	}
+	if s.relay != nil && previousID != "" {
+		// Abort any pending fallback timer or opencode fetch for the session
+		// just replaced, so its stale "final" message can't land in Telegram
+		// after the user has already moved on.
+		s.relay.CancelSession(previousID)
+	}
 	if defaultID := s.sessions.DefaultSessionID(); defaultID != "" {
 		if err := s.sessions.SetSession(ctx, chatID, userID, defaultID); err != nil {
 			return "", err
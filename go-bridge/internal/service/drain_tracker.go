@@ -0,0 +1,61 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+)
+
+// DrainTracker counts Telegram updates currently being handled so a graceful
+// shutdown can wait for them to finish instead of cutting them off mid-way.
+// It also exposes a Draining flag the webhook handler checks to decide
+// whether to ack a request before or after processing it: once draining,
+// new updates are acked immediately (so Telegram stops retrying) and handed
+// off to a tracked goroutine instead of being processed inline.
+type DrainTracker struct {
+	draining atomic.Bool
+	wg       sync.WaitGroup
+}
+
+// NewDrainTracker builds a tracker with nothing in flight.
+func NewDrainTracker() *DrainTracker {
+	return &DrainTracker{}
+}
+
+// Add marks one more update as in flight. Call Done when it's handled.
+func (t *DrainTracker) Add() {
+	t.wg.Add(1)
+}
+
+// Done marks an in-flight update as handled.
+func (t *DrainTracker) Done() {
+	t.wg.Done()
+}
+
+// Draining reports whether SetDraining(true) has been called.
+func (t *DrainTracker) Draining() bool {
+	return t.draining.Load()
+}
+
+// SetDraining flips the draining flag callers check before deciding how to
+// ack a new update.
+func (t *DrainTracker) SetDraining(draining bool) {
+	t.draining.Store(draining)
+}
+
+// Wait blocks until every tracked update finishes or ctx is done, reporting
+// whether everything finished before ctx expired.
+func (t *DrainTracker) Wait(ctx context.Context) bool {
+	done := make(chan struct{})
+	go func() {
+		t.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// IdleWatcher tracks the time of the last observed activity (a Telegram
+// update or a relayed opencode event) so the bridge can exit cleanly after a
+// configurable idle window instead of staying resident, which matters when
+// it's spawned on demand per user on a shared host.
+type IdleWatcher struct {
+	timeout time.Duration
+
+	mu   sync.Mutex
+	last time.Time
+}
+
+// NewIdleWatcher builds a watcher for the given idle timeout. A non-positive
+// timeout disables the watcher: Wait blocks until ctx is cancelled.
+func NewIdleWatcher(timeout time.Duration) *IdleWatcher {
+	return &IdleWatcher{timeout: timeout, last: time.Now()}
+}
+
+// Reset marks activity now, pushing the idle deadline back out.
+func (w *IdleWatcher) Reset() {
+	w.mu.Lock()
+	w.last = time.Now()
+	w.mu.Unlock()
+}
+
+func (w *IdleWatcher) idleSince() time.Duration {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return time.Since(w.last)
+}
+
+// Wait blocks until either the configured idle timeout has elapsed with no
+// Reset call, or ctx is cancelled first.
+func (w *IdleWatcher) Wait(ctx context.Context) {
+	if w.timeout <= 0 {
+		<-ctx.Done()
+		return
+	}
+
+	pollInterval := w.timeout / 4
+	if pollInterval <= 0 {
+		pollInterval = w.timeout
+	}
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if w.idleSince() >= w.timeout {
+				return
+			}
+		}
+	}
+}
@@ -3,23 +3,30 @@ package service
 import (
 	"context"
 	"sync"
+	"time"
 )
 
 type KeyedQueue struct {
-	mu     sync.Mutex
-	chains map[string]chan struct{}
+	mu      sync.Mutex
+	chains  map[string]chan struct{}
+	lastRun map[string]time.Time
+	cancels map[string]context.CancelFunc
 }
 
 func NewKeyedQueue() *KeyedQueue {
-	return &KeyedQueue{chains: map[string]chan struct{}{}}
+	return &KeyedQueue{
+		chains:  map[string]chan struct{}{},
+		lastRun: map[string]time.Time{},
+		cancels: map[string]context.CancelFunc{},
+	}
 }
 
+// Run executes fn in turn for key, deriving a cancellable context so a
+// concurrent Cancel(key) call can abort whichever fn is currently running
+// for that key.
 func (q *KeyedQueue) Run(ctx context.Context, key string, fn func(context.Context) error) error {
-	q.mu.Lock()
-	previous := q.chains[key]
-	next := make(chan struct{})
-	q.chains[key] = next
-	q.mu.Unlock()
+	previous, next := q.reserve(key)
+	defer q.release(key, next)
 
 	if previous != nil {
 		select {
@@ -29,14 +36,105 @@ func (q *KeyedQueue) Run(ctx context.Context, key string, fn func(context.Contex
 		}
 	}
 
+	runCtx, cancel := context.WithCancel(ctx)
+	q.mu.Lock()
+	q.cancels[key] = cancel
+	q.mu.Unlock()
 	defer func() {
-		close(next)
 		q.mu.Lock()
-		if q.chains[key] == next {
-			delete(q.chains, key)
-		}
+		delete(q.cancels, key)
 		q.mu.Unlock()
+		cancel()
+	}()
+
+	return fn(runCtx)
+}
+
+// Cancel aborts the fn currently running for key, if any, by canceling the
+// context Run derived for it. Reports whether there was anything to cancel.
+func (q *KeyedQueue) Cancel(key string) bool {
+	q.mu.Lock()
+	cancel, ok := q.cancels[key]
+	q.mu.Unlock()
+	if !ok {
+		return false
+	}
+	cancel()
+	return true
+}
+
+// RunThrottled behaves like Run but also enforces a minimum interval between
+// successive executions for the same key, implemented as a one-token bucket
+// that refills after minInterval. Used to keep Telegram message edits under
+// its ~1 edit/sec per message rate limit.
+func (q *KeyedQueue) RunThrottled(ctx context.Context, key string, minInterval time.Duration, fn func(context.Context) error) error {
+	return q.Run(ctx, key, q.throttle(key, minInterval, fn))
+}
+
+// RunThrottledAsync is RunThrottled without blocking the caller: the chain
+// position is reserved synchronously so ordering for the key is preserved,
+// but the wait and the work itself happen in a background goroutine. Used by
+// the relay's stream mode so a slow per-chat edit throttle never stalls the
+// opencode event loop.
+func (q *KeyedQueue) RunThrottledAsync(ctx context.Context, key string, minInterval time.Duration, fn func(context.Context) error, onError func(error)) {
+	previous, next := q.reserve(key)
+
+	go func() {
+		defer q.release(key, next)
+
+		if previous != nil {
+			select {
+			case <-previous:
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		if err := q.throttle(key, minInterval, fn)(ctx); err != nil && onError != nil {
+			onError(err)
+		}
 	}()
+}
+
+func (q *KeyedQueue) throttle(key string, minInterval time.Duration, fn func(context.Context) error) func(context.Context) error {
+	return func(ctx context.Context) error {
+		q.mu.Lock()
+		last, ok := q.lastRun[key]
+		q.mu.Unlock()
+		if ok {
+			if wait := minInterval - time.Since(last); wait > 0 {
+				timer := time.NewTimer(wait)
+				defer timer.Stop()
+				select {
+				case <-ctx.Done():
+					return ctx.Err()
+				case <-timer.C:
+				}
+			}
+		}
+
+		err := fn(ctx)
+		q.mu.Lock()
+		q.lastRun[key] = time.Now()
+		q.mu.Unlock()
+		return err
+	}
+}
 
-	return fn(ctx)
+func (q *KeyedQueue) reserve(key string) (chan struct{}, chan struct{}) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	previous := q.chains[key]
+	next := make(chan struct{})
+	q.chains[key] = next
+	return previous, next
+}
+
+func (q *KeyedQueue) release(key string, next chan struct{}) {
+	close(next)
+	q.mu.Lock()
+	if q.chains[key] == next {
+		delete(q.chains, key)
+	}
+	q.mu.Unlock()
 }
@@ -0,0 +1,11 @@
+package service
+
+import "github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/metrics"
+
+var (
+	updatesTotal = metrics.Default.Register(metrics.NewCounterVec(
+		"bridge_updates_total", "Telegram updates processed by kind.", "kind"))
+	commandDuration = metrics.Default.RegisterHistogram(metrics.NewHistogram(
+		"bridge_command_duration_seconds", "Time to handle a /command message end to end.",
+		[]float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}))
+)
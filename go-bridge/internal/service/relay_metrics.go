@@ -0,0 +1,51 @@
+package service
+
+import "github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/metrics"
+
+// relayMetricsBuckets covers sub-second dispatch latency up through a
+// generous 5-minute tail for slow opencode fallback fetches.
+var relayMetricsBuckets = []float64{0.1, 0.5, 1, 2, 5, 10, 30, 60, 120, 300}
+
+// relayMetrics groups every counter/gauge/histogram RelayService reports, all
+// registered on whichever *metrics.Registry is passed into NewRelayService so
+// tests can use an isolated registry instead of the process-wide Default.
+type relayMetrics struct {
+	eventsTotal        *metrics.CounterVec
+	eventsDroppedTotal *metrics.CounterVec
+	textTruncatedTotal *metrics.Counter
+	dispatchTotal      *metrics.CounterVec
+	fallbackFetchTotal *metrics.CounterVec
+	sendErrorsTotal    *metrics.Counter
+	idleToDispatch     *metrics.Histogram
+	sendDuration       *metrics.Histogram
+	openStreams        *metrics.Gauge
+	cachedSessions     *metrics.Gauge
+}
+
+func newRelayMetrics(registry *metrics.Registry) *relayMetrics {
+	if registry == nil {
+		registry = metrics.Default
+	}
+	return &relayMetrics{
+		eventsTotal: registry.Register(metrics.NewCounterVec(
+			"bridge_relay_events_total", "Opencode events processed by type.", "type")),
+		eventsDroppedTotal: registry.Register(metrics.NewCounterVec(
+			"bridge_relay_events_dropped_total", "Opencode events dropped by validateEvent/updateCache, by reason.", "reason")),
+		textTruncatedTotal: registry.RegisterCounter(metrics.NewCounter(
+			"bridge_relay_text_truncated_total", "Opencode event text truncated for exceeding the configured byte limit.")),
+		dispatchTotal: registry.Register(metrics.NewCounterVec(
+			"bridge_relay_dispatch_total", "Relay dispatch attempts by result.", "result")),
+		fallbackFetchTotal: registry.Register(metrics.NewCounterVec(
+			"bridge_relay_fallback_fetch_total", "Fallback fetches of the last assistant message by reason.", "reason")),
+		sendErrorsTotal: registry.RegisterCounter(metrics.NewCounter(
+			"bridge_telegram_send_errors_total", "Telegram sends from the relay that returned an error.")),
+		idleToDispatch: registry.RegisterHistogram(metrics.NewHistogram(
+			"bridge_relay_idle_to_dispatch_seconds", "Time between session.idle arriving and the first Telegram send.", relayMetricsBuckets)),
+		sendDuration: registry.RegisterHistogram(metrics.NewHistogram(
+			"bridge_telegram_send_seconds", "Duration of Telegram sends issued by the relay.", relayMetricsBuckets)),
+		openStreams: registry.RegisterGauge(metrics.NewGauge(
+			"bridge_relay_open_streams", "Opencode SSE streams currently being consumed by the relay.")),
+		cachedSessions: registry.RegisterGauge(metrics.NewGauge(
+			"bridge_relay_cached_sessions", "Sessions currently buffered in the relay's in-memory cache.")),
+	}
+}
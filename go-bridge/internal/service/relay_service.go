@@ -2,33 +2,127 @@ package service
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
+	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/domain"
+	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/metrics"
 	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/opencode"
 	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/ports"
+	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/telegram"
+)
+
+// defaultAttachmentThresholdBytes is the size above which a relayed message
+// is uploaded as a .md document instead of sent/truncated as plain text.
+const defaultAttachmentThresholdBytes = 3500
+
+const (
+	// telegramMessageMaxChars is Telegram's hard limit on a single message's
+	// text length; stream mode rotates to a new message past this point.
+	telegramMessageMaxChars = 4096
+	// streamEditInterval enforces Telegram's ~1 edit/sec per message limit.
+	streamEditInterval = time.Second
+
+	streamContinuationSuffix = "\n\n(continua abajo)"
+	streamContinuationPrefix = "(continuacion)\n\n"
+
+	// recipientFailingBackoff is how long a recipient that Telegram reports as
+	// unreachable (e.g. blocked the bot) is skipped before being retried.
+	recipientFailingBackoff = 10 * time.Minute
+
+	// defaultLeaseTTL bounds how long a replica may go unresponsive before
+	// another replica is allowed to claim a session's dispatch lease.
+	defaultLeaseTTL = 30 * time.Second
+
+	leaseKeyPrefix = "relay/session/"
+
+	// defaultMaxRelayTextBytes bounds how much of a single opencode event's
+	// Text is cached/relayed, so one misbehaving session can't fill memory
+	// or blow past Telegram's 4096-char message limit.
+	defaultMaxRelayTextBytes = 32 * 1024
 )
 
 type relayCacheEntry struct {
-	Text    string
-	Final   bool
-	Updated time.Time
+	Text        string
+	Final       bool
+	Updated     time.Time
+	Attachments []opencode.Attachment
 }
 
 type RelayService struct {
-	logger        *slog.Logger
-	opencode      *opencode.Client
-	repo          ports.SessionLinkRepository
-	telegram      ports.TelegramClient
-	mode          domain.RelayMode
-	fallback      bool
-	fallbackDelay time.Duration
+	logger                   *slog.Logger
+	opencode                 *opencode.Client
+	repo                     ports.SessionLinkRepository
+	telegram                 ports.TelegramClient
+	mode                     domain.RelayMode
+	fallback                 bool
+	fallbackDelay            time.Duration
+	attachmentThresholdBytes int
+	streamRepo               ports.StreamMessageRepository
+	streamQueue              *KeyedQueue
+	activityHook             func()
+	metrics                  *relayMetrics
+	eventBus                 ports.EventBus
+	leaseRepo                ports.LeaseRepository
+	replicaID                string
+	leaseTTL                 time.Duration
+	maxTextBytes             int
+
+	mu            sync.RWMutex
+	cache         map[string]relayCacheEntry
+	idleArrivedAt map[string]time.Time
+	sessionCtx    map[string]sessionContextEntry
+}
 
-	mu    sync.RWMutex
-	cache map[string]relayCacheEntry
+// sessionContextEntry pairs a per-session context with the cancel func that
+// tears it down, so fetchFinalText/the fallback timer can be aborted the
+// moment a newer message.updated or an explicit session reset supersedes
+// them.
+type sessionContextEntry struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// SetActivityHook registers a callback invoked whenever the relay processes
+// an opencode event, used by the idle-shutdown watcher to know the bridge is
+// still in use.
+func (s *RelayService) SetActivityHook(hook func()) {
+	s.activityHook = hook
+}
+
+// SetMode changes the relay mode ("last", "final" or "stream") while the
+// service is running, e.g. from config.Manager's "RELAY_MODE" subscriber. An
+// unrecognized value is ignored rather than falling back silently, since by
+// the time it reaches here config.Manager's own validation should already
+// have rejected it.
+func (s *RelayService) SetMode(mode string) {
+	var resolved domain.RelayMode
+	switch {
+	case strings.EqualFold(mode, string(domain.RelayModeFinal)):
+		resolved = domain.RelayModeFinal
+	case strings.EqualFold(mode, string(domain.RelayModeStream)):
+		resolved = domain.RelayModeStream
+	case strings.EqualFold(mode, string(domain.RelayModeLast)):
+		resolved = domain.RelayModeLast
+	default:
+		return
+	}
+	s.mu.Lock()
+	s.mode = resolved
+	s.mu.Unlock()
+}
+
+func (s *RelayService) currentMode() domain.RelayMode {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.mode
 }
 
 func NewRelayService(
@@ -39,25 +133,59 @@ func NewRelayService(
 	mode string,
 	fallback bool,
 	fallbackDelayMs int,
+	attachmentThresholdBytes int,
+	streamRepo ports.StreamMessageRepository,
+	registry *metrics.Registry,
+	eventBus ports.EventBus,
+	leaseRepo ports.LeaseRepository,
+	replicaID string,
+	leaseTTLSeconds int,
+	maxTextBytes int,
 ) *RelayService {
 	resolvedMode := domain.RelayModeLast
-	if strings.EqualFold(mode, string(domain.RelayModeFinal)) {
+	switch {
+	case strings.EqualFold(mode, string(domain.RelayModeFinal)):
 		resolvedMode = domain.RelayModeFinal
+	case strings.EqualFold(mode, string(domain.RelayModeStream)):
+		resolvedMode = domain.RelayModeStream
+	}
+	if attachmentThresholdBytes <= 0 {
+		attachmentThresholdBytes = defaultAttachmentThresholdBytes
+	}
+	leaseTTL := defaultLeaseTTL
+	if leaseTTLSeconds > 0 {
+		leaseTTL = time.Duration(leaseTTLSeconds) * time.Second
+	}
+	if maxTextBytes <= 0 {
+		maxTextBytes = defaultMaxRelayTextBytes
 	}
 	return &RelayService{
-		logger:        logger,
-		opencode:      opencodeClient,
-		repo:          repo,
-		telegram:      telegram,
-		mode:          resolvedMode,
-		fallback:      fallback,
-		fallbackDelay: time.Duration(fallbackDelayMs) * time.Millisecond,
-		cache:         make(map[string]relayCacheEntry),
+		logger:                   logger,
+		opencode:                 opencodeClient,
+		repo:                     repo,
+		telegram:                 telegram,
+		mode:                     resolvedMode,
+		fallback:                 fallback,
+		fallbackDelay:            time.Duration(fallbackDelayMs) * time.Millisecond,
+		attachmentThresholdBytes: attachmentThresholdBytes,
+		streamRepo:               streamRepo,
+		streamQueue:              NewKeyedQueue(),
+		metrics:                  newRelayMetrics(registry),
+		eventBus:                 eventBus,
+		leaseRepo:                leaseRepo,
+		replicaID:                replicaID,
+		leaseTTL:                 leaseTTL,
+		maxTextBytes:             maxTextBytes,
+		cache:                    make(map[string]relayCacheEntry),
+		idleArrivedAt:            make(map[string]time.Time),
+		sessionCtx:               make(map[string]sessionContextEntry),
 	}
 }
 
 func (s *RelayService) Run(ctx context.Context) error {
 	events, errs := s.opencode.StreamEvents(ctx)
+	s.metrics.openStreams.Inc()
+	defer s.metrics.openStreams.Dec()
 	for {
 		select {
 		case <-ctx.Done():
@@ -79,40 +207,179 @@ func (s *RelayService) Run(ctx context.Context) error {
 }
 
 func (s *RelayService) handleEvent(ctx context.Context, event opencode.Event) {
+	if s.activityHook != nil {
+		s.activityHook()
+	}
 	if event.SessionID == "" {
 		return
 	}
+	normalized, truncated, err := validateEvent(event, s.maxTextBytes)
+	if err != nil {
+		s.logger.Warn("dropping malformed opencode event", "type", event.Type, "session_id", event.SessionID, "error", err)
+		s.metrics.eventsDroppedTotal.WithLabelValue("malformed").Inc()
+		return
+	}
+	if truncated {
+		s.logger.Warn("truncated oversized opencode event text", "type", event.Type, "session_id", event.SessionID, "max_bytes", s.maxTextBytes)
+		s.metrics.textTruncatedTotal.Inc()
+	}
+	event = normalized
+
+	s.metrics.eventsTotal.WithLabelValue(event.Type).Inc()
+	s.publishEvent(ctx, event)
 
 	switch event.Type {
 	case "message.updated":
-		s.updateCache(event)
+		if !s.updateCache(event) {
+			s.logger.Debug("dropping stale non-final update after a final message was already cached", "session_id", event.SessionID)
+			s.metrics.eventsDroppedTotal.WithLabelValue("stale_non_final").Inc()
+			return
+		}
+		s.refreshSessionContext(ctx, event.SessionID)
+		if s.currentMode() == domain.RelayModeStream {
+			s.streamUpdate(ctx, event.SessionID, event.Text)
+		}
 	case "session.idle":
-		s.onSessionIdle(ctx, event.SessionID)
+		s.markIdleArrival(event.SessionID)
+		if !s.claimDispatch(ctx, event.SessionID) {
+			// Another replica already holds the dispatch lease for this
+			// session; skip flushing so it isn't double-sent.
+			return
+		}
+		sessionCtx := s.currentSessionContext(ctx, event.SessionID)
+		if s.currentMode() == domain.RelayModeStream {
+			s.streamFinalize(sessionCtx, event.SessionID)
+			s.finishSessionContext(event.SessionID)
+			return
+		}
+		s.onSessionIdle(sessionCtx, event.SessionID)
+		s.finishSessionContext(event.SessionID)
 	}
 }
 
-func (s *RelayService) updateCache(event opencode.Event) {
-	if strings.TrimSpace(event.Text) == "" {
+// refreshSessionContext cancels whatever context is currently tracking
+// sessionID and starts a fresh one derived from parent. Called on every
+// message.updated so a new generation of assistant output always supersedes
+// any fallback timer or opencode fetch still in flight for the previous one.
+func (s *RelayService) refreshSessionContext(parent context.Context, sessionID string) context.Context {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if entry, ok := s.sessionCtx[sessionID]; ok {
+		entry.cancel()
+	}
+	sessionCtx, cancel := context.WithCancel(parent)
+	s.sessionCtx[sessionID] = sessionContextEntry{ctx: sessionCtx, cancel: cancel}
+	return sessionCtx
+}
+
+// currentSessionContext returns the context tracking sessionID, creating one
+// derived from parent if none exists yet (e.g. session.idle fired without a
+// preceding message.updated in this process's lifetime).
+func (s *RelayService) currentSessionContext(parent context.Context, sessionID string) context.Context {
+	s.mu.RLock()
+	entry, ok := s.sessionCtx[sessionID]
+	s.mu.RUnlock()
+	if ok {
+		return entry.ctx
+	}
+	return s.refreshSessionContext(parent, sessionID)
+}
+
+// finishSessionContext cancels and forgets sessionID's context once dispatch
+// has completed, since nothing is in flight for it anymore.
+func (s *RelayService) finishSessionContext(sessionID string) {
+	s.mu.Lock()
+	entry, ok := s.sessionCtx[sessionID]
+	if ok {
+		delete(s.sessionCtx, sessionID)
+	}
+	s.mu.Unlock()
+	if ok {
+		entry.cancel()
+	}
+}
+
+// CancelSession aborts any relay work in flight for sessionID — a pending
+// fallback-delay timer or a blocking fetchFinalText opencode call — so
+// ControlService can call it from /command/session/new and guarantee a stale
+// "final" message from the superseded session never reaches Telegram.
+func (s *RelayService) CancelSession(sessionID string) {
+	s.finishSessionContext(sessionID)
+}
+
+// publishEvent mirrors the opencode event onto the configured EventBus so
+// other replicas (when running clustered behind a real bus backend; see
+// internal/eventbus) observe the same cache state this replica does. It's a
+// no-op without an EventBus configured.
+func (s *RelayService) publishEvent(ctx context.Context, event opencode.Event) {
+	if s.eventBus == nil {
 		return
 	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		s.logger.Error("relay event marshal failed", "session_id", event.SessionID, "error", err)
+		return
+	}
+	if err := s.eventBus.Publish(ctx, event.SessionID, data); err != nil {
+		s.logger.Error("relay event publish failed", "session_id", event.SessionID, "error", err)
+	}
+}
+
+// claimDispatch decides whether this replica may flush sessionID to Telegram
+// for the current session.idle event. Without a LeaseRepository configured
+// (the single-replica default) every replica is implicitly the leader.
+func (s *RelayService) claimDispatch(ctx context.Context, sessionID string) bool {
+	if s.leaseRepo == nil {
+		return true
+	}
+	ok, err := s.leaseRepo.ClaimLease(ctx, leaseKeyPrefix+sessionID, s.replicaID, s.leaseTTL)
+	if err != nil {
+		s.logger.Error("relay lease claim failed", "session_id", sessionID, "error", err)
+		return false
+	}
+	return ok
+}
+
+// markIdleArrival records when session.idle arrived for sessionID so dispatch
+// can report bridge_relay_idle_to_dispatch_seconds once the first send goes
+// out.
+func (s *RelayService) markIdleArrival(sessionID string) {
 	s.mu.Lock()
-	s.cache[event.SessionID] = relayCacheEntry{Text: event.Text, Final: event.Final, Updated: time.Now()}
+	s.idleArrivedAt[sessionID] = time.Now()
 	s.mu.Unlock()
 }
 
+// updateCache stores event's content for sessionID, reporting false without
+// applying the update when a final message has already been cached for it —
+// opencode sometimes delivers a stray non-final update after the final one,
+// and accepting it would overwrite the answer the relay is about to send.
+func (s *RelayService) updateCache(event opencode.Event) bool {
+	if strings.TrimSpace(event.Text) == "" && len(event.Attachments) == 0 {
+		return true
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.cache[event.SessionID]; ok && existing.Final && !event.Final {
+		return false
+	}
+	s.cache[event.SessionID] = relayCacheEntry{Text: event.Text, Final: event.Final, Updated: time.Now(), Attachments: event.Attachments}
+	s.metrics.cachedSessions.Set(float64(len(s.cache)))
+	return true
+}
+
 func (s *RelayService) onSessionIdle(ctx context.Context, sessionID string) {
-	if s.mode == domain.RelayModeLast {
-		sendText := s.cachedText(sessionID)
-		if sendText == "" {
+	if s.currentMode() == domain.RelayModeLast {
+		sendText, attachments := s.cachedContent(sessionID)
+		if sendText == "" && len(attachments) == 0 {
 			sendText = s.fetchFinalText(ctx, sessionID)
 		}
-		s.dispatch(ctx, sessionID, sendText)
+		s.dispatch(ctx, sessionID, sendText, attachments)
 		return
 	}
 
 	entry, ok := s.cachedEntry(sessionID)
-	if ok && entry.Final && strings.TrimSpace(entry.Text) != "" {
-		s.dispatch(ctx, sessionID, entry.Text)
+	if ok && entry.Final && (strings.TrimSpace(entry.Text) != "" || len(entry.Attachments) > 0) {
+		s.dispatch(ctx, sessionID, entry.Text, entry.Attachments)
 		return
 	}
 
@@ -128,38 +395,122 @@ func (s *RelayService) onSessionIdle(ctx context.Context, sessionID string) {
 	case <-timer.C:
 	}
 
-	sendText := s.cachedText(sessionID)
-	if sendText == "" {
+	sendText, attachments := s.cachedContent(sessionID)
+	if sendText == "" && len(attachments) == 0 {
 		sendText = s.fetchFinalText(ctx, sessionID)
 	}
-	s.dispatch(ctx, sessionID, sendText)
+	s.dispatch(ctx, sessionID, sendText, attachments)
 }
 
-func (s *RelayService) dispatch(ctx context.Context, sessionID string, text string) {
-	if strings.TrimSpace(text) == "" {
+func (s *RelayService) dispatch(ctx context.Context, sessionID string, text string, attachments []opencode.Attachment) {
+	if strings.TrimSpace(text) == "" && len(attachments) == 0 {
+		s.metrics.dispatchTotal.WithLabelValue("empty").Inc()
 		return
 	}
 	recipients, err := s.repo.FindRecipientsBySession(ctx, sessionID)
 	if err != nil {
 		s.logger.Error("relay recipients lookup failed", "session_id", sessionID, "error", err)
+		s.metrics.dispatchTotal.WithLabelValue("lookup_error").Inc()
 		return
 	}
+	s.observeIdleToDispatch(sessionID)
 	for _, recipient := range recipients {
-		if err := s.telegram.SendMessage(ctx, recipient.TelegramChatID, text); err != nil {
-			s.logger.Error("relay telegram send failed", "chat_id", recipient.TelegramChatID, "error", err)
-		}
+		s.sendToRecipient(ctx, recipient.TelegramChatID, text, attachments)
 	}
+	s.metrics.dispatchTotal.WithLabelValue("sent").Inc()
 	s.mu.Lock()
 	delete(s.cache, sessionID)
+	delete(s.idleArrivedAt, sessionID)
+	s.metrics.cachedSessions.Set(float64(len(s.cache)))
+	s.mu.Unlock()
+}
+
+// observeIdleToDispatch reports the time between session.idle arriving and
+// the first dispatch that follows it, then clears the bookkeeping so a later
+// idle event for the same session starts a fresh measurement.
+func (s *RelayService) observeIdleToDispatch(sessionID string) {
+	s.mu.Lock()
+	arrivedAt, ok := s.idleArrivedAt[sessionID]
 	s.mu.Unlock()
+	if !ok {
+		return
+	}
+	s.metrics.idleToDispatch.Observe(time.Since(arrivedAt).Seconds())
 }
 
-func (s *RelayService) cachedText(sessionID string) string {
+func (s *RelayService) sendToRecipient(ctx context.Context, chatID int64, text string, attachments []opencode.Attachment) {
+	mediaSender, hasMedia := s.telegram.(ports.TelegramMediaSender)
+
+	if hasMedia {
+		for _, attachment := range attachments {
+			data, err := os.ReadFile(attachment.Path)
+			if err != nil {
+				s.logger.Error("relay attachment read failed", "chat_id", chatID, "path", attachment.Path, "error", err)
+				continue
+			}
+			port := ports.Attachment{Filename: filepath.Base(attachment.Path), MIME: attachment.MIME, Data: data}
+			if isImageMIME(port.MIME, port.Filename) {
+				err = mediaSender.SendPhoto(ctx, chatID, port, text)
+			} else {
+				err = mediaSender.SendDocument(ctx, chatID, port, text)
+			}
+			if err != nil {
+				s.logger.Error("relay attachment send failed", "chat_id", chatID, "path", attachment.Path, "error", err)
+			}
+		}
+		if len(attachments) > 0 {
+			return
+		}
+	}
+
+	if hasMedia && len(text) > s.attachmentThresholdBytes {
+		attachment := ports.Attachment{Filename: "response.md", MIME: "text/markdown", Data: []byte(text)}
+		if err := mediaSender.SendDocument(ctx, chatID, attachment, "Respuesta larga de OpenCode (adjunta)"); err != nil {
+			s.logger.Error("relay oversized text upload failed", "chat_id", chatID, "error", err)
+		}
+		return
+	}
+
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	start := time.Now()
+	err := s.telegram.SendMessage(ctx, chatID, text)
+	s.metrics.sendDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		s.logger.Error("relay telegram send failed", "chat_id", chatID, "error", err)
+		s.metrics.sendErrorsTotal.Inc()
+		s.markRecipientIfUnreachable(ctx, chatID, err)
+	}
+}
+
+// markRecipientIfUnreachable records a recipient as temporarily failing when
+// the Telegram client reports permanent delivery failure, so later relays
+// skip it via FindRecipientsBySession instead of retrying a blocked chat on
+// every session event.
+func (s *RelayService) markRecipientIfUnreachable(ctx context.Context, chatID int64, sendErr error) {
+	if !errors.Is(sendErr, telegram.ErrRecipientUnreachable) {
+		return
+	}
+	if err := s.repo.MarkRecipientFailing(ctx, chatID, time.Now().Add(recipientFailingBackoff)); err != nil {
+		s.logger.Error("relay mark recipient failing failed", "chat_id", chatID, "error", err)
+	}
+}
+
+func isImageMIME(mimeType string, filename string) bool {
+	if strings.HasPrefix(mimeType, "image/") {
+		return true
+	}
+	lower := strings.ToLower(filename)
+	return strings.HasSuffix(lower, ".png") || strings.HasSuffix(lower, ".jpg") || strings.HasSuffix(lower, ".jpeg") || strings.HasSuffix(lower, ".gif")
+}
+
+func (s *RelayService) cachedContent(sessionID string) (string, []opencode.Attachment) {
 	entry, ok := s.cachedEntry(sessionID)
 	if !ok {
-		return ""
+		return "", nil
 	}
-	return entry.Text
+	return entry.Text, entry.Attachments
 }
 
 func (s *RelayService) cachedEntry(sessionID string) (relayCacheEntry, bool) {
@@ -173,7 +524,105 @@ func (s *RelayService) fetchFinalText(ctx context.Context, sessionID string) str
 	text, err := s.opencode.GetLastAssistantMessage(ctx, sessionID)
 	if err != nil {
 		s.logger.Error("fetch final text failed", "session_id", sessionID, "error", err)
+		s.metrics.fallbackFetchTotal.WithLabelValue("error").Inc()
 		return ""
 	}
+	s.metrics.fallbackFetchTotal.WithLabelValue("ok").Inc()
 	return strings.TrimSpace(text)
 }
+
+// streamUpdate pushes the session's latest cumulative text to every linked
+// chat, throttled per chat so edits never exceed Telegram's ~1/sec limit.
+func (s *RelayService) streamUpdate(ctx context.Context, sessionID string, text string) {
+	editor, ok := s.telegram.(ports.TelegramMessageEditor)
+	if !ok || strings.TrimSpace(text) == "" || s.streamRepo == nil {
+		return
+	}
+
+	recipients, err := s.repo.FindRecipientsBySession(ctx, sessionID)
+	if err != nil {
+		s.logger.Error("relay recipients lookup failed", "session_id", sessionID, "error", err)
+		return
+	}
+
+	for _, recipient := range recipients {
+		chatID := recipient.TelegramChatID
+		key := fmt.Sprintf("%s:%d", sessionID, chatID)
+		s.streamQueue.RunThrottledAsync(ctx, key, streamEditInterval, func(ctx context.Context) error {
+			return s.streamEdit(ctx, editor, sessionID, chatID, text)
+		}, func(err error) {
+			s.logger.Error("relay stream edit failed", "session_id", sessionID, "chat_id", chatID, "error", err)
+			s.markRecipientIfUnreachable(ctx, chatID, err)
+		})
+	}
+}
+
+// streamEdit sends the initial placeholder or edits the message already in
+// flight for (sessionID, chatID). When the unsent portion of text would
+// overflow Telegram's per-message limit, it finalizes the current message
+// with a continuation marker and starts editing a new one from the overflow
+// point, persisting the new message_id and offset so a restart mid-stream
+// resumes editing instead of starting over.
+func (s *RelayService) streamEdit(ctx context.Context, editor ports.TelegramMessageEditor, sessionID string, chatID int64, text string) error {
+	messageID, offset, found, err := s.streamRepo.GetStreamMessage(ctx, sessionID, chatID)
+	if err != nil {
+		return fmt.Errorf("load stream message: %w", err)
+	}
+
+	if !found {
+		display := text
+		if len(display) > telegramMessageMaxChars {
+			display = display[:telegramMessageMaxChars]
+		}
+		newID, err := editor.SendMessageReturningID(ctx, chatID, display)
+		if err != nil {
+			return fmt.Errorf("send stream placeholder: %w", err)
+		}
+		return s.streamRepo.UpsertStreamMessage(ctx, sessionID, chatID, newID, 0)
+	}
+
+	if offset > len(text) {
+		return nil
+	}
+	remaining := text[offset:]
+	if len(remaining) <= telegramMessageMaxChars {
+		return editor.EditMessageText(ctx, chatID, messageID, remaining)
+	}
+
+	if err := editor.EditMessageText(ctx, chatID, messageID, remaining[:telegramMessageMaxChars]+streamContinuationSuffix); err != nil {
+		return fmt.Errorf("finalize rotated stream message: %w", err)
+	}
+	newOffset := offset + telegramMessageMaxChars
+	newID, err := editor.SendMessageReturningID(ctx, chatID, streamContinuationPrefix+text[newOffset:])
+	if err != nil {
+		return fmt.Errorf("start rotated stream message: %w", err)
+	}
+	return s.streamRepo.UpsertStreamMessage(ctx, sessionID, chatID, newID, newOffset)
+}
+
+// streamFinalize flushes any text left in the cache one last time, then
+// clears the in-flight message bookkeeping so the next session starts fresh.
+func (s *RelayService) streamFinalize(ctx context.Context, sessionID string) {
+	if text, _ := s.cachedContent(sessionID); strings.TrimSpace(text) != "" {
+		s.streamUpdate(ctx, sessionID, text)
+	}
+
+	if s.streamRepo != nil {
+		recipients, err := s.repo.FindRecipientsBySession(ctx, sessionID)
+		if err != nil {
+			s.logger.Error("relay recipients lookup failed", "session_id", sessionID, "error", err)
+		} else {
+			for _, recipient := range recipients {
+				if err := s.streamRepo.ClearStreamMessage(ctx, sessionID, recipient.TelegramChatID); err != nil {
+					s.logger.Error("relay stream cleanup failed", "session_id", sessionID, "chat_id", recipient.TelegramChatID, "error", err)
+				}
+			}
+		}
+	}
+
+	s.mu.Lock()
+	delete(s.cache, sessionID)
+	delete(s.idleArrivedAt, sessionID)
+	s.metrics.cachedSessions.Set(float64(len(s.cache)))
+	s.mu.Unlock()
+}
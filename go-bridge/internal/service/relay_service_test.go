@@ -2,8 +2,10 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"io"
 	"log/slog"
+	"sync"
 	"testing"
 	"time"
 
@@ -23,6 +25,7 @@ func (r *testRepo) ClearSessionLink(context.Context, int64, int64) error
 func (r *testRepo) FindRecipientsBySession(_ context.Context, sessionID string) ([]ports.ChatRecipient, error) {
 	return r.recipients[sessionID], nil
 }
+func (r *testRepo) MarkRecipientFailing(context.Context, int64, time.Time) error { return nil }
 
 type sentMessage struct {
 	chatID int64
@@ -38,6 +41,79 @@ func (t *testTelegram) SendMessage(_ context.Context, chatID int64, text string)
 	return nil
 }
 
+type testStreamEditor struct {
+	mu     sync.Mutex
+	nextID int64
+	posted []sentMessage
+	edits  []sentMessage
+}
+
+func (t *testStreamEditor) SendMessage(_ context.Context, chatID int64, text string) error {
+	_, err := t.SendMessageReturningID(context.Background(), chatID, text)
+	return err
+}
+
+func (t *testStreamEditor) SendMessageReturningID(_ context.Context, chatID int64, text string) (int64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.nextID++
+	t.posted = append(t.posted, sentMessage{chatID: chatID, text: text})
+	return t.nextID, nil
+}
+
+func (t *testStreamEditor) EditMessageText(_ context.Context, chatID int64, _ int64, text string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.edits = append(t.edits, sentMessage{chatID: chatID, text: text})
+	return nil
+}
+
+type testStreamRepo struct {
+	mu       sync.Mutex
+	messages map[string]struct {
+		messageID int64
+		offset    int
+	}
+}
+
+func newTestStreamRepo() *testStreamRepo {
+	return &testStreamRepo{messages: map[string]struct {
+		messageID int64
+		offset    int
+	}{}}
+}
+
+func streamKey(sessionID string, chatID int64) string {
+	return fmt.Sprintf("%s:%d", sessionID, chatID)
+}
+
+func (r *testStreamRepo) GetStreamMessage(_ context.Context, sessionID string, chatID int64) (int64, int, bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entry, ok := r.messages[streamKey(sessionID, chatID)]
+	if !ok {
+		return 0, 0, false, nil
+	}
+	return entry.messageID, entry.offset, true, nil
+}
+
+func (r *testStreamRepo) UpsertStreamMessage(_ context.Context, sessionID string, chatID int64, messageID int64, offset int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.messages[streamKey(sessionID, chatID)] = struct {
+		messageID int64
+		offset    int
+	}{messageID: messageID, offset: offset}
+	return nil
+}
+
+func (r *testStreamRepo) ClearStreamMessage(_ context.Context, sessionID string, chatID int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.messages, streamKey(sessionID, chatID))
+	return nil
+}
+
 func TestRelayModeLastSendsCachedMessageOnIdle(t *testing.T) {
 	repo := &testRepo{recipients: map[string][]ports.ChatRecipient{"ses_1": {{TelegramChatID: 10, TelegramUserID: 20}}}}
 	telegramClient := &testTelegram{}
@@ -49,6 +125,14 @@ func TestRelayModeLastSendsCachedMessageOnIdle(t *testing.T) {
 		"last",
 		true,
 		1,
+		0,
+		nil,
+		nil,
+		nil,
+		nil,
+		"test-replica",
+		0,
+		0,
 	)
 
 	service.handleEvent(context.Background(), opencode.Event{Type: "message.updated", SessionID: "ses_1", Text: "hello", Final: false})
@@ -73,6 +157,14 @@ func TestRelayModeFinalWithoutFallbackSkipsNonFinal(t *testing.T) {
 		"final",
 		false,
 		1,
+		0,
+		nil,
+		nil,
+		nil,
+		nil,
+		"test-replica",
+		0,
+		0,
 	)
 
 	service.handleEvent(context.Background(), opencode.Event{Type: "message.updated", SessionID: "ses_1", Text: "draft", Final: false})
@@ -94,6 +186,14 @@ func TestRelayModeFinalWithFallbackSendsAfterDelay(t *testing.T) {
 		"final",
 		true,
 		10,
+		0,
+		nil,
+		nil,
+		nil,
+		nil,
+		"test-replica",
+		0,
+		0,
 	)
 
 	service.handleEvent(context.Background(), opencode.Event{Type: "message.updated", SessionID: "ses_1", Text: "draft", Final: false})
@@ -118,6 +218,14 @@ func TestRelayModeFinalSendsFinalImmediately(t *testing.T) {
 		"final",
 		true,
 		5000,
+		0,
+		nil,
+		nil,
+		nil,
+		nil,
+		"test-replica",
+		0,
+		0,
 	)
 
 	start := time.Now()
@@ -132,3 +240,115 @@ func TestRelayModeFinalSendsFinalImmediately(t *testing.T) {
 		t.Fatalf("expected immediate send for final message, took %s", elapsed)
 	}
 }
+
+func TestRelayModeStreamEditsThenFinalizes(t *testing.T) {
+	repo := &testRepo{recipients: map[string][]ports.ChatRecipient{"ses_1": {{TelegramChatID: 10, TelegramUserID: 20}}}}
+	editor := &testStreamEditor{}
+	streamRepo := newTestStreamRepo()
+	service := NewRelayService(
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		nil,
+		repo,
+		editor,
+		"stream",
+		true,
+		1,
+		0,
+		streamRepo,
+		nil,
+		nil,
+		nil,
+		"test-replica",
+		0,
+		0,
+	)
+
+	service.handleEvent(context.Background(), opencode.Event{Type: "message.updated", SessionID: "ses_1", Text: "hello"})
+	waitForCondition(t, func() bool {
+		editor.mu.Lock()
+		defer editor.mu.Unlock()
+		return len(editor.posted) == 1
+	})
+
+	service.handleEvent(context.Background(), opencode.Event{Type: "message.updated", SessionID: "ses_1", Text: "hello world"})
+	waitForCondition(t, func() bool {
+		editor.mu.Lock()
+		defer editor.mu.Unlock()
+		return len(editor.edits) == 1
+	})
+
+	service.handleEvent(context.Background(), opencode.Event{Type: "session.idle", SessionID: "ses_1"})
+	waitForCondition(t, func() bool {
+		_, _, found, _ := streamRepo.GetStreamMessage(context.Background(), "ses_1", 10)
+		return !found
+	})
+
+	editor.mu.Lock()
+	defer editor.mu.Unlock()
+	if len(editor.posted) != 1 {
+		t.Fatalf("expected 1 placeholder message, got %d", len(editor.posted))
+	}
+	if editor.posted[0].text != "hello" {
+		t.Fatalf("expected placeholder text hello, got %q", editor.posted[0].text)
+	}
+	if len(editor.edits) == 0 || editor.edits[len(editor.edits)-1].text != "hello world" {
+		t.Fatalf("expected final edit to contain full text, got %+v", editor.edits)
+	}
+}
+
+func TestCancelSessionAbortsPendingFallback(t *testing.T) {
+	repo := &testRepo{recipients: map[string][]ports.ChatRecipient{"ses_1": {{TelegramChatID: 10, TelegramUserID: 20}}}}
+	telegramClient := &testTelegram{}
+	service := NewRelayService(
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		nil,
+		repo,
+		telegramClient,
+		"final",
+		true,
+		200,
+		0,
+		nil,
+		nil,
+		nil,
+		nil,
+		"test-replica",
+		0,
+		0,
+	)
+
+	service.handleEvent(context.Background(), opencode.Event{Type: "message.updated", SessionID: "ses_1", Text: "draft", Final: false})
+
+	done := make(chan struct{})
+	go func() {
+		service.handleEvent(context.Background(), opencode.Event{Type: "session.idle", SessionID: "ses_1"})
+		close(done)
+	}()
+
+	// Give the fallback timer time to start waiting before cancelling it;
+	// 200ms of fallback delay leaves plenty of room.
+	time.Sleep(20 * time.Millisecond)
+	service.CancelSession("ses_1")
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("handleEvent did not return after CancelSession")
+	}
+
+	if len(telegramClient.sent) != 0 {
+		t.Fatalf("expected cancellation to suppress the fallback send, got %d messages", len(telegramClient.sent))
+	}
+}
+
+func waitForCondition(t *testing.T, check func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if check() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("condition not met within timeout")
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/opencode"
+)
+
+// ansiEscapePattern matches ANSI/VT100 escape sequences (e.g. the color
+// codes opencode's CLI output carries); Telegram has no terminal to render
+// them against, so they're stripped before anything reaches the cache.
+var ansiEscapePattern = regexp.MustCompile("\x1b\\[[0-9;]*[a-zA-Z]")
+
+const truncationMarkerFormat = "…[truncated %d bytes]"
+
+// validateEvent rejects events with a malformed SessionID and otherwise
+// returns a normalized copy: ANSI escapes stripped and Text truncated to
+// maxTextBytes with a visible marker, so a single misbehaving opencode
+// session can't fill the relay cache or blow past Telegram's 4096-char
+// message limit. The second return value reports whether Text was
+// truncated, for metrics.
+func validateEvent(event opencode.Event, maxTextBytes int) (opencode.Event, bool, error) {
+	if strings.TrimSpace(event.SessionID) == "" {
+		return opencode.Event{}, false, errors.New("empty session id")
+	}
+	if strings.ContainsAny(event.SessionID, " \t\n\r") {
+		return opencode.Event{}, false, fmt.Errorf("session id %q contains whitespace", event.SessionID)
+	}
+
+	event.Text = ansiEscapePattern.ReplaceAllString(event.Text, "")
+
+	truncated := false
+	if maxTextBytes > 0 && len(event.Text) > maxTextBytes {
+		droppedBytes := len(event.Text) - maxTextBytes
+		event.Text = truncateUTF8(event.Text, maxTextBytes) + fmt.Sprintf(truncationMarkerFormat, droppedBytes)
+		truncated = true
+	}
+	return event, truncated, nil
+}
+
+// truncateUTF8 cuts s to at most maxBytes, backing off until the cut point
+// lands on a valid UTF-8 boundary so a multi-byte rune isn't split.
+func truncateUTF8(s string, maxBytes int) string {
+	if len(s) <= maxBytes {
+		return s
+	}
+	cut := s[:maxBytes]
+	for len(cut) > 0 && !utf8.ValidString(cut) {
+		cut = cut[:len(cut)-1]
+	}
+	return cut
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/opencode"
+)
+
+func TestValidateEventRejectsWhitespaceSessionID(t *testing.T) {
+	_, _, err := validateEvent(opencode.Event{Type: "message.updated", SessionID: "ses 1", Text: "hi"}, 1024)
+	if err == nil {
+		t.Fatalf("expected error for session id containing whitespace")
+	}
+}
+
+func TestValidateEventRejectsEmptySessionID(t *testing.T) {
+	_, _, err := validateEvent(opencode.Event{Type: "message.updated", SessionID: "  ", Text: "hi"}, 1024)
+	if err == nil {
+		t.Fatalf("expected error for empty session id")
+	}
+}
+
+func TestValidateEventStripsANSIEscapes(t *testing.T) {
+	event, _, err := validateEvent(opencode.Event{SessionID: "ses_1", Text: "\x1b[31mred\x1b[0m text"}, 1024)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if event.Text != "red text" {
+		t.Fatalf("expected ANSI escapes stripped, got %q", event.Text)
+	}
+}
+
+func TestValidateEventTruncatesOversizedText(t *testing.T) {
+	event, truncated, err := validateEvent(opencode.Event{SessionID: "ses_1", Text: strings.Repeat("a", 100)}, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !truncated {
+		t.Fatalf("expected truncated=true")
+	}
+	if !strings.HasPrefix(event.Text, strings.Repeat("a", 10)) {
+		t.Fatalf("expected first 10 bytes preserved, got %q", event.Text)
+	}
+	if !strings.Contains(event.Text, "truncated 90 bytes") {
+		t.Fatalf("expected truncation marker mentioning dropped byte count, got %q", event.Text)
+	}
+}
+
+func TestUpdateCacheDropsStaleNonFinalAfterFinal(t *testing.T) {
+	service := &RelayService{
+		metrics: newRelayMetrics(nil),
+		cache:   make(map[string]relayCacheEntry),
+	}
+
+	if applied := service.updateCache(opencode.Event{SessionID: "ses_1", Text: "final answer", Final: true}); !applied {
+		t.Fatalf("expected the final update to apply")
+	}
+	if applied := service.updateCache(opencode.Event{SessionID: "ses_1", Text: "stray draft", Final: false}); applied {
+		t.Fatalf("expected a non-final update after a final one to be dropped")
+	}
+
+	entry, ok := service.cachedEntry("ses_1")
+	if !ok || entry.Text != "final answer" {
+		t.Fatalf("expected cache to still hold the final answer, got %+v ok=%v", entry, ok)
+	}
+}
@@ -2,31 +2,104 @@ package service
 
 import (
 	"context"
+	"errors"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/metrics"
+	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/ports"
 	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/telegram"
 )
 
+var resolveCacheTotal = metrics.Default.Register(metrics.NewCounterVec(
+	"resolve_username_cache_total", "Username resolutions served from or missing the username_index cache.", "result"))
+
+// UsernameResolver resolves a single @username to a numeric Telegram user
+// ID. telegram.Resolver (Bot API getChat), *telegram.TDLibClient
+// (searchPublicChat) and telegram.DirectoryResolver (a static resolver.json
+// file) all implement it; ResolveService tries its configured backends in
+// order, so a username that misses one falls through to the next before
+// being reported unresolved.
+type UsernameResolver interface {
+	ResolveOne(ctx context.Context, username string) (int64, error)
+}
+
 type ResolveService struct {
-	resolver *telegram.Resolver
-	authz    interface {
+	resolvers []UsernameResolver
+	authz     interface {
 		UpsertAdmin(ctx context.Context, userID int64) error
 		UpsertAllowed(ctx context.Context, userID int64) error
 	}
+	usernameIndex    ports.UsernameIndexRepository
+	cacheTTL         time.Duration
+	negativeCacheTTL time.Duration
+	concurrency      int
 }
 
-func NewResolveService(resolver *telegram.Resolver, authz interface {
-	UpsertAdmin(ctx context.Context, userID int64) error
-	UpsertAllowed(ctx context.Context, userID int64) error
-}) *ResolveService {
-	return &ResolveService{resolver: resolver, authz: authz}
+// NewResolveService builds a ResolveService chaining resolvers in the order
+// given (selected via RESOLVER_BACKEND). usernameIndex may be nil, in which
+// case every call hits the backends directly with no caching (matching this
+// service's behavior before the username_index cache was wired in).
+// cacheTTL/negativeCacheTTL bound how long a cached hit/miss is trusted
+// before ResolveAndPersist revalidates it; concurrency bounds how many
+// backend calls run at once for usernames not served from cache.
+func NewResolveService(
+	resolvers []UsernameResolver,
+	authz interface {
+		UpsertAdmin(ctx context.Context, userID int64) error
+		UpsertAllowed(ctx context.Context, userID int64) error
+	},
+	usernameIndex ports.UsernameIndexRepository,
+	cacheTTL time.Duration,
+	negativeCacheTTL time.Duration,
+	concurrency int,
+) *ResolveService {
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+	return &ResolveService{
+		resolvers:        resolvers,
+		authz:            authz,
+		usernameIndex:    usernameIndex,
+		cacheTTL:         cacheTTL,
+		negativeCacheTTL: negativeCacheTTL,
+		concurrency:      concurrency,
+	}
 }
 
+// ResolveAndPersist resolves usernames to Telegram user IDs, serving
+// unexpired username_index entries from cache and only calling getChat (with
+// bounded concurrency) for the rest, then persists both the authz rows and
+// the cache entries for whatever it newly resolved.
 func (s *ResolveService) ResolveAndPersist(ctx context.Context, usernames []string) telegram.ResolveResult {
 	normalized := normalizeUsernames(usernames)
-	result := s.resolver.ResolveMany(ctx, normalized)
+	result := telegram.ResolveResult{
+		Resolved:   make([]telegram.ResolvedUsername, 0, len(normalized)),
+		Unresolved: make([]telegram.UnresolvedUsername, 0),
+	}
 
-	for _, resolved := range result.Resolved {
+	toFetch := make([]string, 0, len(normalized))
+	for _, username := range normalized {
+		entry, ok, err := s.cachedEntry(ctx, username)
+		if !ok || err != nil {
+			resolveCacheTotal.WithLabelValue("miss").Inc()
+			toFetch = append(toFetch, username)
+			continue
+		}
+		resolveCacheTotal.WithLabelValue("hit").Inc()
+		if entry.Found {
+			result.Resolved = append(result.Resolved, telegram.ResolvedUsername{Username: username, UserID: entry.UserID})
+		} else {
+			result.Unresolved = append(result.Unresolved, telegram.UnresolvedUsername{Username: username, Reason: "cached: username not found"})
+		}
+	}
+
+	fetched := s.resolveConcurrently(ctx, toFetch)
+	result.Resolved = append(result.Resolved, fetched.Resolved...)
+	result.Unresolved = append(result.Unresolved, fetched.Unresolved...)
+
+	for _, resolved := range fetched.Resolved {
 		_ = s.authz.UpsertAdmin(ctx, resolved.UserID)
 		_ = s.authz.UpsertAllowed(ctx, resolved.UserID)
 	}
@@ -34,6 +107,111 @@ func (s *ResolveService) ResolveAndPersist(ctx context.Context, usernames []stri
 	return result
 }
 
+// cachedEntry returns ok=true only when usernameIndex is wired and holds an
+// entry that hasn't yet crossed its TTL (cacheTTL for a positive hit,
+// negativeCacheTTL for a negative one).
+func (s *ResolveService) cachedEntry(ctx context.Context, username string) (ports.UsernameIndexEntry, bool, error) {
+	if s.usernameIndex == nil {
+		return ports.UsernameIndexEntry{}, false, nil
+	}
+	entry, ok, err := s.usernameIndex.GetUsernameIndexEntry(ctx, username)
+	if err != nil || !ok {
+		return ports.UsernameIndexEntry{}, false, err
+	}
+	ttl := s.cacheTTL
+	if !entry.Found {
+		ttl = s.negativeCacheTTL
+	}
+	if ttl > 0 && time.Since(entry.UpdatedAt) > ttl {
+		return ports.UsernameIndexEntry{}, false, nil
+	}
+	return entry, true, nil
+}
+
+type resolveOutcome struct {
+	username string
+	userID   int64
+	err      error
+}
+
+// resolveConcurrently calls getChat for each of usernames across a bounded
+// worker pool, caching every outcome (positive or negative) in
+// username_index as it completes.
+func (s *ResolveService) resolveConcurrently(ctx context.Context, usernames []string) telegram.ResolveResult {
+	result := telegram.ResolveResult{
+		Resolved:   make([]telegram.ResolvedUsername, 0, len(usernames)),
+		Unresolved: make([]telegram.UnresolvedUsername, 0),
+	}
+	if len(usernames) == 0 {
+		return result
+	}
+
+	concurrency := s.concurrency
+	if concurrency > len(usernames) {
+		concurrency = len(usernames)
+	}
+
+	jobs := make(chan string)
+	outcomes := make(chan resolveOutcome, len(usernames))
+
+	var workers sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for username := range jobs {
+				userID, err := s.resolveViaBackends(ctx, username)
+				outcomes <- resolveOutcome{username: username, userID: userID, err: err}
+			}
+		}()
+	}
+	go func() {
+		for _, username := range usernames {
+			jobs <- username
+		}
+		close(jobs)
+	}()
+	go func() {
+		workers.Wait()
+		close(outcomes)
+	}()
+
+	for outcome := range outcomes {
+		if outcome.err != nil {
+			result.Unresolved = append(result.Unresolved, telegram.UnresolvedUsername{Username: outcome.username, Reason: outcome.err.Error()})
+			if s.usernameIndex != nil {
+				var notFound *telegram.NotFoundError
+				if errors.As(outcome.err, &notFound) {
+					_ = s.usernameIndex.MarkUsernameNotFound(ctx, outcome.username)
+				}
+			}
+			continue
+		}
+		result.Resolved = append(result.Resolved, telegram.ResolvedUsername{Username: outcome.username, UserID: outcome.userID})
+		if s.usernameIndex != nil {
+			_ = s.usernameIndex.UpsertUsernameIndex(ctx, outcome.username, outcome.userID)
+		}
+	}
+	return result
+}
+
+// resolveViaBackends tries each configured backend in order, returning the
+// first success. If every backend fails it returns the last one's error, so
+// a username only ends up in ResolveAndPersist's Unresolved list (and
+// therefore only trips runResolve's manual-steps epilogue) once nothing
+// could resolve it.
+func (s *ResolveService) resolveViaBackends(ctx context.Context, username string) (int64, error) {
+	var lastErr error
+	for _, backend := range s.resolvers {
+		userID, err := backend.ResolveOne(ctx, username)
+		if err == nil {
+			return userID, nil
+		}
+		lastErr = err
+	}
+	return 0, lastErr
+}
+
 func normalizeUsernames(usernames []string) []string {
 	out := make([]string, 0, len(usernames))
 	for _, raw := range usernames {
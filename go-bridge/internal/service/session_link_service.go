@@ -2,17 +2,29 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
 	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/ports"
 )
 
+// ErrForbidden is returned by SessionLinkService when the authz enforcer
+// denies a (chat, user) pair the action it requested, so Telegram handlers
+// can reply with a clear "not allowed" message instead of a generic error.
+var ErrForbidden = errors.New("forbidden")
+
 type SessionLinkService struct {
 	repo             ports.SessionLinkRepository
+	authz            ports.AuthzEnforcer
 	defaultSessionID string
 }
 
-func NewSessionLinkService(repo ports.SessionLinkRepository, defaultSessionID string) *SessionLinkService {
-	return &SessionLinkService{repo: repo, defaultSessionID: defaultSessionID}
+// NewSessionLinkService builds a SessionLinkService backed by repo, gating
+// every call through authz. A nil authz allows everything, matching the
+// service's pre-policy behavior, which existing tests and embedders rely
+// on.
+func NewSessionLinkService(repo ports.SessionLinkRepository, authz ports.AuthzEnforcer, defaultSessionID string) *SessionLinkService {
+	return &SessionLinkService{repo: repo, authz: authz, defaultSessionID: defaultSessionID}
 }
 
 func (s *SessionLinkService) DefaultSessionID() string {
@@ -20,6 +32,9 @@ func (s *SessionLinkService) DefaultSessionID() string {
 }
 
 func (s *SessionLinkService) GetSession(ctx context.Context, chatID int64, userID int64) (string, error) {
+	if err := s.checkAuthz(chatID, userID, "read"); err != nil {
+		return "", err
+	}
 	current, ok, err := s.repo.GetSessionLink(ctx, chatID, userID)
 	if err != nil {
 		return "", err
@@ -37,9 +52,46 @@ func (s *SessionLinkService) GetSession(ctx context.Context, chatID int64, userI
 }
 
 func (s *SessionLinkService) SetSession(ctx context.Context, chatID int64, userID int64, sessionID string) error {
+	if err := s.checkAuthz(chatID, userID, "write"); err != nil {
+		return err
+	}
 	return s.repo.UpsertSessionLink(ctx, chatID, userID, sessionID)
 }
 
 func (s *SessionLinkService) ClearSession(ctx context.Context, chatID int64, userID int64) error {
+	if err := s.checkAuthz(chatID, userID, "clear"); err != nil {
+		return err
+	}
 	return s.repo.ClearSessionLink(ctx, chatID, userID)
 }
+
+// checkAuthz asks authz whether the Telegram user behind userID may perform
+// action on the session binding for chatID, so one user in a group chat
+// can't read or overwrite another user's binding by guessing the chat ID.
+// It's layered on top of, not instead of, BridgeService's coarser
+// allow-list check.
+//
+// In shared-session group chats, BridgeService passes sharedSessionUserID
+// (the sentinel 0) rather than a real Telegram user ID, since the binding
+// belongs to the chat as a whole rather than to whichever member triggered
+// it. Policies are written in terms of real users and chats, so that
+// sentinel is checked as subject "chat:<chatID>" instead of "user:0" - a
+// value no admin would ever think to grant.
+func (s *SessionLinkService) checkAuthz(chatID int64, userID int64, action string) error {
+	if s.authz == nil {
+		return nil
+	}
+	subject := fmt.Sprintf("user:%d", userID)
+	if userID == sharedSessionUserID {
+		subject = fmt.Sprintf("chat:%d", chatID)
+	}
+	object := fmt.Sprintf("chat:%d", chatID)
+	allowed, err := s.authz.Enforce(subject, object, action)
+	if err != nil {
+		return err
+	}
+	if !allowed {
+		return ErrForbidden
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"context"
+)
+
+// ListConfigOverrides implements config.ConfigOverridesStore (and
+// ports.ConfigOverridesRepository) for config.Manager's db layer.
+func (s *SQLiteStore) ListConfigOverrides(ctx context.Context) (map[string]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT key, value FROM config_overrides;`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := map[string]string{}
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		out[key] = value
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (s *SQLiteStore) UpsertConfigOverride(ctx context.Context, key string, value string) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO config_overrides (key, value, updated_at)
+		VALUES (?, ?, datetime('now'))
+		ON CONFLICT(key)
+		DO UPDATE SET value = excluded.value, updated_at = datetime('now');
+	`, key, value)
+	return err
+}
+
+func (s *SQLiteStore) DeleteConfigOverride(ctx context.Context, key string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM config_overrides WHERE key = ?;`, key)
+	return err
+}
@@ -0,0 +1,61 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/config"
+)
+
+func TestConfigOverridesUpsertListDelete(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := config.Config{DatabasePath: filepath.Join(tempDir, "bridge.db")}
+	store, err := Open(cfg)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	if err := store.UpsertConfigOverride(ctx, "RELAY_MODE", "stream"); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+	if err := store.UpsertConfigOverride(ctx, "LOG_LEVEL", "debug"); err != nil {
+		t.Fatalf("upsert: %v", err)
+	}
+
+	overrides, err := store.ListConfigOverrides(ctx)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if overrides["RELAY_MODE"] != "stream" || overrides["LOG_LEVEL"] != "debug" {
+		t.Fatalf("unexpected overrides: %v", overrides)
+	}
+
+	if err := store.UpsertConfigOverride(ctx, "RELAY_MODE", "final"); err != nil {
+		t.Fatalf("upsert replacing value: %v", err)
+	}
+	overrides, err = store.ListConfigOverrides(ctx)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if overrides["RELAY_MODE"] != "final" {
+		t.Fatalf("RELAY_MODE = %q, want final after re-upserting", overrides["RELAY_MODE"])
+	}
+
+	if err := store.DeleteConfigOverride(ctx, "LOG_LEVEL"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	overrides, err = store.ListConfigOverrides(ctx)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if _, ok := overrides["LOG_LEVEL"]; ok {
+		t.Fatalf("LOG_LEVEL should have been deleted, got %v", overrides)
+	}
+}
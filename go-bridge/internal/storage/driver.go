@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/config"
+)
+
+const driverSQLite = "sqlite"
+
+// ErrDriverNotVendored is returned by Open when cfg.DatabaseURL names a
+// driver this binary recognizes but doesn't ship, because the project has
+// no dependency manifest to vendor a postgres/mysql driver with yet. It
+// exists so operators get a clear error instead of Open silently falling
+// back to sqlite.
+//
+// Scope note: this package is sqlite-only. Recognizing postgres/mysql
+// schemes here (and the schema_migrations versioning in migrate.go) only
+// reserves the DATABASE_URL shape and migration bookkeeping a real second
+// driver would need; no Store implementation for either exists yet, and
+// none of Store's methods have been exercised against anything but sqlite.
+// Don't read this file as meaning Postgres/MySQL are supported.
+var ErrDriverNotVendored = errors.New("storage: driver not vendored in this build")
+
+// parseDatabaseURL resolves cfg into a driver name and a driver-specific
+// DSN. An empty DATABASE_URL preserves the pre-DATABASE_URL behavior of
+// opening the sqlite file at cfg.DatabasePath. postgres/mysql schemes parse
+// successfully so DATABASE_URL can already be written in its eventual
+// pluggable form, but Open always rejects them with ErrDriverNotVendored -
+// see that error's doc comment for why.
+func parseDatabaseURL(cfg config.Config) (driver string, dsn string, err error) {
+	raw := strings.TrimSpace(cfg.DatabaseURL)
+	if raw == "" {
+		return driverSQLite, cfg.DatabasePath, nil
+	}
+
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return "", "", fmt.Errorf("parse DATABASE_URL: %w", err)
+	}
+
+	switch parsed.Scheme {
+	case "sqlite", "sqlite3":
+		path := parsed.Opaque
+		if path == "" {
+			path = parsed.Path
+		}
+		if path == "" {
+			return "", "", fmt.Errorf("DATABASE_URL %q has no sqlite file path", raw)
+		}
+		return driverSQLite, path, nil
+	case "postgres", "postgresql", "mysql":
+		return parsed.Scheme, raw, nil
+	default:
+		return "", "", fmt.Errorf("DATABASE_URL %q has unsupported scheme %q", raw, parsed.Scheme)
+	}
+}
@@ -15,6 +15,7 @@ type LegacyImportStats struct {
 	Allowed       int `json:"allowed"`
 	SessionLinks  int `json:"sessionLinks"`
 	SessionModels int `json:"sessionModels"`
+	Encrypted     int `json:"encrypted"`
 }
 
 func (s *SQLiteStore) ImportLegacyJSON(ctx context.Context, dataDir string) (LegacyImportStats, error) {
@@ -55,6 +56,9 @@ func (s *SQLiteStore) ImportLegacyJSON(ctx context.Context, dataDir string) (Leg
 			return stats, err
 		}
 		stats.SessionLinks++
+		if s.cipher != nil {
+			stats.Encrypted++
+		}
 	}
 
 	sessionModels, err := readLegacySessionModels(filepath.Join(base, "session-models.json"))
@@ -66,6 +70,9 @@ func (s *SQLiteStore) ImportLegacyJSON(ctx context.Context, dataDir string) (Leg
 			return stats, err
 		}
 		stats.SessionModels++
+		if s.cipher != nil {
+			stats.Encrypted++
+		}
 	}
 
 	return stats, nil
@@ -0,0 +1,137 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//go:embed migrations/sqlite/*.sql
+var sqliteMigrations embed.FS
+
+// migration is one versioned schema step, loaded from a
+// "NNNN_name.up.sql"/"NNNN_name.down.sql" pair under migrations/<driver>.
+// Down scripts are embedded for operator rollback but are never applied by
+// Migrate itself, which only ever moves forward.
+type migration struct {
+	version int
+	name    string
+	up      string
+}
+
+// loadMigrations reads every "*.up.sql" file under dir in fsys and returns
+// them sorted by their leading numeric version, so Migrate can apply
+// whatever is pending in a stable, monotonic order regardless of directory
+// listing order.
+func loadMigrations(fsys embed.FS, dir string) ([]migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("read migrations dir %s: %w", dir, err)
+	}
+
+	migrations := make([]migration, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".up.sql") {
+			continue
+		}
+		version, name, err := parseMigrationFilename(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+		content, err := fsys.ReadFile(dir + "/" + entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("read migration %s: %w", entry.Name(), err)
+		}
+		migrations = append(migrations, migration{version: version, name: name, up: string(content)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].version < migrations[j].version })
+	return migrations, nil
+}
+
+// parseMigrationFilename splits "0001_init.up.sql" into its version (1) and
+// name ("init").
+func parseMigrationFilename(filename string) (int, string, error) {
+	base := strings.TrimSuffix(filename, ".up.sql")
+	parts := strings.SplitN(base, "_", 2)
+	if len(parts) != 2 {
+		return 0, "", fmt.Errorf("migration filename %q must be NNNN_name.up.sql", filename)
+	}
+	version, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, "", fmt.Errorf("migration filename %q must start with a numeric version: %w", filename, err)
+	}
+	return version, parts[1], nil
+}
+
+// applyMigrations runs every migration in fsys/dir whose version hasn't
+// already been recorded in schema_migrations, in ascending order, each in
+// its own transaction so a failure partway through leaves earlier
+// migrations committed. It's safe to call on every startup: a tree with
+// nothing pending is a no-op.
+func applyMigrations(ctx context.Context, db *sql.DB, fsys embed.FS, dir string) error {
+	if _, err := db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at TEXT NOT NULL DEFAULT (datetime('now'))
+		);
+	`); err != nil {
+		return fmt.Errorf("create schema_migrations: %w", err)
+	}
+
+	applied := map[int]bool{}
+	rows, err := db.QueryContext(ctx, `SELECT version FROM schema_migrations;`)
+	if err != nil {
+		return fmt.Errorf("read schema_migrations: %w", err)
+	}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan schema_migrations: %w", err)
+		}
+		applied[version] = true
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return err
+	}
+	rows.Close()
+
+	migrations, err := loadMigrations(fsys, dir)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		if applied[m.version] {
+			continue
+		}
+
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("begin migration %d_%s: %w", m.version, m.name, err)
+		}
+		if _, err := tx.ExecContext(ctx, m.up); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("apply migration %d_%s: %w", m.version, m.name, err)
+		}
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO schema_migrations (version, name) VALUES (?, ?);
+		`, m.version, m.name); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("record migration %d_%s: %w", m.version, m.name, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("commit migration %d_%s: %w", m.version, m.name, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,252 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// QuotaStatus is one user's current usage against their daily request and
+// monthly token quotas, as of the moment it was read.
+type QuotaStatus struct {
+	DailyRequestLimit int
+	RequestCount      int
+	MonthlyTokenLimit int
+	TokenCount        int
+	WindowStart       time.Time
+}
+
+// RateLimiter tracks a per-user daily request quota and monthly token quota
+// backed by the user_quotas table. Only the daily request quota is enforced:
+// Allow denies once request_count reaches daily_request_limit, while the
+// monthly token limit is stored, counted and reported by Status but never
+// causes a denial. Allow should be called by the
+// Telegram handler before dispatching a prompt to OpenCode; RecordTokens
+// afterward once OpenCode reports how many tokens the turn used. Both
+// windows share one row and one window_start: request_count resets whenever
+// window_start falls on an earlier UTC day than now, and token_count resets
+// on top of that whenever the calendar month has also rolled over, so a
+// single sliding anchor drives both limits without two tables.
+type RateLimiter struct {
+	store                *SQLiteStore
+	defaultDailyRequests int
+	defaultMonthlyTokens int
+}
+
+// NewRateLimiter builds a RateLimiter. defaultDailyRequests/defaultMonthlyTokens
+// seed a user's limits the first time they're seen, mirroring
+// DEFAULT_USER_REQUESTS_PER_DAY/DEFAULT_USER_TOKENS_PER_MONTH; either may be
+// 0 to mean "unlimited".
+func NewRateLimiter(store *SQLiteStore, defaultDailyRequests, defaultMonthlyTokens int) *RateLimiter {
+	return &RateLimiter{store: store, defaultDailyRequests: defaultDailyRequests, defaultMonthlyTokens: defaultMonthlyTokens}
+}
+
+// Allow reports whether userID may send another prompt right now, recording
+// the attempt (incrementing request_count) only when it's allowed. It checks
+// the daily request limit only (0 means unlimited); token_count and the
+// monthly token limit play no part in the decision.
+//
+// The limit check and the increment happen as one atomic UPDATE rather than
+// a Go-side read-then-write: two concurrent Allow calls for the same userID
+// would otherwise both read request_count below the limit and both pass,
+// since neither write depended on the other having happened yet. save
+// still handles seeding a new row and resetting expired windows, which
+// aren't security-relevant races - only the limit-gated increment needs to
+// be conditioned on the row's live value.
+func (r *RateLimiter) Allow(ctx context.Context, userID int64) (bool, QuotaStatus, error) {
+	tx, err := r.store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, QuotaStatus{}, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	row, err := r.loadOrSeed(ctx, tx, userID, now)
+	if err != nil {
+		return false, QuotaStatus{}, err
+	}
+	row = resetExpiredWindows(row, now)
+	if err := r.save(ctx, tx, userID, row); err != nil {
+		return false, QuotaStatus{}, err
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE user_quotas
+		SET request_count = request_count + 1
+		WHERE telegram_user_id = ?
+		  AND (daily_request_limit = 0 OR request_count < daily_request_limit);
+	`, userID)
+	if err != nil {
+		return false, QuotaStatus{}, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, QuotaStatus{}, err
+	}
+
+	final, err := r.loadOrSeed(ctx, tx, userID, now)
+	if err != nil {
+		return false, QuotaStatus{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, QuotaStatus{}, err
+	}
+	return affected > 0, quotaStatus(final), nil
+}
+
+// RecordTokens adds tokens to userID's running monthly total, resetting the
+// window first if the month has rolled over since it was last touched.
+func (r *RateLimiter) RecordTokens(ctx context.Context, userID int64, tokens int) error {
+	if tokens <= 0 {
+		return nil
+	}
+	tx, err := r.store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	row, err := r.loadOrSeed(ctx, tx, userID, now)
+	if err != nil {
+		return err
+	}
+	row = resetExpiredWindows(row, now)
+	row.tokenCount += tokens
+	if err := r.save(ctx, tx, userID, row); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SetLimits implements the admin-facing "/quota set <user> <requests/day>
+// <tokens/month>" command.
+func (r *RateLimiter) SetLimits(ctx context.Context, userID int64, dailyRequests int, monthlyTokens int) error {
+	tx, err := r.store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	row, err := r.loadOrSeed(ctx, tx, userID, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+	row.dailyRequestLimit = dailyRequests
+	row.monthlyTokenLimit = monthlyTokens
+	if err := r.save(ctx, tx, userID, row); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Status implements the admin-facing "/quota show <user>" command.
+func (r *RateLimiter) Status(ctx context.Context, userID int64) (QuotaStatus, error) {
+	tx, err := r.store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return QuotaStatus{}, err
+	}
+	defer tx.Rollback()
+
+	row, err := r.loadOrSeed(ctx, tx, userID, time.Now().UTC())
+	if err != nil {
+		return QuotaStatus{}, err
+	}
+	row = resetExpiredWindows(row, time.Now().UTC())
+	if err := tx.Commit(); err != nil {
+		return QuotaStatus{}, err
+	}
+	return quotaStatus(row), nil
+}
+
+type quotaRow struct {
+	windowStart       time.Time
+	requestCount      int
+	tokenCount        int
+	dailyRequestLimit int
+	monthlyTokenLimit int
+}
+
+func quotaStatus(row quotaRow) QuotaStatus {
+	return QuotaStatus{
+		DailyRequestLimit: row.dailyRequestLimit,
+		RequestCount:      row.requestCount,
+		MonthlyTokenLimit: row.monthlyTokenLimit,
+		TokenCount:        row.tokenCount,
+		WindowStart:       row.windowStart,
+	}
+}
+
+// resetExpiredWindows zeroes request_count once window_start falls on an
+// earlier UTC day than now, and additionally zeroes token_count once now is
+// also in a later calendar month, then advances window_start to now.
+func resetExpiredWindows(row quotaRow, now time.Time) quotaRow {
+	sameDay := row.windowStart.Year() == now.Year() && row.windowStart.YearDay() == now.YearDay()
+	if sameDay {
+		return row
+	}
+	sameMonth := row.windowStart.Year() == now.Year() && row.windowStart.Month() == now.Month()
+	row.requestCount = 0
+	if !sameMonth {
+		row.tokenCount = 0
+	}
+	row.windowStart = now
+	return row
+}
+
+func (r *RateLimiter) loadOrSeed(ctx context.Context, tx *sql.Tx, userID int64, now time.Time) (quotaRow, error) {
+	var windowStart string
+	row := quotaRow{}
+	err := tx.QueryRowContext(ctx, `
+		SELECT window_start, request_count, token_count, daily_request_limit, monthly_limit
+		FROM user_quotas WHERE telegram_user_id = ? LIMIT 1;
+	`, userID).Scan(&windowStart, &row.requestCount, &row.tokenCount, &row.dailyRequestLimit, &row.monthlyTokenLimit)
+	if errors.Is(err, sql.ErrNoRows) {
+		return quotaRow{
+			windowStart:       now,
+			dailyRequestLimit: r.defaultDailyRequests,
+			monthlyTokenLimit: r.defaultMonthlyTokens,
+		}, nil
+	}
+	if err != nil {
+		return quotaRow{}, err
+	}
+	parsed, err := time.Parse(time.RFC3339, windowStart)
+	if err != nil {
+		return quotaRow{}, err
+	}
+	row.windowStart = parsed
+	return row, nil
+}
+
+func (r *RateLimiter) save(ctx context.Context, tx *sql.Tx, userID int64, row quotaRow) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO user_quotas (telegram_user_id, window_start, request_count, token_count, daily_request_limit, monthly_limit)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(telegram_user_id) DO UPDATE SET
+			window_start = excluded.window_start,
+			request_count = excluded.request_count,
+			token_count = excluded.token_count,
+			daily_request_limit = excluded.daily_request_limit,
+			monthly_limit = excluded.monthly_limit;
+	`, userID, row.windowStart.Format(time.RFC3339), row.requestCount, row.tokenCount, row.dailyRequestLimit, row.monthlyTokenLimit)
+	return err
+}
+
+// RecordDenial audit-logs a quota rejection so an operator can later review
+// why a user's prompt was refused, without BridgeService depending on
+// *SQLiteStore directly.
+func (r *RateLimiter) RecordDenial(ctx context.Context, userID int64, reason string) error {
+	return r.store.RecordAuditEvent(ctx, userID, "quota_denied", reason)
+}
+
+// RecordAuditEvent appends an entry to the audit_log table, e.g. for a quota
+// rejection an operator may later want to review.
+func (s *SQLiteStore) RecordAuditEvent(ctx context.Context, userID int64, event string, detail string) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO audit_log (telegram_user_id, event, detail, created_at)
+		VALUES (?, ?, ?, datetime('now'));
+	`, userID, event, detail)
+	return err
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/config"
+)
+
+func newTestRateLimiter(t *testing.T) *RateLimiter {
+	t.Helper()
+	tempDir := t.TempDir()
+	cfg := config.Config{DatabasePath: filepath.Join(tempDir, "bridge.db")}
+	store, err := Open(cfg)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	if err := store.Migrate(context.Background()); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return NewRateLimiter(store, 3, 0)
+}
+
+func TestAllowDeniesOnceDailyLimitReached(t *testing.T) {
+	limiter := newTestRateLimiter(t)
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		allow, _, err := limiter.Allow(ctx, 1)
+		if err != nil {
+			t.Fatalf("Allow: %v", err)
+		}
+		if !allow {
+			t.Fatalf("request %d: expected allow", i)
+		}
+	}
+
+	allow, status, err := limiter.Allow(ctx, 1)
+	if err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+	if allow {
+		t.Fatalf("expected the 4th request to be denied")
+	}
+	if status.RequestCount != 3 {
+		t.Fatalf("expected request_count to stay at the limit (3), got %d", status.RequestCount)
+	}
+}
+
+// TestAllowEnforcesLimitUnderConcurrency guards against the read-modify-write
+// race the limit check used to have: concurrent Allow calls for the same
+// user must never let more than dailyRequestLimit requests through, since
+// the increment-and-check is now a single atomic UPDATE instead of a
+// Go-side read then write.
+func TestAllowEnforcesLimitUnderConcurrency(t *testing.T) {
+	limiter := newTestRateLimiter(t)
+	ctx := context.Background()
+
+	const attempts = 20
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	wg.Add(attempts)
+	for i := 0; i < attempts; i++ {
+		go func() {
+			defer wg.Done()
+			allow, _, err := limiter.Allow(ctx, 42)
+			if err != nil {
+				t.Errorf("Allow: %v", err)
+				return
+			}
+			if allow {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != 3 {
+		t.Fatalf("expected exactly 3 of %d concurrent requests to be allowed (daily limit), got %d", attempts, allowed)
+	}
+}
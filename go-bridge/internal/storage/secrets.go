@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/crypto"
+)
+
+// errSecretsNotConfigured is returned by PutSecret/RotateSecrets when
+// SECRETS_KEY isn't set, since there would be no key to encrypt rows under.
+var errSecretsNotConfigured = errors.New("storage: SECRETS_KEY must be set to manage encrypted secrets")
+
+// GetSecret looks up name in encrypted_secrets and decrypts it under the
+// store's secrets cipher. It reports ok=false, not an error, both when the
+// row is absent and when no SECRETS_KEY is configured, so callers (the
+// config loader's env-var fallback in particular) can treat "no secret
+// store" and "secret not set" identically.
+func (s *SQLiteStore) GetSecret(ctx context.Context, name string) (string, bool, error) {
+	if s.secretsCipher == nil {
+		return "", false, nil
+	}
+
+	var stored string
+	err := s.db.QueryRowContext(ctx, `SELECT ciphertext FROM encrypted_secrets WHERE name = ? LIMIT 1;`, name).Scan(&stored)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+
+	value, err := s.secretsCipher.Decrypt(stored)
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+func (s *SQLiteStore) PutSecret(ctx context.Context, name string, value string) error {
+	if s.secretsCipher == nil {
+		return errSecretsNotConfigured
+	}
+
+	ciphertext, err := s.secretsCipher.Encrypt(value)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO encrypted_secrets (name, ciphertext, updated_at)
+		VALUES (?, ?, datetime('now'))
+		ON CONFLICT(name)
+		DO UPDATE SET ciphertext = excluded.ciphertext, updated_at = datetime('now');
+	`, name, ciphertext)
+	return err
+}
+
+// RotateSecrets decrypts every row in encrypted_secrets under the store's
+// current secrets cipher and re-encrypts it under a cipher derived from
+// newMasterKey (reusing the same on-disk salt, so only the key material
+// changes), committing the whole batch in one transaction so a failure
+// partway through never leaves some rows under the old key and others under
+// the new one.
+func (s *SQLiteStore) RotateSecrets(ctx context.Context, newMasterKey string) (int, error) {
+	if s.secretsCipher == nil {
+		return 0, errSecretsNotConfigured
+	}
+	newCipher, err := crypto.NewCipher(newMasterKey, s.secretsSalt)
+	if err != nil {
+		return 0, fmt.Errorf("init new secrets cipher: %w", err)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `SELECT name, ciphertext FROM encrypted_secrets;`)
+	if err != nil {
+		return 0, err
+	}
+	type secretRow struct{ name, ciphertext string }
+	var secrets []secretRow
+	for rows.Next() {
+		var row secretRow
+		if err := rows.Scan(&row.name, &row.ciphertext); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		secrets = append(secrets, row)
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return 0, err
+	}
+	rows.Close()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rotated := 0
+	for _, row := range secrets {
+		plaintext, err := s.secretsCipher.Decrypt(row.ciphertext)
+		if err != nil {
+			return rotated, fmt.Errorf("decrypt secret %q under current key: %w", row.name, err)
+		}
+		reencrypted, err := newCipher.Encrypt(plaintext)
+		if err != nil {
+			return rotated, fmt.Errorf("encrypt secret %q under new key: %w", row.name, err)
+		}
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE encrypted_secrets SET ciphertext = ?, updated_at = datetime('now') WHERE name = ?;
+		`, reencrypted, row.name); err != nil {
+			return rotated, err
+		}
+		rotated++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return rotated, err
+	}
+	s.secretsCipher = newCipher
+	return rotated, nil
+}
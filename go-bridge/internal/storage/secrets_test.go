@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/config"
+)
+
+func TestGetSecretWithoutSecretsKeyReportsNotFound(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := config.Config{DatabasePath: filepath.Join(tempDir, "bridge.db")}
+	store, err := Open(cfg)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	if _, ok, err := store.GetSecret(ctx, secretNameForTest); err != nil || ok {
+		t.Fatalf("expected ok=false err=nil without SECRETS_KEY, got ok=%v err=%v", ok, err)
+	}
+	if err := store.PutSecret(ctx, secretNameForTest, "s3cr3t"); err == nil {
+		t.Fatalf("expected PutSecret to fail without SECRETS_KEY")
+	}
+}
+
+func TestPutSecretThenGetSecretRoundTrips(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := config.Config{DatabasePath: filepath.Join(tempDir, "bridge.db"), SecretsKey: "correct-horse-battery-staple"}
+	store, err := Open(cfg)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+
+	if err := store.PutSecret(ctx, secretNameForTest, "s3cr3t"); err != nil {
+		t.Fatalf("put secret: %v", err)
+	}
+
+	value, ok, err := store.GetSecret(ctx, secretNameForTest)
+	if err != nil {
+		t.Fatalf("get secret: %v", err)
+	}
+	if !ok || value != "s3cr3t" {
+		t.Fatalf("unexpected secret: ok=%v value=%q", ok, value)
+	}
+}
+
+func TestRotateSecretsReencryptsUnderNewKey(t *testing.T) {
+	tempDir := t.TempDir()
+	cfg := config.Config{DatabasePath: filepath.Join(tempDir, "bridge.db"), SecretsKey: "old-key"}
+	store, err := Open(cfg)
+	if err != nil {
+		t.Fatalf("open store: %v", err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	if err := store.Migrate(ctx); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := store.PutSecret(ctx, secretNameForTest, "s3cr3t"); err != nil {
+		t.Fatalf("put secret: %v", err)
+	}
+
+	rotated, err := store.RotateSecrets(ctx, "new-key")
+	if err != nil {
+		t.Fatalf("rotate secrets: %v", err)
+	}
+	if rotated != 1 {
+		t.Fatalf("expected 1 rotated secret, got %d", rotated)
+	}
+
+	value, ok, err := store.GetSecret(ctx, secretNameForTest)
+	if err != nil {
+		t.Fatalf("get secret after rotation: %v", err)
+	}
+	if !ok || value != "s3cr3t" {
+		t.Fatalf("unexpected secret after rotation: ok=%v value=%q", ok, value)
+	}
+}
+
+const secretNameForTest = "test_secret"
@@ -0,0 +1,32 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// GetSessionEndpoint implements ports.SessionEndpointsRepository.
+func (s *SQLiteStore) GetSessionEndpoint(ctx context.Context, sessionID string) (string, bool, error) {
+	var baseURL string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT base_url FROM session_endpoints WHERE session_id = ? LIMIT 1;
+	`, sessionID).Scan(&baseURL)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return baseURL, true, nil
+}
+
+func (s *SQLiteStore) UpsertSessionEndpoint(ctx context.Context, sessionID string, baseURL string) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO session_endpoints (session_id, base_url, updated_at)
+		VALUES (?, ?, datetime('now'))
+		ON CONFLICT(session_id)
+		DO UPDATE SET base_url = excluded.base_url, updated_at = datetime('now');
+	`, sessionID, baseURL)
+	return err
+}
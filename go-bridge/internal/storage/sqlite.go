@@ -2,81 +2,185 @@ package storage
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/config"
+	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/crypto"
 	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/ports"
 	_ "modernc.org/sqlite"
 )
 
+// errListHashedIDs is returned by ListAllowed/ListAdmins when
+// AUTHZ_HASHED_IDS is enabled: the tables only ever store an HMAC digest of
+// each Telegram user ID, which cannot be reversed back into the original ID
+// for listing.
+var errListHashedIDs = errors.New("storage: cannot list user IDs while AUTHZ_HASHED_IDS is enabled")
+
 type SQLiteStore struct {
-	db *sql.DB
+	db          *sql.DB
+	cipher      *crypto.Cipher
+	hashedIDs   bool
+	storageSalt []byte
+
+	secretsCipher *crypto.Cipher
+	secretsSalt   []byte
 }
 
+// Open dispatches on cfg.DatabaseURL's scheme and returns a Store backed by
+// that driver. Only sqlite is built into this binary today: postgres and
+// mysql URLs are recognized (so DATABASE_URL can already be written in its
+// final pluggable form) but rejected with ErrDriverNotVendored until this
+// module gains a dependency manager to vendor those drivers with, and a
+// Store implementation is written and tested against each. Don't add a
+// postgres/mysql scheme here without also shipping that.
 func Open(cfg config.Config) (*SQLiteStore, error) {
-	if err := os.MkdirAll(filepath.Dir(cfg.DatabasePath), 0o755); err != nil {
+	driver, dsn, err := parseDatabaseURL(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if driver != driverSQLite {
+		return nil, fmt.Errorf("%w: %s", ErrDriverNotVendored, driver)
+	}
+	return openSQLite(cfg, dsn)
+}
+
+func openSQLite(cfg config.Config, path string) (*SQLiteStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		return nil, err
 	}
 
-	db, err := sql.Open("sqlite", cfg.DatabasePath)
+	// busy_timeout makes a writer block and retry for up to 10s instead of
+	// failing immediately with SQLITE_BUSY when another connection from this
+	// same process holds the write lock - RateLimiter.Allow's atomic
+	// increment depends on concurrent callers actually serializing rather
+	// than erroring out.
+	db, err := sql.Open("sqlite", path+"?_pragma=busy_timeout(10000)")
 	if err != nil {
 		return nil, err
 	}
 
-	store := &SQLiteStore{db: db}
+	store := &SQLiteStore{db: db, hashedIDs: cfg.AuthzHashedIDs}
 	if err := db.Ping(); err != nil {
 		db.Close()
 		return nil, err
 	}
 
+	if strings.TrimSpace(cfg.StorageEncryptionPassphrase) != "" {
+		salt, err := loadOrCreateSalt(filepath.Join(filepath.Dir(path), "encryption.salt"))
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("load encryption salt: %w", err)
+		}
+		cipher, err := crypto.NewCipher(cfg.StorageEncryptionPassphrase, salt)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("init encryption: %w", err)
+		}
+		store.cipher = cipher
+		store.storageSalt = salt
+	}
+
+	if strings.TrimSpace(cfg.SecretsKey) != "" {
+		salt, err := loadOrCreateSalt(filepath.Join(filepath.Dir(path), "secrets.salt"))
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("load secrets salt: %w", err)
+		}
+		secretsCipher, err := crypto.NewCipher(cfg.SecretsKey, salt)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("init secrets encryption: %w", err)
+		}
+		store.secretsCipher = secretsCipher
+		store.secretsSalt = salt
+	}
+
 	return store, nil
 }
 
+// loadOrCreateSalt reads the scrypt salt persisted at path, generating and
+// writing a new random one on first run. The salt doesn't need to be secret,
+// only stable and random, so it's stored alongside the database rather than
+// derived from the passphrase.
+func loadOrCreateSalt(path string) ([]byte, error) {
+	existing, err := os.ReadFile(path)
+	if err == nil && len(existing) == crypto.SaltSize {
+		return existing, nil
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	salt := make([]byte, crypto.SaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("generate salt: %w", err)
+	}
+	if err := os.WriteFile(path, salt, 0o600); err != nil {
+		return nil, fmt.Errorf("persist salt: %w", err)
+	}
+	return salt, nil
+}
+
 func (s *SQLiteStore) Close() error {
 	return s.db.Close()
 }
 
-func (s *SQLiteStore) Migrate(ctx context.Context) error {
-	queries := []string{
-		`CREATE TABLE IF NOT EXISTS admins (
-			telegram_user_id INTEGER PRIMARY KEY,
-			created_at TEXT NOT NULL DEFAULT (datetime('now'))
-		);`,
-		`CREATE TABLE IF NOT EXISTS allowed_users (
-			telegram_user_id INTEGER PRIMARY KEY,
-			created_at TEXT NOT NULL DEFAULT (datetime('now'))
-		);`,
-		`CREATE TABLE IF NOT EXISTS session_links (
-			telegram_chat_id INTEGER NOT NULL,
-			telegram_user_id INTEGER NOT NULL,
-			opencode_session_id TEXT NOT NULL,
-			updated_at TEXT NOT NULL DEFAULT (datetime('now')),
-			PRIMARY KEY (telegram_chat_id, telegram_user_id)
-		);`,
-		`CREATE TABLE IF NOT EXISTS session_models (
-			opencode_session_id TEXT PRIMARY KEY,
-			model TEXT NOT NULL,
-			updated_at TEXT NOT NULL DEFAULT (datetime('now'))
-		);`,
-		`CREATE TABLE IF NOT EXISTS username_index (
-			username TEXT PRIMARY KEY,
-			telegram_user_id INTEGER NOT NULL,
-			updated_at TEXT NOT NULL DEFAULT (datetime('now'))
-		);`,
-	}
-
-	for _, query := range queries {
-		if _, err := s.db.ExecContext(ctx, query); err != nil {
-			return fmt.Errorf("run migration query: %w", err)
-		}
+// encryptValue seals a sensitive value column (session_id, model) for
+// storage, passing it through unchanged when no encryption passphrase is
+// configured.
+func (s *SQLiteStore) encryptValue(value string) (string, error) {
+	if s.cipher == nil {
+		return value, nil
 	}
+	return s.cipher.Encrypt(value)
+}
 
-	return nil
+// decryptValue reverses encryptValue.
+func (s *SQLiteStore) decryptValue(stored string) (string, error) {
+	if s.cipher == nil {
+		return stored, nil
+	}
+	return s.cipher.Decrypt(stored)
+}
+
+// sessionIDKey returns the value used in indexed/lookup session_id columns:
+// the session ID itself when encryption is off, or a deterministic HMAC
+// digest when on, so equality lookups (FindRecipientsBySession, the
+// session_models primary key) keep working without ever putting the real
+// session ID in a column queried directly.
+func (s *SQLiteStore) sessionIDKey(sessionID string) string {
+	if s.cipher == nil {
+		return sessionID
+	}
+	return s.cipher.Hash(sessionID)
+}
+
+// userIDKey returns the value used for the admins/allowed_users primary key:
+// the raw Telegram user ID normally, or its HMAC digest when
+// AUTHZ_HASHED_IDS is enabled. Note ListAllowed/ListAdmins cannot recover the
+// original ID from the digest, so they report an error in that mode.
+func (s *SQLiteStore) userIDKey(userID int64) any {
+	if s.cipher == nil || !s.hashedIDs {
+		return userID
+	}
+	return s.cipher.HashID(userID)
+}
+
+// Migrate applies every pending versioned migration under
+// migrations/sqlite, recording each in schema_migrations as it goes. It's
+// safe to call on every startup: a database already at the latest version
+// is a no-op.
+func (s *SQLiteStore) Migrate(ctx context.Context) error {
+	return applyMigrations(ctx, s.db, sqliteMigrations, "migrations/sqlite")
 }
 
 func (s *SQLiteStore) SeedFromConfig(ctx context.Context, adminIDs []int64, allowedIDs []int64) error {
@@ -98,7 +202,7 @@ func (s *SQLiteStore) UpsertAdmin(ctx context.Context, userID int64) error {
 		INSERT INTO admins (telegram_user_id, created_at)
 		VALUES (?, datetime('now'))
 		ON CONFLICT(telegram_user_id) DO NOTHING;
-	`, userID)
+	`, s.userIDKey(userID))
 	return err
 }
 
@@ -107,7 +211,7 @@ func (s *SQLiteStore) UpsertAllowed(ctx context.Context, userID int64) error {
 		INSERT INTO allowed_users (telegram_user_id, created_at)
 		VALUES (?, datetime('now'))
 		ON CONFLICT(telegram_user_id) DO NOTHING;
-	`, userID)
+	`, s.userIDKey(userID))
 	return err
 }
 
@@ -122,7 +226,7 @@ func (s *SQLiteStore) IsAllowed(ctx context.Context, userID int64) (bool, error)
 		)
 		WHERE telegram_user_id = ?
 		LIMIT 1;
-	`, userID).Scan(&found)
+	`, s.userIDKey(userID)).Scan(&found)
 	if err == nil {
 		return true, nil
 	}
@@ -136,7 +240,7 @@ func (s *SQLiteStore) IsAdmin(ctx context.Context, userID int64) (bool, error) {
 	var found int
 	err := s.db.QueryRowContext(ctx, `
 		SELECT 1 FROM admins WHERE telegram_user_id = ? LIMIT 1;
-	`, userID).Scan(&found)
+	`, s.userIDKey(userID)).Scan(&found)
 	if err == nil {
 		return true, nil
 	}
@@ -147,11 +251,17 @@ func (s *SQLiteStore) IsAdmin(ctx context.Context, userID int64) (bool, error) {
 }
 
 func (s *SQLiteStore) RemoveAllowed(ctx context.Context, userID int64) error {
-	_, err := s.db.ExecContext(ctx, `DELETE FROM allowed_users WHERE telegram_user_id = ?;`, userID)
+	_, err := s.db.ExecContext(ctx, `DELETE FROM allowed_users WHERE telegram_user_id = ?;`, s.userIDKey(userID))
 	return err
 }
 
+// ListAllowed returns every allowed user ID. It errors under
+// AUTHZ_HASHED_IDS=true: the table only holds the HMAC digest of each ID by
+// design, so the original IDs can't be recovered to list.
 func (s *SQLiteStore) ListAllowed(ctx context.Context) ([]int64, error) {
+	if s.hashedIDs && s.cipher != nil {
+		return nil, errListHashedIDs
+	}
 	rows, err := s.db.QueryContext(ctx, `SELECT telegram_user_id FROM allowed_users;`)
 	if err != nil {
 		return nil, err
@@ -173,7 +283,11 @@ func (s *SQLiteStore) ListAllowed(ctx context.Context) ([]int64, error) {
 	return out, nil
 }
 
+// ListAdmins has the same AUTHZ_HASHED_IDS limitation as ListAllowed.
 func (s *SQLiteStore) ListAdmins(ctx context.Context) ([]int64, error) {
+	if s.hashedIDs && s.cipher != nil {
+		return nil, errListHashedIDs
+	}
 	rows, err := s.db.QueryContext(ctx, `SELECT telegram_user_id FROM admins;`)
 	if err != nil {
 		return nil, err
@@ -196,31 +310,40 @@ func (s *SQLiteStore) ListAdmins(ctx context.Context) ([]int64, error) {
 }
 
 func (s *SQLiteStore) GetSessionLink(ctx context.Context, chatID int64, userID int64) (string, bool, error) {
-	var sessionID string
+	var stored string
 	err := s.db.QueryRowContext(ctx, `
 		SELECT opencode_session_id
 		FROM session_links
 		WHERE telegram_chat_id = ? AND telegram_user_id = ?
 		LIMIT 1;
-	`, chatID, userID).Scan(&sessionID)
-	if err == nil {
-		return sessionID, true, nil
-	}
+	`, chatID, userID).Scan(&stored)
 	if errors.Is(err, sql.ErrNoRows) {
 		return "", false, nil
 	}
-	return "", false, err
+	if err != nil {
+		return "", false, err
+	}
+	sessionID, err := s.decryptValue(stored)
+	if err != nil {
+		return "", false, err
+	}
+	return sessionID, true, nil
 }
 
 func (s *SQLiteStore) UpsertSessionLink(ctx context.Context, chatID int64, userID int64, sessionID string) error {
-	_, err := s.db.ExecContext(ctx, `
-		INSERT INTO session_links (telegram_chat_id, telegram_user_id, opencode_session_id, updated_at)
-		VALUES (?, ?, ?, datetime('now'))
+	stored, err := s.encryptValue(sessionID)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO session_links (telegram_chat_id, telegram_user_id, opencode_session_id, session_id_hash, updated_at)
+		VALUES (?, ?, ?, ?, datetime('now'))
 		ON CONFLICT(telegram_chat_id, telegram_user_id)
 		DO UPDATE SET
 			opencode_session_id = excluded.opencode_session_id,
+			session_id_hash = excluded.session_id_hash,
 			updated_at = datetime('now');
-	`, chatID, userID, sessionID)
+	`, chatID, userID, stored, s.sessionIDKey(sessionID))
 	return err
 }
 
@@ -233,10 +356,12 @@ func (s *SQLiteStore) ClearSessionLink(ctx context.Context, chatID int64, userID
 
 func (s *SQLiteStore) FindRecipientsBySession(ctx context.Context, sessionID string) ([]ports.ChatRecipient, error) {
 	rows, err := s.db.QueryContext(ctx, `
-		SELECT telegram_chat_id, telegram_user_id
-		FROM session_links
-		WHERE opencode_session_id = ?;
-	`, sessionID)
+		SELECT sl.telegram_chat_id, sl.telegram_user_id
+		FROM session_links sl
+		LEFT JOIN recipient_failures rf ON rf.telegram_chat_id = sl.telegram_chat_id
+		WHERE sl.session_id_hash = ?
+			AND (rf.failing_until IS NULL OR rf.failing_until <= ?);
+	`, s.sessionIDKey(sessionID), time.Now().UTC().Format(time.RFC3339))
 	if err != nil {
 		return nil, err
 	}
@@ -256,35 +381,549 @@ func (s *SQLiteStore) FindRecipientsBySession(ctx context.Context, sessionID str
 	return out, nil
 }
 
+func (s *SQLiteStore) MarkRecipientFailing(ctx context.Context, chatID int64, until time.Time) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO recipient_failures (telegram_chat_id, failing_until)
+		VALUES (?, ?)
+		ON CONFLICT(telegram_chat_id)
+		DO UPDATE SET failing_until = excluded.failing_until;
+	`, chatID, until.UTC().Format(time.RFC3339))
+	return err
+}
+
+// ClaimLease implements ports.LeaseRepository on top of the same SQLite
+// database used for session links, so a clustered RelayService (see
+// internal/eventbus) doesn't need a separate coordination store. A lease is
+// granted when no row exists for key, the existing row already expired, or
+// holder already owns it (a renewal).
+func (s *SQLiteStore) ClaimLease(ctx context.Context, key string, holder string, ttl time.Duration) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now().UTC()
+	var existingHolder, existingExpiry string
+	err = tx.QueryRowContext(ctx, `SELECT holder, expires_at FROM leases WHERE lease_key = ?;`, key).Scan(&existingHolder, &existingExpiry)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		// no current holder
+	case err != nil:
+		return false, err
+	default:
+		expiresAt, parseErr := time.Parse(time.RFC3339, existingExpiry)
+		if parseErr == nil && existingHolder != holder && now.Before(expiresAt) {
+			return false, nil
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO leases (lease_key, holder, expires_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(lease_key) DO UPDATE SET holder = excluded.holder, expires_at = excluded.expires_at;
+	`, key, holder, now.Add(ttl).Format(time.RFC3339)); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
 func (s *SQLiteStore) GetSessionModel(ctx context.Context, sessionID string) (string, bool, error) {
-	var model string
+	var stored string
 	err := s.db.QueryRowContext(ctx, `
 		SELECT model FROM session_models WHERE opencode_session_id = ? LIMIT 1;
-	`, sessionID).Scan(&model)
-	if err == nil {
-		return model, true, nil
-	}
+	`, s.sessionIDKey(sessionID)).Scan(&stored)
 	if errors.Is(err, sql.ErrNoRows) {
 		return "", false, nil
 	}
-	return "", false, err
+	if err != nil {
+		return "", false, err
+	}
+	model, err := s.decryptValue(stored)
+	if err != nil {
+		return "", false, err
+	}
+	return model, true, nil
 }
 
 func (s *SQLiteStore) UpsertSessionModel(ctx context.Context, sessionID string, model string) error {
-	_, err := s.db.ExecContext(ctx, `
+	storedModel, err := s.encryptValue(model)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, `
 		INSERT INTO session_models (opencode_session_id, model, updated_at)
 		VALUES (?, ?, datetime('now'))
 		ON CONFLICT(opencode_session_id)
 		DO UPDATE SET
 			model = excluded.model,
 			updated_at = datetime('now');
-	`, sessionID, model)
+	`, s.sessionIDKey(sessionID), storedModel)
 	return err
 }
 
 func (s *SQLiteStore) ClearSessionModel(ctx context.Context, sessionID string) error {
 	_, err := s.db.ExecContext(ctx, `
 		DELETE FROM session_models WHERE opencode_session_id = ?;
-	`, sessionID)
+	`, s.sessionIDKey(sessionID))
+	return err
+}
+
+// MigrateEncrypt re-encrypts existing session_links and session_models rows
+// in place under the store's configured cipher, backfilling session_id_hash
+// as it goes. It distinguishes already-encrypted rows from legacy plaintext
+// ones by attempting a decrypt: a value that fails to decrypt under the
+// current key is assumed to be plaintext left over from before encryption
+// was enabled (or an import that bypassed encryptValue).
+func (s *SQLiteStore) MigrateEncrypt(ctx context.Context) (int, error) {
+	if s.cipher == nil {
+		return 0, errors.New("storage: STORAGE_ENCRYPTION_PASSPHRASE must be set to run migrate-encrypt")
+	}
+
+	rewritten := 0
+
+	linkRows, err := s.db.QueryContext(ctx, `SELECT telegram_chat_id, telegram_user_id, opencode_session_id FROM session_links;`)
+	if err != nil {
+		return rewritten, err
+	}
+	type linkRow struct {
+		chatID, userID int64
+		sessionID      string
+	}
+	var links []linkRow
+	for linkRows.Next() {
+		var row linkRow
+		if err := linkRows.Scan(&row.chatID, &row.userID, &row.sessionID); err != nil {
+			linkRows.Close()
+			return rewritten, err
+		}
+		links = append(links, row)
+	}
+	if err := linkRows.Err(); err != nil {
+		linkRows.Close()
+		return rewritten, err
+	}
+	linkRows.Close()
+
+	for _, row := range links {
+		if _, err := s.cipher.Decrypt(row.sessionID); err == nil {
+			continue
+		}
+		stored, err := s.cipher.Encrypt(row.sessionID)
+		if err != nil {
+			return rewritten, err
+		}
+		if _, err := s.db.ExecContext(ctx, `
+			UPDATE session_links SET opencode_session_id = ?, session_id_hash = ?
+			WHERE telegram_chat_id = ? AND telegram_user_id = ?;
+		`, stored, s.cipher.Hash(row.sessionID), row.chatID, row.userID); err != nil {
+			return rewritten, err
+		}
+		rewritten++
+	}
+
+	modelRows, err := s.db.QueryContext(ctx, `SELECT opencode_session_id, model FROM session_models;`)
+	if err != nil {
+		return rewritten, err
+	}
+	type modelRow struct {
+		sessionID, model string
+	}
+	var models []modelRow
+	for modelRows.Next() {
+		var row modelRow
+		if err := modelRows.Scan(&row.sessionID, &row.model); err != nil {
+			modelRows.Close()
+			return rewritten, err
+		}
+		models = append(models, row)
+	}
+	if err := modelRows.Err(); err != nil {
+		modelRows.Close()
+		return rewritten, err
+	}
+	modelRows.Close()
+
+	for _, row := range models {
+		// A row whose model is still plaintext was written with no cipher,
+		// so its PK is the raw session ID (see sessionIDKey); rewrite the PK
+		// to the hashed lookup key along with the model.
+		if _, err := s.cipher.Decrypt(row.model); err == nil {
+			continue
+		}
+		storedModel, err := s.cipher.Encrypt(row.model)
+		if err != nil {
+			return rewritten, err
+		}
+		if _, err := s.db.ExecContext(ctx, `
+			UPDATE session_models SET opencode_session_id = ?, model = ? WHERE opencode_session_id = ?;
+		`, s.cipher.Hash(row.sessionID), storedModel, row.sessionID); err != nil {
+			return rewritten, err
+		}
+		rewritten++
+	}
+
+	return rewritten, nil
+}
+
+// RotateStorageEncryption re-encrypts session_links, session_models and
+// prompt_history under a cipher derived from newPassphrase (reusing the
+// same on-disk encryption.salt, so only the key material changes),
+// mirroring RotateSecrets for the STORAGE_ENCRYPTION_PASSPHRASE-backed
+// columns. session_links/prompt_history carry the session ID encrypted, so
+// they decrypt and re-encrypt directly; session_models is keyed by
+// session_id_hash, which is itself derived from the passphrase, so its rows
+// are matched up via the session_links rotated in the same pass rather than
+// decrypted in isolation. A session_models row with no corresponding
+// session_links entry can't be re-keyed (its plaintext session ID isn't
+// recoverable from the row alone); it's left untouched, and once s.cipher
+// switches to newCipher its session_id_hash - computed under the old key -
+// will never again match a lookup hash computed under the new one, making
+// the row permanently unreachable. The returned skipped count is how the
+// caller finds out that happened, so it isn't a silent data loss.
+func (s *SQLiteStore) RotateStorageEncryption(ctx context.Context, newPassphrase string) (rotated int, skipped int, err error) {
+	if s.cipher == nil {
+		return 0, 0, errors.New("storage: STORAGE_ENCRYPTION_PASSPHRASE must be set to run rekey")
+	}
+	newCipher, err := crypto.NewCipher(newPassphrase, s.storageSalt)
+	if err != nil {
+		return 0, 0, fmt.Errorf("init new storage cipher: %w", err)
+	}
+
+	linkRows, err := s.db.QueryContext(ctx, `SELECT telegram_chat_id, telegram_user_id, opencode_session_id FROM session_links;`)
+	if err != nil {
+		return 0, 0, err
+	}
+	type linkRow struct {
+		chatID, userID int64
+		sessionID      string
+	}
+	var links []linkRow
+	for linkRows.Next() {
+		var row linkRow
+		if err := linkRows.Scan(&row.chatID, &row.userID, &row.sessionID); err != nil {
+			linkRows.Close()
+			return 0, 0, err
+		}
+		links = append(links, row)
+	}
+	if err := linkRows.Err(); err != nil {
+		linkRows.Close()
+		return 0, 0, err
+	}
+	linkRows.Close()
+
+	promptRows, err := s.db.QueryContext(ctx, `SELECT id, opencode_session_id, prompt_text FROM prompt_history;`)
+	if err != nil {
+		return 0, 0, err
+	}
+	type promptRow struct {
+		id                    int64
+		sessionID, promptText string
+	}
+	var prompts []promptRow
+	for promptRows.Next() {
+		var row promptRow
+		if err := promptRows.Scan(&row.id, &row.sessionID, &row.promptText); err != nil {
+			promptRows.Close()
+			return 0, 0, err
+		}
+		prompts = append(prompts, row)
+	}
+	if err := promptRows.Err(); err != nil {
+		promptRows.Close()
+		return 0, 0, err
+	}
+	promptRows.Close()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	oldHashToNewHash := make(map[string]string, len(links))
+	for _, row := range links {
+		plaintext, err := s.cipher.Decrypt(row.sessionID)
+		if err != nil {
+			return rotated, skipped, fmt.Errorf("decrypt session_links session id under current key: %w", err)
+		}
+		reencrypted, err := newCipher.Encrypt(plaintext)
+		if err != nil {
+			return rotated, skipped, fmt.Errorf("encrypt session_links session id under new key: %w", err)
+		}
+		oldHash := s.cipher.Hash(plaintext)
+		newHash := newCipher.Hash(plaintext)
+		oldHashToNewHash[oldHash] = newHash
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE session_links SET opencode_session_id = ?, session_id_hash = ?
+			WHERE telegram_chat_id = ? AND telegram_user_id = ?;
+		`, reencrypted, newHash, row.chatID, row.userID); err != nil {
+			return rotated, skipped, err
+		}
+		rotated++
+	}
+
+	modelRows, err := tx.QueryContext(ctx, `SELECT opencode_session_id, model FROM session_models;`)
+	if err != nil {
+		return rotated, skipped, err
+	}
+	type modelRow struct {
+		oldHash, model string
+	}
+	var models []modelRow
+	for modelRows.Next() {
+		var row modelRow
+		if err := modelRows.Scan(&row.oldHash, &row.model); err != nil {
+			modelRows.Close()
+			return rotated, skipped, err
+		}
+		models = append(models, row)
+	}
+	if err := modelRows.Err(); err != nil {
+		modelRows.Close()
+		return rotated, skipped, err
+	}
+	modelRows.Close()
+
+	for _, row := range models {
+		newHash, ok := oldHashToNewHash[row.oldHash]
+		if !ok {
+			skipped++
+			continue
+		}
+		plaintextModel, err := s.cipher.Decrypt(row.model)
+		if err != nil {
+			return rotated, skipped, fmt.Errorf("decrypt session_models model under current key: %w", err)
+		}
+		reencrypted, err := newCipher.Encrypt(plaintextModel)
+		if err != nil {
+			return rotated, skipped, fmt.Errorf("encrypt session_models model under new key: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE session_models SET opencode_session_id = ?, model = ? WHERE opencode_session_id = ?;
+		`, newHash, reencrypted, row.oldHash); err != nil {
+			return rotated, skipped, err
+		}
+		rotated++
+	}
+
+	for _, row := range prompts {
+		plaintextSessionID, err := s.cipher.Decrypt(row.sessionID)
+		if err != nil {
+			return rotated, skipped, fmt.Errorf("decrypt prompt_history session id under current key: %w", err)
+		}
+		plaintextPrompt, err := s.cipher.Decrypt(row.promptText)
+		if err != nil {
+			return rotated, skipped, fmt.Errorf("decrypt prompt_history prompt text under current key: %w", err)
+		}
+		reencryptedSessionID, err := newCipher.Encrypt(plaintextSessionID)
+		if err != nil {
+			return rotated, skipped, fmt.Errorf("encrypt prompt_history session id under new key: %w", err)
+		}
+		reencryptedPrompt, err := newCipher.Encrypt(plaintextPrompt)
+		if err != nil {
+			return rotated, skipped, fmt.Errorf("encrypt prompt_history prompt text under new key: %w", err)
+		}
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE prompt_history SET opencode_session_id = ?, prompt_text = ? WHERE id = ?;
+		`, reencryptedSessionID, reencryptedPrompt, row.id); err != nil {
+			return rotated, skipped, err
+		}
+		rotated++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return rotated, skipped, err
+	}
+	s.cipher = newCipher
+	return rotated, skipped, nil
+}
+
+func (s *SQLiteStore) GetChatMode(ctx context.Context, chatID int64) (string, bool, error) {
+	var mode string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT mode FROM chat_settings WHERE telegram_chat_id = ? LIMIT 1;
+	`, chatID).Scan(&mode)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return mode, true, nil
+}
+
+func (s *SQLiteStore) UpsertChatMode(ctx context.Context, chatID int64, mode string) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO chat_settings (telegram_chat_id, mode, updated_at)
+		VALUES (?, ?, datetime('now'))
+		ON CONFLICT(telegram_chat_id)
+		DO UPDATE SET mode = excluded.mode, updated_at = datetime('now');
+	`, chatID, mode)
+	return err
+}
+
+func (s *SQLiteStore) GetUserPref(ctx context.Context, userID int64, key string) (string, bool, error) {
+	var value string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT value FROM user_prefs WHERE telegram_user_id = ? AND key = ? LIMIT 1;
+	`, userID, key).Scan(&value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return value, true, nil
+}
+
+func (s *SQLiteStore) UpsertUserPref(ctx context.Context, userID int64, key string, value string) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO user_prefs (telegram_user_id, key, value, updated_at)
+		VALUES (?, ?, ?, datetime('now'))
+		ON CONFLICT(telegram_user_id, key)
+		DO UPDATE SET value = excluded.value, updated_at = datetime('now');
+	`, userID, key, value)
+	return err
+}
+
+func (s *SQLiteStore) ListUserPrefs(ctx context.Context, userID int64) (map[string]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT key, value FROM user_prefs WHERE telegram_user_id = ?;`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make(map[string]string)
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, err
+		}
+		out[key] = value
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// maxPromptHistoryPerUser bounds how many prompt_history rows RecordPrompt
+// keeps per (chat, user): only the last turn is ever read back, so there's
+// no need to retain more than a small buffer for debugging/future reuse.
+const maxPromptHistoryPerUser = 5
+
+func (s *SQLiteStore) RecordPrompt(ctx context.Context, chatID int64, userID int64, entry ports.PromptHistoryEntry) error {
+	storedSessionID, err := s.encryptValue(entry.SessionID)
+	if err != nil {
+		return err
+	}
+	storedPrompt, err := s.encryptValue(entry.PromptText)
+	if err != nil {
+		return err
+	}
+	assistantIDs := make([]string, len(entry.AssistantMsgIDs))
+	for i, id := range entry.AssistantMsgIDs {
+		assistantIDs[i] = strconv.FormatInt(id, 10)
+	}
+
+	if _, err := s.db.ExecContext(ctx, `
+		INSERT INTO prompt_history (telegram_chat_id, telegram_user_id, opencode_session_id, opencode_message_id, prompt_text, assistant_message_ids, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, datetime('now'));
+	`, chatID, userID, storedSessionID, entry.MessageID, storedPrompt, strings.Join(assistantIDs, ",")); err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		DELETE FROM prompt_history
+		WHERE telegram_chat_id = ? AND telegram_user_id = ? AND id NOT IN (
+			SELECT id FROM prompt_history
+			WHERE telegram_chat_id = ? AND telegram_user_id = ?
+			ORDER BY id DESC LIMIT ?
+		);
+	`, chatID, userID, chatID, userID, maxPromptHistoryPerUser)
+	return err
+}
+
+func (s *SQLiteStore) GetLastPrompt(ctx context.Context, chatID int64, userID int64) (ports.PromptHistoryEntry, bool, error) {
+	var storedSessionID, messageID, storedPrompt, assistantIDsRaw string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT opencode_session_id, opencode_message_id, prompt_text, assistant_message_ids
+		FROM prompt_history
+		WHERE telegram_chat_id = ? AND telegram_user_id = ?
+		ORDER BY id DESC LIMIT 1;
+	`, chatID, userID).Scan(&storedSessionID, &messageID, &storedPrompt, &assistantIDsRaw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ports.PromptHistoryEntry{}, false, nil
+	}
+	if err != nil {
+		return ports.PromptHistoryEntry{}, false, err
+	}
+
+	sessionID, err := s.decryptValue(storedSessionID)
+	if err != nil {
+		return ports.PromptHistoryEntry{}, false, err
+	}
+	promptText, err := s.decryptValue(storedPrompt)
+	if err != nil {
+		return ports.PromptHistoryEntry{}, false, err
+	}
+
+	var assistantMsgIDs []int64
+	if assistantIDsRaw != "" {
+		for _, part := range strings.Split(assistantIDsRaw, ",") {
+			id, parseErr := strconv.ParseInt(part, 10, 64)
+			if parseErr != nil {
+				continue
+			}
+			assistantMsgIDs = append(assistantMsgIDs, id)
+		}
+	}
+
+	return ports.PromptHistoryEntry{
+		SessionID:       sessionID,
+		MessageID:       messageID,
+		PromptText:      promptText,
+		AssistantMsgIDs: assistantMsgIDs,
+	}, true, nil
+}
+
+func (s *SQLiteStore) GetStreamMessage(ctx context.Context, sessionID string, chatID int64) (int64, int, bool, error) {
+	var messageID int64
+	var textOffset int
+	err := s.db.QueryRowContext(ctx, `
+		SELECT telegram_message_id, text_offset
+		FROM stream_messages
+		WHERE opencode_session_id = ? AND telegram_chat_id = ?
+		LIMIT 1;
+	`, sessionID, chatID).Scan(&messageID, &textOffset)
+	if err == nil {
+		return messageID, textOffset, true, nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, 0, false, nil
+	}
+	return 0, 0, false, err
+}
+
+func (s *SQLiteStore) UpsertStreamMessage(ctx context.Context, sessionID string, chatID int64, messageID int64, textOffset int) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO stream_messages (opencode_session_id, telegram_chat_id, telegram_message_id, text_offset, updated_at)
+		VALUES (?, ?, ?, ?, datetime('now'))
+		ON CONFLICT(opencode_session_id, telegram_chat_id)
+		DO UPDATE SET
+			telegram_message_id = excluded.telegram_message_id,
+			text_offset = excluded.text_offset,
+			updated_at = datetime('now');
+	`, sessionID, chatID, messageID, textOffset)
+	return err
+}
+
+func (s *SQLiteStore) ClearStreamMessage(ctx context.Context, sessionID string, chatID int64) error {
+	_, err := s.db.ExecContext(ctx, `
+		DELETE FROM stream_messages WHERE opencode_session_id = ? AND telegram_chat_id = ?;
+	`, sessionID, chatID)
 	return err
 }
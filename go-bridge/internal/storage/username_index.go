@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/ports"
+)
+
+// LookupUsername implements ports.UsernameIndexRepository's fast path: it
+// doesn't consider TTLs itself, only whether the cached entry is a positive
+// one at all. Callers that need TTL-aware revalidation should use
+// GetUsernameIndexEntry instead.
+func (s *SQLiteStore) LookupUsername(ctx context.Context, username string) (int64, bool, error) {
+	entry, ok, err := s.GetUsernameIndexEntry(ctx, username)
+	if err != nil || !ok || !entry.Found {
+		return 0, false, err
+	}
+	return entry.UserID, true, nil
+}
+
+func (s *SQLiteStore) GetUsernameIndexEntry(ctx context.Context, username string) (ports.UsernameIndexEntry, bool, error) {
+	var userID int64
+	var found bool
+	var updatedAt string
+	err := s.db.QueryRowContext(ctx, `
+		SELECT telegram_user_id, found, updated_at FROM username_index WHERE username = ? LIMIT 1;
+	`, username).Scan(&userID, &found, &updatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ports.UsernameIndexEntry{}, false, nil
+	}
+	if err != nil {
+		return ports.UsernameIndexEntry{}, false, err
+	}
+	parsed, err := time.Parse(time.RFC3339, updatedAt)
+	if err != nil {
+		return ports.UsernameIndexEntry{}, false, err
+	}
+	return ports.UsernameIndexEntry{Username: username, UserID: userID, Found: found, UpdatedAt: parsed}, true, nil
+}
+
+func (s *SQLiteStore) UpsertUsernameIndex(ctx context.Context, username string, userID int64) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO username_index (username, telegram_user_id, found, updated_at)
+		VALUES (?, ?, 1, ?)
+		ON CONFLICT(username)
+		DO UPDATE SET telegram_user_id = excluded.telegram_user_id, found = 1, updated_at = excluded.updated_at;
+	`, username, userID, time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+// MarkUsernameNotFound negative-caches a username Telegram reported as not
+// resolving to any chat, so ResolveService doesn't re-call getChat for it on
+// every onboarding run within UsernameNegativeCacheTTLSeconds.
+func (s *SQLiteStore) MarkUsernameNotFound(ctx context.Context, username string) error {
+	_, err := s.db.ExecContext(ctx, `
+		INSERT INTO username_index (username, telegram_user_id, found, updated_at)
+		VALUES (?, 0, 0, ?)
+		ON CONFLICT(username)
+		DO UPDATE SET telegram_user_id = 0, found = 0, updated_at = excluded.updated_at;
+	`, username, time.Now().UTC().Format(time.RFC3339))
+	return err
+}
+
+func (s *SQLiteStore) ListUsernameIndex(ctx context.Context) ([]ports.UsernameIndexEntry, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT username, telegram_user_id, found, updated_at FROM username_index ORDER BY username;
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	out := make([]ports.UsernameIndexEntry, 0)
+	for rows.Next() {
+		var entry ports.UsernameIndexEntry
+		var updatedAt string
+		if err := rows.Scan(&entry.Username, &entry.UserID, &entry.Found, &updatedAt); err != nil {
+			return nil, err
+		}
+		parsed, err := time.Parse(time.RFC3339, updatedAt)
+		if err != nil {
+			return nil, err
+		}
+		entry.UpdatedAt = parsed
+		out = append(out, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
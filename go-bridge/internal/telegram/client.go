@@ -3,6 +3,7 @@ package telegram
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -13,6 +14,7 @@ import (
 type Resolver struct {
 	botToken string
 	client   *http.Client
+	limiter  *tokenBucket
 }
 
 type ResolvedUsername struct {
@@ -34,20 +36,28 @@ func NewResolver(botToken string, timeout time.Duration) *Resolver {
 	return &Resolver{
 		botToken: botToken,
 		client:   &http.Client{Timeout: timeout},
+		// Telegram documents a 30 requests/sec global cap across all Bot API
+		// methods; getChat counts against it just like sendMessage does.
+		limiter: newTokenBucket(30, 30),
 	}
 }
 
+// CheckConnectivity reports whether botToken is valid and Telegram's Bot API
+// is reachable. Any error is scrubbed of botToken first: a transport failure
+// surfaces as a *url.Error whose Error() includes the full request URL
+// (botToken and all), and that string would otherwise leak the token into
+// logs or an unauthenticated /health or /doctor response.
 func CheckConnectivity(ctx context.Context, botToken string, timeout time.Duration) error {
 	client := &http.Client{Timeout: timeout}
 	endpoint := fmt.Sprintf("https://api.telegram.org/bot%s/getMe", botToken)
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
 	if err != nil {
-		return err
+		return redactToken(err, botToken)
 	}
 
 	res, err := client.Do(req)
 	if err != nil {
-		return err
+		return redactToken(err, botToken)
 	}
 	defer res.Body.Close()
 
@@ -57,6 +67,16 @@ func CheckConnectivity(ctx context.Context, botToken string, timeout time.Durati
 	return nil
 }
 
+// redactToken replaces every occurrence of botToken in err's message with
+// "***", so a failed request's error never carries the token back out
+// through logs or an API response.
+func redactToken(err error, botToken string) error {
+	if err == nil || botToken == "" {
+		return err
+	}
+	return errors.New(strings.ReplaceAll(err.Error(), botToken, "***"))
+}
+
 func (r *Resolver) ResolveMany(ctx context.Context, usernames []string) ResolveResult {
 	result := ResolveResult{
 		Resolved:   make([]ResolvedUsername, 0, len(usernames)),
@@ -64,7 +84,7 @@ func (r *Resolver) ResolveMany(ctx context.Context, usernames []string) ResolveR
 	}
 
 	for _, username := range usernames {
-		userID, err := r.resolveSingle(ctx, username)
+		userID, err := r.ResolveOne(ctx, username)
 		if err != nil {
 			result.Unresolved = append(result.Unresolved, UnresolvedUsername{Username: username, Reason: err.Error()})
 			continue
@@ -75,6 +95,70 @@ func (r *Resolver) ResolveMany(ctx context.Context, usernames []string) ResolveR
 	return result
 }
 
+// resolveRetryMaxAttempts bounds how many getChat calls ResolveOne makes for
+// one username, the first call included, when Telegram keeps returning 429
+// with a retry_after, before giving up.
+const resolveRetryMaxAttempts = 3
+
+// ResolveOne resolves a single username, retrying with the exponential
+// backoff Telegram's 429 retry_after suggests (doubling it on each further
+// 429) until it has made resolveRetryMaxAttempts calls in all. Callers that want a typed
+// not-found signal (e.g. to negative-cache it) can use errors.As with
+// *NotFoundError.
+func (r *Resolver) ResolveOne(ctx context.Context, username string) (int64, error) {
+	wait := time.Duration(0)
+	var lastErr error
+	for attempt := 0; attempt < resolveRetryMaxAttempts; attempt++ {
+		if wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return 0, ctx.Err()
+			case <-timer.C:
+			}
+		}
+
+		userID, err := r.resolveSingle(ctx, username)
+		if err == nil {
+			return userID, nil
+		}
+		lastErr = err
+
+		var rateLimited *RateLimitedError
+		if !errors.As(err, &rateLimited) {
+			return 0, err
+		}
+		if wait == 0 {
+			wait = rateLimited.RetryAfter
+		} else {
+			wait *= 2
+		}
+	}
+	return 0, lastErr
+}
+
+// NotFoundError is returned by resolveSingle when Telegram reports a
+// username doesn't resolve to any chat, distinguishing it from a transient
+// failure so ResolveService can negative-cache it.
+type NotFoundError struct {
+	Username string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("username not found: %s", e.Username)
+}
+
+// RateLimitedError is returned by resolveSingle when Telegram responds 429,
+// carrying the retry_after duration it suggested.
+type RateLimitedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitedError) Error() string {
+	return fmt.Sprintf("rate limited, retry after %s", e.RetryAfter)
+}
+
 func (r *Resolver) resolveSingle(ctx context.Context, username string) (int64, error) {
 	cleanUsername := strings.TrimSpace(username)
 	if cleanUsername == "" {
@@ -84,6 +168,10 @@ func (r *Resolver) resolveSingle(ctx context.Context, username string) (int64, e
 		cleanUsername = "@" + cleanUsername
 	}
 
+	if err := r.limiter.wait(ctx); err != nil {
+		return 0, err
+	}
+
 	endpoint := fmt.Sprintf(
 		"https://api.telegram.org/bot%s/getChat?chat_id=%s",
 		r.botToken,
@@ -102,11 +190,15 @@ func (r *Resolver) resolveSingle(ctx context.Context, username string) (int64, e
 	defer res.Body.Close()
 
 	var payload struct {
-		OK          bool `json:"ok"`
-		Description string
+		OK          bool   `json:"ok"`
+		ErrorCode   int    `json:"error_code"`
+		Description string `json:"description"`
 		Result      struct {
 			ID int64 `json:"id"`
 		} `json:"result"`
+		Parameters struct {
+			RetryAfter int `json:"retry_after"`
+		} `json:"parameters"`
 	}
 
 	if err := json.NewDecoder(res.Body).Decode(&payload); err != nil {
@@ -117,6 +209,16 @@ func (r *Resolver) resolveSingle(ctx context.Context, username string) (int64, e
 		if reason == "" {
 			reason = fmt.Sprintf("telegram status %d", res.StatusCode)
 		}
+		if payload.ErrorCode == http.StatusTooManyRequests || res.StatusCode == http.StatusTooManyRequests {
+			retryAfter := time.Duration(payload.Parameters.RetryAfter) * time.Second
+			if retryAfter <= 0 {
+				retryAfter = time.Second
+			}
+			return 0, &RateLimitedError{RetryAfter: retryAfter}
+		}
+		if strings.Contains(strings.ToUpper(reason), "USERNAME_NOT_OCCUPIED") || strings.Contains(strings.ToLower(reason), "chat not found") {
+			return 0, &NotFoundError{Username: cleanUsername}
+		}
 		return 0, fmt.Errorf("%s", reason)
 	}
 	if payload.Result.ID == 0 {
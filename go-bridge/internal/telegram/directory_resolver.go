@@ -0,0 +1,56 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DirectoryResolver implements service.UsernameResolver from a static JSON
+// file (username -> numeric user ID), for air-gapped deployments that can't
+// reach Telegram's servers to resolve usernames at all. The file is loaded
+// once at startup; operators re-run `bridge resolve`/restart the bridge to
+// pick up edits.
+type DirectoryResolver struct {
+	entries map[string]int64
+}
+
+// NewDirectoryResolver loads path, a JSON object mapping "@username" (or
+// "username", normalized the same way) to its numeric Telegram user ID.
+func NewDirectoryResolver(path string) (*DirectoryResolver, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("directory resolver: read %s: %w", path, err)
+	}
+
+	var parsed map[string]int64
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		return nil, fmt.Errorf("directory resolver: parse %s: %w", path, err)
+	}
+
+	entries := make(map[string]int64, len(parsed))
+	for username, userID := range parsed {
+		entries[normalizeDirectoryUsername(username)] = userID
+	}
+	return &DirectoryResolver{entries: entries}, nil
+}
+
+// ResolveOne implements service.UsernameResolver.
+func (d *DirectoryResolver) ResolveOne(ctx context.Context, username string) (int64, error) {
+	key := normalizeDirectoryUsername(username)
+	userID, ok := d.entries[key]
+	if !ok {
+		return 0, &NotFoundError{Username: key}
+	}
+	return userID, nil
+}
+
+func normalizeDirectoryUsername(username string) string {
+	trimmed := strings.ToLower(strings.TrimSpace(username))
+	if !strings.HasPrefix(trimmed, "@") {
+		trimmed = "@" + trimmed
+	}
+	return trimmed
+}
@@ -0,0 +1,12 @@
+package telegram
+
+import "github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/metrics"
+
+var (
+	sendTotal = metrics.Default.Register(metrics.NewCounterVec(
+		"telegram_send_total", "Telegram API calls by method and outcome.", "result"))
+	rateLimitedTotal = metrics.Default.Register(metrics.NewCounterVec(
+		"telegram_rate_limited_total", "Telegram API calls that received a 429 response.", "method"))
+	retryTotal = metrics.Default.Register(metrics.NewCounterVec(
+		"telegram_retry_total", "Telegram API calls retried after a transient failure.", "method"))
+)
@@ -0,0 +1,90 @@
+package telegram
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// tokenBucket is a minimal token-bucket limiter refilling at rate tokens/sec
+// up to burst tokens, used instead of pulling in golang.org/x/time/rate.
+type tokenBucket struct {
+	mu       sync.Mutex
+	tokens   float64
+	rate     float64
+	burst    float64
+	lastFill time.Time
+}
+
+func newTokenBucket(ratePerSecond float64, burst float64) *tokenBucket {
+	return &tokenBucket{tokens: burst, rate: ratePerSecond, burst: burst, lastFill: time.Now()}
+}
+
+// wait blocks until a token is available or ctx is cancelled.
+func (b *tokenBucket) wait(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		now := time.Now()
+		b.tokens = minFloat(b.burst, b.tokens+now.Sub(b.lastFill).Seconds()*b.rate)
+		b.lastFill = now
+		if b.tokens >= 1 {
+			b.tokens--
+			b.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		b.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func minFloat(a, b float64) float64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// chatLimiter enforces Telegram's documented send limits: roughly 1
+// message/sec per chat and 30 messages/sec globally across all chats.
+type chatLimiter struct {
+	global *tokenBucket
+
+	mu      sync.Mutex
+	perChat map[int64]*tokenBucket
+}
+
+func newChatLimiter() *chatLimiter {
+	return &chatLimiter{global: newTokenBucket(30, 30), perChat: map[int64]*tokenBucket{}}
+}
+
+// wait blocks until a send to chatID is allowed under both the global and
+// per-chat budgets. chatID of 0 means "not chat-scoped" (e.g. getUpdates) and
+// only consumes the global budget.
+func (l *chatLimiter) wait(ctx context.Context, chatID int64) error {
+	if err := l.global.wait(ctx); err != nil {
+		return err
+	}
+	if chatID == 0 {
+		return nil
+	}
+	return l.chatBucket(chatID).wait(ctx)
+}
+
+func (l *chatLimiter) chatBucket(chatID int64) *tokenBucket {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	b, ok := l.perChat[chatID]
+	if !ok {
+		b = newTokenBucket(1, 1)
+		l.perChat[chatID] = b
+	}
+	return b
+}
@@ -4,19 +4,48 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
 	"net/url"
 	"path"
 	"strings"
 	"time"
+
+	"github.com/hanamilabs/opencode-telegram-bridge/go-bridge/internal/ports"
+)
+
+const (
+	maxTelegramRetries = 5
+	maxRetryAfterWait  = 30 * time.Second
 )
 
+// ErrRecipientUnreachable indicates Telegram permanently rejected delivery to
+// a chat (e.g. the user blocked the bot or left the chat), as opposed to a
+// transient failure worth retrying. Callers can use errors.Is to detect it
+// and stop sending to that recipient instead of retrying forever.
+var ErrRecipientUnreachable = errors.New("telegram: recipient unreachable")
+
+// apiError captures a Telegram Bot API error response, including the
+// retry_after hint Telegram sends with 429s.
+type apiError struct {
+	Code        int
+	Description string
+	RetryAfter  int
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("telegram: %s (code %d)", e.Description, e.Code)
+}
+
 type API struct {
 	botToken        string
 	client          *http.Client
 	pollingInterval time.Duration
+	limiter         *chatLimiter
 }
 
 type Update struct {
@@ -33,10 +62,49 @@ type CallbackQuery struct {
 }
 
 type Message struct {
-	MessageID int64  `json:"message_id"`
-	From      User   `json:"from"`
-	Chat      Chat   `json:"chat"`
-	Text      string `json:"text"`
+	MessageID      int64           `json:"message_id"`
+	From           User            `json:"from"`
+	Chat           Chat            `json:"chat"`
+	Text           string          `json:"text"`
+	Caption        string          `json:"caption,omitempty"`
+	Photo          []PhotoSize     `json:"photo,omitempty"`
+	Document       *Document       `json:"document,omitempty"`
+	Voice          *Voice          `json:"voice,omitempty"`
+	Entities       []MessageEntity `json:"entities,omitempty"`
+	ReplyToMessage *Message        `json:"reply_to_message,omitempty"`
+}
+
+// MessageEntity marks a span of Text (or Caption) with special meaning, e.g.
+// an @mention or a /command. Offset and Length are counted in UTF-16 code
+// units per the Bot API, not bytes - callers that slice Text by these fields
+// need to convert via unicode/utf16 rather than indexing the string directly.
+type MessageEntity struct {
+	Type   string `json:"type"`
+	Offset int    `json:"offset"`
+	Length int    `json:"length"`
+}
+
+// PhotoSize is one resolution of an inbound photo; Telegram sends several
+// per message in ascending size order, so callers wanting the original use
+// the last entry.
+type PhotoSize struct {
+	FileID   string `json:"file_id"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	FileSize int    `json:"file_size"`
+}
+
+type Document struct {
+	FileID   string `json:"file_id"`
+	FileName string `json:"file_name"`
+	MIME     string `json:"mime_type"`
+	FileSize int    `json:"file_size"`
+}
+
+type Voice struct {
+	FileID   string `json:"file_id"`
+	MIME     string `json:"mime_type"`
+	FileSize int    `json:"file_size"`
 }
 
 type User struct {
@@ -45,7 +113,8 @@ type User struct {
 }
 
 type Chat struct {
-	ID int64 `json:"id"`
+	ID   int64  `json:"id"`
+	Type string `json:"type"`
 }
 
 type InlineKeyboardButton struct {
@@ -61,15 +130,206 @@ func NewAPI(botToken string, timeout time.Duration, pollingInterval time.Duratio
 	if pollingInterval <= 0 {
 		pollingInterval = 2 * time.Second
 	}
-	return &API{botToken: botToken, client: &http.Client{Timeout: timeout}, pollingInterval: pollingInterval}
+	return &API{botToken: botToken, client: &http.Client{Timeout: timeout}, pollingInterval: pollingInterval, limiter: newChatLimiter()}
 }
 
 func (a *API) SendMessage(ctx context.Context, chatID int64, text string) error {
 	body := map[string]any{"chat_id": chatID, "text": text}
-	_, err := a.request(ctx, http.MethodPost, "sendMessage", body)
+	_, err := a.requestWithRetry(ctx, http.MethodPost, "sendMessage", body, chatID)
+	return err
+}
+
+// SendMessageWithEntities posts text with a Telegram parse_mode so opencode
+// output (diffs, code fences) renders formatted instead of as a literal blob.
+func (a *API) SendMessageWithEntities(ctx context.Context, chatID int64, text string, parseMode string) error {
+	body := map[string]any{"chat_id": chatID, "text": text, "parse_mode": parseMode}
+	_, err := a.requestWithRetry(ctx, http.MethodPost, "sendMessage", body, chatID)
+	return err
+}
+
+// SendMessageReturningID posts text and returns the Telegram message_id so
+// callers can edit it in place later, used by the relay's stream mode.
+func (a *API) SendMessageReturningID(ctx context.Context, chatID int64, text string) (int64, error) {
+	body := map[string]any{"chat_id": chatID, "text": text}
+	raw, err := a.requestWithRetry(ctx, http.MethodPost, "sendMessage", body, chatID)
+	if err != nil {
+		return 0, err
+	}
+	var payload struct {
+		OK     bool `json:"ok"`
+		Result struct {
+			MessageID int64 `json:"message_id"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return 0, err
+	}
+	if !payload.OK {
+		return 0, fmt.Errorf("telegram sendMessage failed")
+	}
+	return payload.Result.MessageID, nil
+}
+
+// EditMessageText updates a previously sent message in place.
+func (a *API) EditMessageText(ctx context.Context, chatID int64, messageID int64, text string) error {
+	body := map[string]any{"chat_id": chatID, "message_id": messageID, "text": text}
+	_, err := a.requestWithRetry(ctx, http.MethodPost, "editMessageText", body, chatID)
 	return err
 }
 
+// DeleteMessage removes a previously sent message, used by /retry and /edit
+// to clear the previous turn's placeholder before re-running the prompt.
+func (a *API) DeleteMessage(ctx context.Context, chatID int64, messageID int64) error {
+	body := map[string]any{"chat_id": chatID, "message_id": messageID}
+	_, err := a.requestWithRetry(ctx, http.MethodPost, "deleteMessage", body, chatID)
+	return err
+}
+
+// GetMe returns the bot's own user profile, including the username needed to
+// recognize @mentions in group chats. Callers should cache the result rather
+// than calling this on every update.
+func (a *API) GetMe(ctx context.Context) (User, error) {
+	raw, err := a.request(ctx, http.MethodGet, "getMe", nil)
+	if err != nil {
+		return User{}, err
+	}
+	var payload struct {
+		OK     bool `json:"ok"`
+		Result User `json:"result"`
+	}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return User{}, err
+	}
+	if !payload.OK {
+		return User{}, fmt.Errorf("telegram getMe failed")
+	}
+	return payload.Result, nil
+}
+
+// getFile resolves fileID to the path Telegram stored it under, as returned
+// by the getFile Bot API method.
+func (a *API) getFile(ctx context.Context, fileID string) (string, error) {
+	raw, err := a.request(ctx, http.MethodGet, "getFile?file_id="+url.QueryEscape(fileID), nil)
+	if err != nil {
+		return "", err
+	}
+	var payload struct {
+		OK     bool `json:"ok"`
+		Result struct {
+			FilePath string `json:"file_path"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return "", err
+	}
+	if !payload.OK || payload.Result.FilePath == "" {
+		return "", fmt.Errorf("telegram getFile failed")
+	}
+	return payload.Result.FilePath, nil
+}
+
+// DownloadFile resolves fileID via getFile and downloads its bytes, used to
+// pull inbound photo/document/voice attachments before handing them to
+// OpenCode.
+func (a *API) DownloadFile(ctx context.Context, fileID string) ([]byte, error) {
+	filePath, err := a.getFile(ctx, fileID)
+	if err != nil {
+		return nil, fmt.Errorf("resolve telegram file path: %w", err)
+	}
+
+	fileURL := fmt.Sprintf("https://api.telegram.org/file/bot%s/%s", a.botToken, filePath)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := a.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	raw, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= 400 {
+		msg := strings.TrimSpace(string(raw))
+		if msg == "" {
+			msg = fmt.Sprintf("telegram file download status %d", res.StatusCode)
+		}
+		return nil, fmt.Errorf("%s", msg)
+	}
+	return raw, nil
+}
+
+// SendDocument uploads attachment as a Telegram document via multipart/form-data.
+func (a *API) SendDocument(ctx context.Context, chatID int64, attachment ports.Attachment, caption string) error {
+	return a.sendMultipartFile(ctx, "sendDocument", "document", chatID, attachment, caption)
+}
+
+// SendPhoto uploads attachment as a Telegram photo via multipart/form-data.
+func (a *API) SendPhoto(ctx context.Context, chatID int64, attachment ports.Attachment, caption string) error {
+	return a.sendMultipartFile(ctx, "sendPhoto", "photo", chatID, attachment, caption)
+}
+
+func (a *API) sendMultipartFile(ctx context.Context, endpoint string, fileField string, chatID int64, attachment ports.Attachment, caption string) error {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	if err := writer.WriteField("chat_id", fmt.Sprintf("%d", chatID)); err != nil {
+		return err
+	}
+	if caption != "" {
+		if err := writer.WriteField("caption", caption); err != nil {
+			return err
+		}
+	}
+
+	mimeType := attachment.MIME
+	if strings.TrimSpace(mimeType) == "" {
+		mimeType = http.DetectContentType(attachment.Data)
+	}
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name=%q; filename=%q`, fileField, attachment.Filename))
+	header.Set("Content-Type", mimeType)
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(attachment.Data); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("https://api.telegram.org/bot%s/%s", a.botToken, endpoint), &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Accept", "application/json")
+
+	res, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	raw, readErr := io.ReadAll(res.Body)
+	if readErr != nil {
+		return readErr
+	}
+	if res.StatusCode >= 400 {
+		msg := strings.TrimSpace(string(raw))
+		if msg == "" {
+			msg = fmt.Sprintf("telegram status %d", res.StatusCode)
+		}
+		return fmt.Errorf("%s", msg)
+	}
+	return nil
+}
+
 func (a *API) SendChatAction(ctx context.Context, chatID int64, action string) error {
 	body := map[string]any{"chat_id": chatID, "action": action}
 	_, err := a.request(ctx, http.MethodPost, "sendChatAction", body)
@@ -88,13 +348,44 @@ func (a *API) SendMessageWithInlineKeyboard(ctx context.Context, chatID int64, t
 	return err
 }
 
+// SendMessageWithInlineKeyboardReturningID behaves like
+// SendMessageWithInlineKeyboard but also returns the sent message_id, used to
+// attach a persistent action button (e.g. a cancel button) to a message the
+// caller will keep editing in place as a reply streams in.
+func (a *API) SendMessageWithInlineKeyboardReturningID(ctx context.Context, chatID int64, text string, rows [][]InlineKeyboardButton) (int64, error) {
+	body := map[string]any{
+		"chat_id": chatID,
+		"text":    text,
+		"reply_markup": InlineKeyboardMarkup{
+			InlineKeyboard: rows,
+		},
+	}
+	raw, err := a.request(ctx, http.MethodPost, "sendMessage", body)
+	if err != nil {
+		return 0, err
+	}
+	var payload struct {
+		OK     bool `json:"ok"`
+		Result struct {
+			MessageID int64 `json:"message_id"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return 0, err
+	}
+	if !payload.OK {
+		return 0, fmt.Errorf("telegram sendMessage failed")
+	}
+	return payload.Result.MessageID, nil
+}
+
 func (a *API) AnswerCallbackQuery(ctx context.Context, callbackQueryID string, text string) error {
 	body := map[string]any{
 		"callback_query_id": callbackQueryID,
 		"text":              text,
 		"show_alert":        false,
 	}
-	_, err := a.request(ctx, http.MethodPost, "answerCallbackQuery", body)
+	_, err := a.requestWithRetry(ctx, http.MethodPost, "answerCallbackQuery", body, 0)
 	return err
 }
 
@@ -138,8 +429,11 @@ func (a *API) PollUpdates(ctx context.Context, handler func(context.Context, Upd
 	}
 }
 
-func (a *API) SetupWebhook(ctx context.Context, webhookURL string) error {
+func (a *API) SetupWebhook(ctx context.Context, webhookURL string, secretToken string) error {
 	body := map[string]any{"url": webhookURL}
+	if secretToken != "" {
+		body["secret_token"] = secretToken
+	}
 	_, err := a.request(ctx, http.MethodPost, "setWebhook", body)
 	return err
 }
@@ -149,6 +443,38 @@ func (a *API) DeleteWebhook(ctx context.Context) error {
 	return err
 }
 
+// WebhookInfo mirrors the subset of Telegram's getWebhookInfo response the
+// doctor preflight cares about: whether Telegram can actually reach the
+// configured URL, and the error it last hit trying.
+type WebhookInfo struct {
+	URL                  string `json:"url"`
+	PendingUpdateCount   int    `json:"pending_update_count"`
+	LastErrorDate        int64  `json:"last_error_date"`
+	LastErrorMessage     string `json:"last_error_message"`
+	HasCustomCertificate bool   `json:"has_custom_certificate"`
+}
+
+// GetWebhookInfo reports what Telegram believes about the bridge's webhook
+// registration, most importantly whether it's seen a delivery failure since
+// the last successful one.
+func (a *API) GetWebhookInfo(ctx context.Context) (WebhookInfo, error) {
+	raw, err := a.request(ctx, http.MethodGet, "getWebhookInfo", nil)
+	if err != nil {
+		return WebhookInfo{}, err
+	}
+	var payload struct {
+		OK     bool        `json:"ok"`
+		Result WebhookInfo `json:"result"`
+	}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return WebhookInfo{}, err
+	}
+	if !payload.OK {
+		return WebhookInfo{}, fmt.Errorf("telegram getWebhookInfo failed")
+	}
+	return payload.Result, nil
+}
+
 func (a *API) WebhookPath(webhookURL string) string {
 	parsed, err := url.Parse(webhookURL)
 	if err != nil {
@@ -205,6 +531,66 @@ func (a *API) ParseWebhookUpdate(body []byte) (Update, error) {
 	return update, err
 }
 
+// requestWithRetry wraps request with Telegram's documented rate limits and a
+// bounded 429 retry loop, for the idempotent calls safe to retry
+// (sendMessage, editMessageText, answerCallbackQuery). chatID of 0 means the
+// call isn't scoped to a single chat, so only the global budget applies.
+func (a *API) requestWithRetry(ctx context.Context, method string, endpoint string, body any, chatID int64) ([]byte, error) {
+	if a.limiter != nil {
+		if err := a.limiter.wait(ctx, chatID); err != nil {
+			return nil, err
+		}
+	}
+
+	var lastErr error
+	for attempt := 0; attempt < maxTelegramRetries; attempt++ {
+		raw, err := a.request(ctx, method, endpoint, body)
+		if err == nil {
+			sendTotal.WithLabelValue(endpoint + ":ok").Inc()
+			return raw, nil
+		}
+
+		var apiErr *apiError
+		if !errors.As(err, &apiErr) {
+			sendTotal.WithLabelValue(endpoint + ":error").Inc()
+			return nil, err
+		}
+		if apiErr.Code == http.StatusForbidden {
+			sendTotal.WithLabelValue(endpoint + ":unreachable").Inc()
+			return nil, fmt.Errorf("%w: %s", ErrRecipientUnreachable, apiErr.Description)
+		}
+		if apiErr.Code != http.StatusTooManyRequests {
+			sendTotal.WithLabelValue(endpoint + ":error").Inc()
+			return nil, err
+		}
+
+		rateLimitedTotal.WithLabelValue(endpoint).Inc()
+		lastErr = err
+
+		wait := time.Duration(apiErr.RetryAfter) * time.Second
+		if wait <= 0 || wait > maxRetryAfterWait {
+			wait = maxRetryAfterWait
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+		retryTotal.WithLabelValue(endpoint).Inc()
+
+		if a.limiter != nil {
+			if err := a.limiter.wait(ctx, chatID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	sendTotal.WithLabelValue(endpoint + ":error").Inc()
+	return nil, fmt.Errorf("telegram: exhausted retries for %s: %w", endpoint, lastErr)
+}
+
 func (a *API) request(ctx context.Context, method string, endpoint string, body any) ([]byte, error) {
 	var payload io.Reader
 	if body != nil {
@@ -235,11 +621,29 @@ func (a *API) request(ctx context.Context, method string, endpoint string, body
 		return nil, readErr
 	}
 	if res.StatusCode >= 400 {
+		return nil, parseAPIError(res.StatusCode, raw)
+	}
+	return raw, nil
+}
+
+// parseAPIError builds an *apiError from a Telegram error response body, so
+// requestWithRetry can branch on the error_code and retry_after fields
+// Telegram sends for 429s. It falls back to the raw body or status code if
+// the body isn't the expected JSON shape.
+func parseAPIError(statusCode int, raw []byte) error {
+	var payload struct {
+		ErrorCode   int    `json:"error_code"`
+		Description string `json:"description"`
+		Parameters  struct {
+			RetryAfter int `json:"retry_after"`
+		} `json:"parameters"`
+	}
+	if err := json.Unmarshal(raw, &payload); err != nil || payload.ErrorCode == 0 {
 		msg := strings.TrimSpace(string(raw))
 		if msg == "" {
-			msg = fmt.Sprintf("telegram status %d", res.StatusCode)
+			msg = fmt.Sprintf("telegram status %d", statusCode)
 		}
-		return nil, fmt.Errorf("%s", msg)
+		return &apiError{Code: statusCode, Description: msg}
 	}
-	return raw, nil
+	return &apiError{Code: payload.ErrorCode, Description: payload.Description, RetryAfter: payload.Parameters.RetryAfter}
 }
@@ -0,0 +1,124 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// telegramWebhookCIDRs are the source ranges Telegram documents for webhook
+// delivery: https://core.telegram.org/bots/webhooks
+var telegramWebhookCIDRs = []string{"149.154.160.0/20", "91.108.4.0/22"}
+
+// VerifyWebhookRequest rejects requests that don't carry the configured
+// secret token and don't originate from Telegram's documented webhook IP
+// ranges, so forged updates never reach RelayService/ControlService.
+//
+// When trustedProxyCIDRs is non-empty, the client IP is taken from the
+// right-most X-Forwarded-For/X-Real-IP hop that isn't inside a trusted
+// proxy CIDR (mirrors how strukturag/nextcloud-spreed-signaling resolves
+// client IPs behind a reverse proxy), falling back to RemoteAddr otherwise.
+func VerifyWebhookRequest(r *http.Request, secretToken string, trustedProxyCIDRs []string) error {
+	if secretToken != "" && r.Header.Get("X-Telegram-Bot-Api-Secret-Token") != secretToken {
+		return errors.New("webhook: invalid or missing secret token")
+	}
+
+	clientIP, err := clientIPFromRequest(r, trustedProxyCIDRs)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	if !ipInCIDRs(clientIP, telegramWebhookCIDRs) {
+		return fmt.Errorf("webhook: source IP %s is outside Telegram's webhook ranges", clientIP)
+	}
+	return nil
+}
+
+func clientIPFromRequest(r *http.Request, trustedProxyCIDRs []string) (net.IP, error) {
+	remoteIP, err := hostIP(r.RemoteAddr)
+	if err != nil {
+		return nil, fmt.Errorf("parse remote addr: %w", err)
+	}
+
+	trusted, err := parseCIDRs(trustedProxyCIDRs)
+	if err != nil {
+		return nil, fmt.Errorf("parse trusted proxy CIDRs: %w", err)
+	}
+	if len(trusted) == 0 {
+		return remoteIP, nil
+	}
+
+	chain := append(forwardedChain(r), remoteIP)
+	for i := len(chain) - 1; i >= 0; i-- {
+		if !ipInNets(chain[i], trusted) {
+			return chain[i], nil
+		}
+	}
+	return remoteIP, nil
+}
+
+// forwardedChain returns the IPs a reverse proxy reported, ordered from the
+// original client (left) to the nearest proxy (right), same order as a
+// standard X-Forwarded-For header.
+func forwardedChain(r *http.Request) []net.IP {
+	var chain []net.IP
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+		if ip := net.ParseIP(realIP); ip != nil {
+			chain = append(chain, ip)
+		}
+	}
+	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+		for _, part := range strings.Split(xff, ",") {
+			if ip := net.ParseIP(strings.TrimSpace(part)); ip != nil {
+				chain = append(chain, ip)
+			}
+		}
+	}
+	return chain
+}
+
+func hostIP(remoteAddr string) (net.IP, error) {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid remote address %q", remoteAddr)
+	}
+	return ip, nil
+}
+
+func parseCIDRs(raw []string) ([]*net.IPNet, error) {
+	out := make([]*net.IPNet, 0, len(raw))
+	for _, item := range raw {
+		trimmed := strings.TrimSpace(item)
+		if trimmed == "" {
+			continue
+		}
+		_, network, err := net.ParseCIDR(trimmed)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q: %w", trimmed, err)
+		}
+		out = append(out, network)
+	}
+	return out, nil
+}
+
+func ipInNets(ip net.IP, networks []*net.IPNet) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func ipInCIDRs(ip net.IP, raw []string) bool {
+	networks, err := parseCIDRs(raw)
+	if err != nil {
+		return false
+	}
+	return ipInNets(ip, networks)
+}